@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertRecent is how many of the latest probes an alert carries.
+const alertRecent = 5
+
+// alertMetrics are the measurements of a probe -alert-when compares,
+// those timed in milliseconds.
+var alertMetrics = map[string]func(r MonitorResult) float64{
+	"dns":          func(r MonitorResult) float64 { return float64(r.micros.DNS) / 1000 },
+	"tcp":          func(r MonitorResult) float64 { return float64(r.micros.TCP) / 1000 },
+	"tls":          func(r MonitorResult) float64 { return float64(r.micros.TLS) / 1000 },
+	"server":       func(r MonitorResult) float64 { return float64(r.micros.Server) / 1000 },
+	"transfer":     func(r MonitorResult) float64 { return float64(r.micros.Transfer) / 1000 },
+	"total":        func(r MonitorResult) float64 { return float64(r.micros.Total) / 1000 },
+	"p50":          func(r MonitorResult) float64 { return r.P50 },
+	"p95":          func(r MonitorResult) float64 { return r.P95 },
+	"status":       func(r MonitorResult) float64 { return float64(statusNumber(r.Status)) },
+	"availability": func(r MonitorResult) float64 { return r.Availability },
+}
+
+// alertMetricNames lists the alertMetrics in the order of the phases.
+var alertMetricNames = []string{"dns", "tcp", "tls", "server", "transfer", "total", "p50", "p95", "status", "availability"}
+
+// alertTimed are the alertMetrics whose values may be durations.
+var alertTimed = map[string]bool{"dns": true, "tcp": true, "tls": true, "server": true, "transfer": true, "total": true, "p50": true, "p95": true}
+
+// alertCondition is a parsed -alert-when expression, such as
+// 'total>1s || status>=500'. Comparisons of the alertMetrics are joined
+// by && and ||, and grouped with parentheses; down is true of a probe
+// that found the target down.
+type alertCondition interface {
+	// tripped returns the comparisons r meets that make the condition
+	// true, or nil if it's false.
+	tripped(r MonitorResult) []string
+}
+
+type alertComparison struct {
+	metric, op string
+	value      float64
+	text       string
+}
+
+func (c alertComparison) tripped(r MonitorResult) []string {
+	if c.metric == "down" {
+		if !r.Up {
+			return []string{c.text}
+		}
+		return nil
+	}
+	v := alertMetrics[c.metric](r)
+	var ok bool
+	switch c.op {
+	case ">":
+		ok = v > c.value
+	case ">=":
+		ok = v >= c.value
+	case "<":
+		ok = v < c.value
+	case "<=":
+		ok = v <= c.value
+	case "==":
+		ok = v == c.value
+	case "!=":
+		ok = v != c.value
+	}
+	if !ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s (%s)", c.text, trimFloat(v))}
+}
+
+type alertAnd []alertCondition
+
+func (a alertAnd) tripped(r MonitorResult) []string {
+	var all []string
+	for _, c := range a {
+		t := c.tripped(r)
+		if t == nil {
+			return nil
+		}
+		all = append(all, t...)
+	}
+	return all
+}
+
+type alertOr []alertCondition
+
+func (o alertOr) tripped(r MonitorResult) []string {
+	for _, c := range o {
+		if t := c.tripped(r); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// alertTokens splits an -alert-when expression into its tokens.
+func alertTokens(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		case strings.ContainsRune("<>=!&|", rune(c)):
+			j := i + 1
+			if j < len(s) && strings.ContainsRune("=&|", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t()<>=!&|", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// alertParser parses the tokens of an -alert-when expression by
+// recursive descent.
+type alertParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *alertParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *alertParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *alertParser) or() (alertCondition, error) {
+	var o alertOr
+	for {
+		c, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, c)
+		if p.peek() != "||" {
+			break
+		}
+		p.next()
+	}
+	if len(o) == 1 {
+		return o[0], nil
+	}
+	return o, nil
+}
+
+func (p *alertParser) and() (alertCondition, error) {
+	var a alertAnd
+	for {
+		c, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, c)
+		if p.peek() != "&&" {
+			break
+		}
+		p.next()
+	}
+	if len(a) == 1 {
+		return a[0], nil
+	}
+	return a, nil
+}
+
+func (p *alertParser) comparison() (alertCondition, error) {
+	tok := p.next()
+	switch {
+	case tok == "(":
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return c, nil
+	case tok == "down":
+		return alertComparison{metric: "down", text: "down"}, nil
+	case alertMetrics[tok] == nil:
+		return nil, fmt.Errorf("unknown measurement %q, use down or %s", tok, strings.Join(alertMetricNames, ", "))
+	}
+	op := p.next()
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return nil, fmt.Errorf("expected a comparison after %s, got %q", tok, op)
+	}
+	s := p.next()
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && alertTimed[tok] {
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		v = float64(d) / float64(time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q for %s", s, tok)
+	}
+	return alertComparison{tok, op, v, tok + op + s}, nil
+}
+
+// parseAlertCondition parses an -alert-when expression.
+func parseAlertCondition(s string) (alertCondition, error) {
+	p := &alertParser{tokens: alertTokens(s)}
+	c, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert condition %q: %v", s, err)
+	}
+	return c, nil
+}
+
+// Alert is posted to the -alert-webhook when a probe trips the
+// -alert-when condition.
+type Alert struct {
+	Time      string
+	Target    string
+	URL       string
+	Condition string          // the comparisons of -alert-when the probe met
+	Recent    []MonitorResult // the latest probes of the target, oldest first
+}
+
+// alerter is a monitorSink posting an Alert to a webhook when a result
+// trips its condition, at most once every cooldown for each target.
+type alerter struct {
+	webhook  string
+	when     alertCondition
+	cooldown time.Duration
+	client   *http.Client
+	targets  map[string]*monitorTarget
+
+	mu   sync.Mutex
+	last map[string]time.Time // when each target was last alerted on
+	wg   sync.WaitGroup       // the alerts being posted
+}
+
+func (a *alerter) write(r MonitorResult) error {
+	tripped := a.when.tripped(r)
+	if tripped == nil {
+		return nil
+	}
+	a.mu.Lock()
+	now := time.Now()
+	if last, ok := a.last[r.Target]; ok && now.Sub(last) < a.cooldown {
+		a.mu.Unlock()
+		return nil
+	}
+	a.last[r.Target] = now
+	a.mu.Unlock()
+
+	alert := Alert{Time: r.Time, Target: r.Target, URL: r.URL, Condition: strings.Join(tripped, " && ")}
+	if t := a.targets[r.Target]; t != nil {
+		alert.Recent = t.recent(alertRecent)
+	}
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	// the probes carry on while the alert is posted.
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		resp, err := a.client.Post(a.webhook, "application/json", bytes.NewReader(b))
+		if err != nil {
+			log.Printf("warning: unable to post alert on %s: %v", r.Target, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("warning: alert webhook answered %s", resp.Status)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertCondition(t *testing.T) {
+	slow := MonitorResult{Up: true, Status: "200 OK", micros: Timing{Server: 1200000, Total: 1500000}, Availability: 100}
+	broken := MonitorResult{Up: false, Status: "503 Service Unavailable", micros: Timing{Total: 20000}, Availability: 80}
+	refused := MonitorResult{Up: false, Error: "connection refused"}
+	for _, tt := range []struct {
+		when                  string
+		slow, broken, refused []string
+	}{
+		{"total>1s || status>=500", []string{"total>1s (1500)"}, []string{"status>=500 (503)"}, nil},
+		{"total > 1000 && server>=1.2s", []string{"total>1000 (1500)", "server>=1.2s (1200)"}, nil, nil},
+		{"down", nil, []string{"down"}, []string{"down"}},
+		{"(down || total>1s) && availability<90", nil, []string{"down", "availability<90 (80)"}, []string{"down", "availability<90 (0)"}},
+		{"status!=200", nil, []string{"status!=200 (503)"}, []string{"status!=200 (0)"}},
+	} {
+		c, err := parseAlertCondition(tt.when)
+		if err != nil {
+			t.Errorf("%s: %v", tt.when, err)
+			continue
+		}
+		for _, r := range []struct {
+			name   string
+			result MonitorResult
+			want   []string
+		}{{"slow", slow, tt.slow}, {"broken", broken, tt.broken}, {"refused", refused, tt.refused}} {
+			if got := c.tripped(r.result); !reflect.DeepEqual(got, r.want) {
+				t.Errorf("%s of the %s probe = %q, want %q", tt.when, r.name, got, r.want)
+			}
+		}
+	}
+
+	for _, when := range []string{"", "total>", "latency>1s", "status>=5xx", "total=1s", "(down", "down down", "down ||"} {
+		if _, err := parseAlertCondition(when); err == nil {
+			t.Errorf("%q parsed, want an error", when)
+		}
+	}
+}
+
+func TestAlerter(t *testing.T) {
+	alerts := make(chan Alert, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Error(err)
+		}
+		alerts <- a
+	}))
+	defer ts.Close()
+
+	target := &monitorTarget{Name: "api", URL: "https://api.example.com/", size: 10}
+	when, _ := parseAlertCondition("status>=500")
+	a := &alerter{webhook: ts.URL, when: when, cooldown: time.Hour, client: ts.Client(),
+		targets: map[string]*monitorTarget{"api": target}, last: map[string]time.Time{}}
+	for _, status := range []string{"200 OK", "503 Service Unavailable", "500 Internal Server Error", "200 OK"} {
+		r := MonitorResult{Target: "api", URL: target.URL, Status: status, Up: status == "200 OK"}
+		target.add(&r)
+		if err := a.write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.wg.Wait()
+	close(alerts)
+
+	var got []Alert
+	for alert := range alerts {
+		got = append(got, alert)
+	}
+	// the second failure is within the cooldown.
+	if len(got) != 1 {
+		t.Fatalf("posted %d alerts, want 1", len(got))
+	}
+	if alert := got[0]; alert.Target != "api" || alert.Condition != "status>=500 (503)" || len(alert.Recent) != 2 ||
+		!strings.HasPrefix(alert.Recent[1].Status, "503") {
+		t.Errorf("alert %+v", alert)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// glyph returns s, or the ASCII alternative for it with -ascii or when
+// the terminal may not show anything else.
+func glyph(s, ascii string) string {
+	if asciiOutput {
+		return ascii
+	}
+	return s
+}
+
+// utf8Locale reports whether the locale, from LC_ALL, LC_CTYPE or
+// LANG, uses UTF-8. If none of them is set it's assumed to.
+func utf8Locale() bool {
+	for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return true
+}
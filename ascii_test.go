@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestUTF8Locale(t *testing.T) {
+	for _, tt := range []struct {
+		lcAll, lang string
+		want        bool
+	}{
+		{"", "en_US.UTF-8", true},
+		{"", "de_DE.utf8", true},
+		{"C", "en_US.UTF-8", false},
+		{"", "POSIX", false},
+		{"", "en_GB.ISO-8859-1", false},
+		{"", "", true},
+	} {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_CTYPE", "")
+		t.Setenv("LANG", tt.lang)
+		if got := utf8Locale(); got != tt.want {
+			t.Errorf("LC_ALL=%q LANG=%q: got %v, want %v", tt.lcAll, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestGlyph(t *testing.T) {
+	defer func(v bool) { asciiOutput = v }(asciiOutput)
+	asciiOutput = false
+	if got := glyph("▇", "#"); got != "▇" {
+		t.Errorf("got %q", got)
+	}
+	asciiOutput = true
+	if got := glyph("▇", "#"); got != "#" {
+		t.Errorf("with -ascii got %q", got)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// shellSafe matches the words that need no quoting in a shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// curlCommandLine returns the options of a curl command making req as
+// visiting it with tr would, one option with its value to a line, and
+// what about the request curl can't be told to do.
+func curlCommandLine(req *http.Request, tr *http.Transport) (lines [][]string, warnings []string) {
+	add := func(words ...string) { lines = append(lines, words) }
+	add(req.URL.String())
+
+	hasBody := postBody != "" || len(formData) > 0 || bodyRandom.max > 0
+	switch {
+	case req.Method == "HEAD" && !hasBody:
+		add("-I")
+	case req.Method == "GET" && !hasBody, req.Method == "POST" && hasBody:
+	default:
+		add("-X", req.Method)
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if req.Host != "" && req.Host != req.URL.Host {
+		add("-H", "Host: "+req.Host)
+	}
+	for _, k := range keys {
+		switch {
+		case k == "Content-Type" && len(formData) > 0:
+			continue // curl sets it, with its own boundary
+		case k == "Content-Encoding" && compressBody != "":
+			continue
+		}
+		for _, v := range req.Header[k] {
+			add("-H", k+": "+v)
+		}
+	}
+	if len(req.TransferEncoding) > 0 {
+		add("-H", "Transfer-Encoding: "+strings.Join(req.TransferEncoding, ", "))
+	}
+	if authUser != "" && authScheme != "basic" && req.URL.Host == authHost {
+		add("--"+authScheme, "-u", authUser+":"+authPassword)
+	}
+
+	switch {
+	case len(formData) > 0:
+		for _, p := range formData {
+			switch {
+			case p.file || p.contentFile:
+				field := p.name + "=@" + p.value
+				if p.contentFile {
+					field = p.name + "=<" + p.value
+				}
+				if p.contentType != "" {
+					field += ";type=" + p.contentType
+				}
+				if p.file && p.filename != filepath.Base(p.value) {
+					field += ";filename=" + p.filename
+				}
+				add("-F", field)
+			default:
+				add("--form-string", p.name+"="+p.value)
+			}
+		}
+	case bodyRandom.max > 0:
+		warnings = append(warnings, "curl can't generate a random body, use -d @file with one")
+	case strings.HasPrefix(postBody, "@"):
+		add("--data-binary", postBody)
+	case postBody != "":
+		add("--data-raw", expandTemplate(postBody))
+	}
+	if compressBody != "" {
+		warnings = append(warnings, "curl can't compress the request body, it sends it as is")
+	}
+	if len(requestTrailers) > 0 {
+		warnings = append(warnings, "curl can't send request trailers")
+	}
+
+	if followRedirects {
+		add("-L")
+	}
+	if fourOnly {
+		add("-4")
+	}
+	if sixOnly {
+		add("-6")
+	}
+	if maxTime > 0 {
+		add("--max-time", trimFloat(maxTime.Seconds()))
+	}
+	if f := flag.Lookup("connect-timeout"); f.Value.String() != f.DefValue {
+		add("--connect-timeout", trimFloat(connectTimeout.Seconds()))
+	}
+	if tr.Proxy != nil {
+		if u, err := tr.Proxy(req); err == nil && u != nil {
+			if proxyUser != nil && u.User == proxyUser {
+				password, _ := proxyUser.Password()
+				add("-U", proxyUser.Username()+":"+password)
+				u.User = nil
+			}
+			add("-x", u.String())
+		}
+	}
+
+	if req.URL.Scheme == "https" {
+		if insecure {
+			add("-k")
+		}
+		if cacert != "" {
+			add("--cacert", cacert)
+		}
+		switch {
+		case clientCertFile != "" && clientCertPass != "":
+			add("--cert-type", "P12", "--cert", clientCertFile+":"+clientCertPass)
+		case clientCertFile != "":
+			add("--cert", clientCertFile)
+		}
+		if clientKeyFile != "" {
+			add("--key", clientKeyFile)
+		}
+		if clientKeyPass != "" {
+			add("--pass", clientKeyPass)
+		}
+		if pkcs11Spec != "" {
+			warnings = append(warnings, "give curl the PKCS#11 key as a pkcs11: URI with --cert")
+		}
+		if tlsMin != 0 {
+			add("--tlsv" + tlsMin.String())
+		}
+		if tlsMax != 0 {
+			add("--tls-max", tlsMax.String())
+		}
+		if len(publicKeyPins) > 0 {
+			add("--pinnedpubkey", strings.Join(publicKeyPins, ";"))
+		}
+		if serverName != "" {
+			warnings = append(warnings, "curl can't be given a TLS server name other than the URL's host")
+		}
+		switch {
+		case echConfigFile != "":
+			warnings = append(warnings, "give curl the ECH config list with --ech ecl:BASE64")
+		case useECH:
+			add("--ech", "true")
+		}
+	}
+	return lines, warnings
+}
+
+// printCurlCommand prints the curl command line that makes req as
+// visiting it with tr would, to hand on to anyone without httpstat.
+func printCurlCommand(req *http.Request, tr *http.Transport) {
+	lines, warnings := curlCommandLine(req, tr)
+	for _, w := range warnings {
+		log.Printf("warning: %s", w)
+	}
+	s := make([]string, len(lines))
+	for i, words := range lines {
+		for j := range words {
+			words[j] = shellQuote(words[j])
+		}
+		s[i] = strings.Join(words, " ")
+	}
+	printf("curl %s\n", strings.Join(s, " \\\n  "))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	for s, want := range map[string]string{
+		"https://example.com/a?b=1": "'https://example.com/a?b=1'",
+		"-H":                        "-H",
+		"Accept: */*":               "'Accept: */*'",
+		`{"it's": 1}`:               `'{"it'\''s": 1}'`,
+		"":                          "''",
+	} {
+		if got := shellQuote(s); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestCurlCommandLine(t *testing.T) {
+	defer func(body string, follow, k bool, ca string, min tlsVersion) {
+		postBody, followRedirects, insecure, cacert, tlsMin = body, follow, k, ca, min
+	}(postBody, followRedirects, insecure, cacert, tlsMin)
+	postBody, followRedirects, insecure, cacert, tlsMin = `{"a": 1}`, true, true, "ca.pem", tlsVersion(0x0303)
+
+	req, _ := http.NewRequest("PUT", "https://example.com/x", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	proxy, _ := url.Parse("http://proxy:3128")
+	tr := &http.Transport{Proxy: http.ProxyURL(proxy)}
+
+	lines, warnings := curlCommandLine(req, tr)
+	want := [][]string{
+		{"https://example.com/x"},
+		{"-X", "PUT"},
+		{"-H", "Accept-Encoding: gzip"},
+		{"-H", "Content-Type: application/json"},
+		{"--data-raw", `{"a": 1}`},
+		{"-L"},
+		{"-x", "http://proxy:3128"},
+		{"-k"},
+		{"--cacert", "ca.pem"},
+		{"--tlsv1.2"},
+	}
+	if !reflect.DeepEqual(lines, want) || len(warnings) > 0 {
+		t.Errorf("got %q, %q\nwant %q", lines, warnings, want)
+	}
+
+	// the command translates back to the same request.
+	var words []string
+	for _, l := range lines {
+		for _, w := range l {
+			words = append(words, shellQuote(w))
+		}
+	}
+	parsed, err := shellWords("curl " + strings.Join(words, " \\\n  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseCurl(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-H", "Accept-Encoding: gzip", "-H", "Content-Type: application/json", "-L", "-k",
+		"-cacert", "ca.pem", "-tls-min", "1.2", "-d", `{"a": 1}`, "-X", "PUT"}
+	if !reflect.DeepEqual(c.args, args) || c.url != "https://example.com/x" || c.proxy != "http://proxy:3128" {
+		t.Errorf("translated back to %q %s %s", c.args, c.url, c.proxy)
+	}
+}
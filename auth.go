@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// credentials supplied with -u or -token, only sent to the host named
+// on the command line, not to hosts we are redirected to.
+var (
+	authUser     string
+	authPassword string
+	authToken    string
+	authHost     string
+)
+
+// proxyUser is the user and password of -proxy-user.
+var proxyUser *url.Userinfo
+
+// parseUserCredentials splits the user:password argument to -u,
+// prompting for the password if it was omitted and looking it up in
+// the OS keychain if given as keyring:NAME.
+func parseUserCredentials(s string) (string, string, error) {
+	if i := strings.Index(s, ":"); i != -1 {
+		if strings.HasPrefix(s[i+1:], "keyring:") {
+			pass, err := resolveSecret(s[i+1:])
+			return s[:i], pass, err
+		}
+		return s[:i], s[i+1:], nil
+	}
+	pass, err := readPassword(fmt.Sprintf("Enter host password for user '%s': ", s))
+	if err != nil {
+		return "", "", fmt.Errorf("unable to read password: %v", err)
+	}
+	return s, pass, nil
+}
+
+// resolveSecret returns the secret named by s, which is either a
+// literal value, @filename to read it from a file, env:NAME to read
+// it from an environment variable or keyring:NAME to read it from the
+// OS keychain.
+func resolveSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "@"):
+		b, err := ioutil.ReadFile(s[1:])
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	case strings.HasPrefix(s, "env:"):
+		v, ok := os.LookupEnv(s[4:])
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s[4:])
+		}
+		return v, nil
+	case strings.HasPrefix(s, "keyring:"):
+		return keyringSecret(s[8:])
+	default:
+		return s, nil
+	}
+}
+
+// setAuthorization adds the configured credentials to req.
+func setAuthorization(req *http.Request) {
+	if req.URL.Host != authHost {
+		return
+	}
+	switch {
+	case authToken != "":
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	case authUser != "" && authScheme == "basic":
+		req.SetBasicAuth(authUser, authPassword)
+	}
+}
+
+// authenticate answers an authentication challenge in resp, if the
+// configured scheme can, by repeating the request with credentials.
+// The timing of each challenged exchange is kept in report.AuthRoundTrips
+// while report.Timing records the final exchange.
+func authenticate(client *http.Client, url *url.URL, resp *http.Response, report *Report) *http.Response {
+	if resp.StatusCode != http.StatusUnauthorized || authUser == "" || url.Host != authHost {
+		return resp
+	}
+
+	switch authScheme {
+	case "digest":
+		challenge, ok := parseDigestChallenge(resp.Header)
+		if !ok {
+			return resp
+		}
+		// the digest covers the URI the request is sent to, once expanded.
+		req := retryRequest(resp, report)
+		req.Header.Set("Authorization", challenge.authorize(req.Method, req.URL.RequestURI(), authUser, authPassword, newCnonce()))
+		return roundTrip(client, req, report)
+	case "ntlm", "negotiate":
+		// Negotiate is answered with NTLM tokens, which SPNEGO accepts;
+		// Kerberos is not supported.
+		scheme := "NTLM"
+		if authScheme == "negotiate" {
+			scheme = "Negotiate"
+		}
+		if _, ok := ntlmToken(resp.Header.Values("WWW-Authenticate"), scheme); !ok {
+			return resp
+		}
+		resp = retryAuthorized(client, resp, report,
+			scheme+" "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+
+		tok, ok := ntlmToken(resp.Header.Values("WWW-Authenticate"), scheme)
+		if resp.StatusCode != http.StatusUnauthorized || !ok || tok == nil {
+			return resp
+		}
+		challenge, err := parseNTLMChallenge(tok)
+		if err != nil {
+			report.Warnings = append(report.Warnings, err.Error())
+			return resp
+		}
+		msg := ntlmAuthenticateMessage(challenge, authUser, authPassword, newClientChallenge(), time.Now())
+		return retryAuthorized(client, resp, report, scheme+" "+base64.StdEncoding.EncodeToString(msg))
+	default:
+		return resp
+	}
+}
+
+// retryAuthorized completes the challenged exchange in resp, then
+// repeats the request with the given Authorization header.
+func retryAuthorized(client *http.Client, resp *http.Response, report *Report, authorization string) *http.Response {
+	req := retryRequest(resp, report)
+	req.Header.Set("Authorization", authorization)
+	return roundTrip(client, req, report)
+}
+
+// retryRequest completes the challenged exchange in resp and returns the
+// request to repeat it with: the one challenged, with its body rewound,
+// so its templates aren't expanded afresh.
+func retryRequest(resp *http.Response, report *Report) *http.Request {
+	// drain the body so the connection, which NTLM authenticates, is reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	report.finish()
+	report.AuthRoundTrips = append(report.AuthRoundTrips, report.Timing)
+	report.authMicros = append(report.authMicros, report.micros)
+	report.Timing, report.micros = Timing{}, Timing{}
+	report.Size = Sizes{}
+
+	req := resp.Request.Clone(resp.Request.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			failRequest(errors.New("body not rewindable"), "unable to repeat the request with credentials: its body, streamed, can't be sent again")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			failRequest(err, "unable to repeat the request with credentials: %v", err)
+		}
+		req.Body = body
+	}
+	return req
+}
+
+// parseProxyCredentials parses the user:password argument to
+// -proxy-user as parseUserCredentials does that of -u.
+func parseProxyCredentials(s string) (*url.Userinfo, error) {
+	user, password, err := parseUserCredentials(s)
+	if err != nil {
+		return nil, err
+	}
+	return url.UserPassword(user, password), nil
+}
+
+// proxyFromEnvironment is http.ProxyFromEnvironment, authenticating to
+// the proxy as the -proxy-user.
+var proxyFromEnvironment = withProxyUser(http.ProxyFromEnvironment)
+
+// withProxyUser returns proxy, setting the -proxy-user on the proxy URLs
+// without credentials of their own.
+func withProxyUser(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if u == nil || err != nil || proxyUser == nil || u.User != nil {
+			return u, err
+		}
+		u2 := *u
+		u2.User = proxyUser
+		return &u2, nil
+	}
+}
+
+// resolveHeaderSecrets replaces header values given as keyring:NAME with
+// the secret from the OS keychain.
+func resolveHeaderSecrets(h headers) error {
+	for i, v := range h {
+		k, val := headerKeyValue(v)
+		if !strings.HasPrefix(val, "keyring:") {
+			continue
+		}
+		secret, err := resolveSecret(val)
+		if err != nil {
+			return err
+		}
+		h[i] = k + ": " + secret
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("HTTPSTAT_TEST_TOKEN", "from-env")
+	defer os.Unsetenv("HTTPSTAT_TEST_TOKEN")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"literal", "literal"},
+		{"@" + file, "from-file"},
+		{"env:HTTPSTAT_TEST_TOKEN", "from-env"},
+	}
+
+	for _, test := range tests {
+		got, err := resolveSecret(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("Given: %s\nwant: %s\ngot: %s (%v)", test.in, test.want, got, err)
+		}
+	}
+
+	if _, err := resolveSecret("env:HTTPSTAT_TEST_UNSET"); err == nil {
+		t.Errorf("expected error for unset variable")
+	}
+}
+
+func TestProxyCredentials(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the keychain is looked up with secret-tool elsewhere")
+	}
+	// a secret-tool with the one secret.
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$3\" = proxy ] && printf s3cret\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	defer func(u *url.Userinfo) { proxyUser = u }(proxyUser)
+	var err error
+	if proxyUser, err = parseProxyCredentials("alice:keyring:proxy"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Proxy-Authorization")
+	}))
+	defer proxy.Close()
+	u, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: withProxyUser(http.ProxyURL(u))}}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:s3cret")); got != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", got, want)
+	}
+}
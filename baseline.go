@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Baseline is the summary of a run saved with -save-baseline.
+type Baseline struct {
+	URL     string
+	Saved   time.Time
+	Summary *Summary
+}
+
+// percentage is a flag.Value for a fraction given as a percentage,
+// such as 20%.
+type percentage float64
+
+func (p *percentage) String() string {
+	return strconv.FormatFloat(float64(*p)*100, 'f', -1, 64) + "%"
+}
+
+func (p *percentage) Set(s string) error {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid percentage %q", s)
+	}
+	*p = percentage(f / 100)
+	return nil
+}
+
+// baselinePath returns the file of the named baseline, kept in the data
+// directory, unless name is itself the path of a .json file.
+func baselinePath(name string) string {
+	if strings.HasSuffix(name, ".json") || strings.ContainsRune(name, filepath.Separator) {
+		return name
+	}
+	return dataPath("baselines", name+".json")
+}
+
+func writeBaseline(filename string, u *url.URL, s *Summary) error {
+	b, err := json.MarshalIndent(Baseline{URL: u.String(), Saved: time.Now(), Summary: s}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+func loadBaseline(filename string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if b.Summary == nil {
+		return nil, fmt.Errorf("%s: no summary", filename)
+	}
+	return &b, nil
+}
+
+// baselineSlack is how many milliseconds slower a phase must get to be
+// a regression, whatever the tolerance, as timings are to the
+// millisecond.
+const baselineSlack = 1
+
+// PhaseChange compares the median time of a phase with the baseline's.
+type PhaseChange struct {
+	Phase     string
+	Baseline  float64
+	Current   float64
+	Change    float64 // the fraction slower, negative when faster
+	Regressed bool
+}
+
+// BaselineComparison compares the phases of a run with a baseline.
+type BaselineComparison struct {
+	Requests  int
+	Tolerance float64
+	Phases    []PhaseChange
+}
+
+func (c *BaselineComparison) regressed() bool {
+	for _, p := range c.Phases {
+		if p.Regressed {
+			return true
+		}
+	}
+	return false
+}
+
+// compareToBaseline compares the median time of each phase of s with
+// that of the baseline b. A phase has regressed if it's slower by more
+// than the tolerance, and by more than baselineSlack.
+func compareToBaseline(b *Baseline, s *Summary, tolerance float64) *BaselineComparison {
+	c := &BaselineComparison{Requests: s.Requests, Tolerance: tolerance}
+	for _, p := range summaryPhases {
+		was, now := p.stats(b.Summary).P50, p.stats(s).P50
+		pc := PhaseChange{Phase: p.name, Baseline: was, Current: now}
+		if was > 0 {
+			pc.Change = (now - was) / was
+		}
+		pc.Regressed = now-was > baselineSlack && (was == 0 || pc.Change > tolerance)
+		c.Phases = append(c.Phases, pc)
+	}
+	return c
+}
+
+func printBaselineComparison(name string, b *Baseline, c *BaselineComparison, scheme string) {
+	label := labelString
+	printf("\n%s\n", label("Median of %d requests compared with baseline %s, the median of %d saved %s:",
+		c.Requests, name, b.Summary.Requests, b.Saved.Local().Format("2006-01-02 15:04")))
+	for _, p := range c.Phases {
+		if p.Phase == "TLS Handshake" && scheme != "https" {
+			continue
+		}
+		// a change from nothing is only meaningful in milliseconds.
+		change := fmt.Sprintf("%+.0f%%", p.Change*100)
+		if p.Baseline == 0 {
+			change = fmt.Sprintf("%+.1fms", p.Current)
+		}
+		if p.Regressed {
+			change = errorString("%s regression", change)
+		} else {
+			change = valueString(change)
+		}
+		printf("   %s %s %s %s  %s\n", label("%-18s", p.Phase+":"),
+			valueString("%7.1fms", p.Baseline), label(glyph("→", "->")), valueString("%7.1fms", p.Current), change)
+	}
+	if c.regressed() {
+		printf("%s\n", errorString("Slower than the baseline by more than the %.0f%% tolerance.", c.Tolerance*100))
+	} else {
+		printf("%s\n", okString("Within the %.0f%% tolerance of the baseline.", c.Tolerance*100))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestPercentage(t *testing.T) {
+	for s, want := range map[string]float64{"20%": 0.2, "5": 0.05, "12.5%": 0.125} {
+		var p percentage
+		if err := p.Set(s); err != nil || float64(p) != want {
+			t.Errorf("%q: got %v, %v, want %v", s, float64(p), err, want)
+		}
+	}
+	var p percentage
+	if err := p.Set("-1%"); err == nil {
+		t.Error("negative percentage accepted")
+	}
+}
+
+func TestBaselinePath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+	if got := baselinePath("prod"); got != filepath.Join("/data", "httpstat", "baselines", "prod.json") {
+		t.Errorf("got %q", got)
+	}
+	if got := baselinePath("ci/baseline.json"); got != "ci/baseline.json" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	reports := func(server, transfer int) []Report {
+		return []Report{{Status: "200 OK", Timing: Timing{DNS: 1, Server: server, Transfer: transfer, Total: 1 + server + transfer}}}
+	}
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	u, _ := url.Parse("https://example.com/")
+	if err := writeBaseline(filename, u, summarize(reports(100, 0))); err != nil {
+		t.Fatal(err)
+	}
+	b, err := loadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		server, transfer int
+		regressed        []string
+	}{
+		{110, 0, nil},
+		{130, 0, []string{"Server Processing", "Total"}},
+		{100, 1, nil},
+		{90, 3, []string{"Content Transfer"}},
+	} {
+		c := compareToBaseline(b, summarize(reports(tt.server, tt.transfer)), 0.2)
+		var regressed []string
+		for _, p := range c.Phases {
+			if p.Regressed {
+				regressed = append(regressed, p.Phase)
+			}
+		}
+		if len(regressed) != len(tt.regressed) || c.regressed() != (len(tt.regressed) > 0) {
+			t.Errorf("server %dms, transfer %dms: got regressions %q, want %q", tt.server, tt.transfer, regressed, tt.regressed)
+			continue
+		}
+		for i := range regressed {
+			if regressed[i] != tt.regressed[i] {
+				t.Errorf("server %dms, transfer %dms: got regressions %q, want %q", tt.server, tt.transfer, regressed, tt.regressed)
+			}
+		}
+	}
+}
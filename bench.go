@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultWorkerPort is the port workers listen on, unless told otherwise.
+const defaultWorkerPort = "7070"
+
+// BenchJob is the load a bench coordinator asks each worker to generate.
+type BenchJob struct {
+	URL         string
+	Method      string
+	Header      http.Header `json:",omitempty"`
+	Body        string      `json:",omitempty"`
+	Concurrency int
+	Duration    time.Duration
+	MaxTime     time.Duration `json:",omitempty"`
+	Insecure    bool          `json:",omitempty"`
+}
+
+// WorkerResult is what a worker measured of a job.
+type WorkerResult struct {
+	Worker     string
+	Requests   int
+	Errors     int // failed requests and 5xx responses
+	Status     map[string]int
+	Throughput float64   // requests completed a second
+	Latencies  []float64 // milliseconds, of the requests without errors
+	Error      string    `json:",omitempty"` // why the worker couldn't run the job
+}
+
+// jobRequest returns a request of the load of job.
+func jobRequest(job BenchJob) (*http.Request, error) {
+	req, err := http.NewRequest(job.Method, job.URL, strings.NewReader(job.Body))
+	if err != nil {
+		return nil, err
+	}
+	if (req.URL.Scheme != "http" && req.URL.Scheme != "https") || req.URL.Host == "" {
+		return nil, fmt.Errorf("not an http or https URL: %s", job.URL)
+	}
+	for k, v := range job.Header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// runJob generates the load of job and measures it.
+func runJob(job BenchJob) WorkerResult {
+	if _, err := jobRequest(job); err != nil {
+		return WorkerResult{Error: err.Error()}
+	}
+	client := crawlClient(job.Concurrency)
+	client.Timeout = job.MaxTime
+	client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = job.Insecure
+	newRequest := func() *http.Request {
+		req, _ := jobRequest(job)
+		return req
+	}
+	results, elapsed := generateLoad(client, newRequest, float64(job.Concurrency), false, job.Duration)
+
+	r := WorkerResult{Requests: len(results), Status: map[string]int{}, Throughput: float64(len(results)) / elapsed.Seconds()}
+	for _, res := range results {
+		if res.Error != "" {
+			r.Errors++
+			r.Status["failed"]++
+			continue
+		}
+		r.Status[res.Status]++
+		if statusNumber(res.Status) >= 500 {
+			r.Errors++
+			continue
+		}
+		r.Latencies = append(r.Latencies, float64(res.micros.Total)/1000)
+	}
+	return r
+}
+
+// workerHandler runs the jobs posted to it one at a time, for coordinators
+// presenting token if it isn't empty, refusing those longer than
+// maxDuration or of more than maxConcurrency requests at a time.
+func workerHandler(token string, maxDuration time.Duration, maxConcurrency int) http.Handler {
+	running := make(chan struct{}, 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a job", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		var job BenchJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch {
+		case job.Concurrency < 1 || job.Duration <= 0:
+			http.Error(w, "invalid job: the concurrency and duration must be positive", http.StatusBadRequest)
+			return
+		case maxDuration > 0 && job.Duration > maxDuration:
+			http.Error(w, fmt.Sprintf("invalid job: longer than the %s this worker allows", maxDuration), http.StatusBadRequest)
+			return
+		case maxConcurrency > 0 && job.Concurrency > maxConcurrency:
+			http.Error(w, fmt.Sprintf("invalid job: more than the %d concurrent requests this worker allows", maxConcurrency), http.StatusBadRequest)
+			return
+		}
+		if _, err := jobRequest(job); err != nil {
+			http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case running <- struct{}{}:
+			defer func() { <-running }()
+		default:
+			http.Error(w, "busy running another job", http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("running %d concurrent %s %s for %s, for %s", job.Concurrency, job.Method, job.URL, job.Duration, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runJob(job))
+	})
+}
+
+// workerURL returns the URL to post jobs to the worker at addr, a host
+// with an optional port or a URL.
+func workerURL(addr string) string {
+	if strings.Contains(addr, "://") {
+		return addr
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultWorkerPort)
+	}
+	return "http://" + addr + "/"
+}
+
+// sendJob has the worker at addr run job and returns its result.
+func sendJob(client *http.Client, addr, token string, job BenchJob) WorkerResult {
+	fail := func(err error) WorkerResult {
+		return WorkerResult{Worker: addr, Error: err.Error()}
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return fail(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, workerURL(addr), bytes.NewReader(b))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fail(fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg))))
+	}
+	var r WorkerResult
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fail(fmt.Errorf("invalid result: %v", err))
+	}
+	r.Worker = addr
+	return r
+}
+
+// aggregateResults combines the results of the workers that ran the job.
+func aggregateResults(results []WorkerResult) WorkerResult {
+	all := WorkerResult{Worker: "all", Status: map[string]int{}}
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		all.Requests += r.Requests
+		all.Errors += r.Errors
+		all.Throughput += r.Throughput
+		all.Latencies = append(all.Latencies, r.Latencies...)
+		for s, n := range r.Status {
+			all.Status[s] += n
+		}
+	}
+	return all
+}
+
+func printWorkerResult(r WorkerResult, width int) {
+	name := fmt.Sprintf("%-*s", width, r.Worker)
+	if r.Error != "" {
+		printf("   %s %s\n", labelString(name), errorString("%s", r.Error))
+		return
+	}
+	s := newStats(r.Latencies)
+	row := fmt.Sprintf("%9d %7d %9s %9s %9s %9s", r.Requests, r.Errors, fmt.Sprintf("%.1f/s", r.Throughput),
+		fmt.Sprintf("%.1fms", s.P50), fmt.Sprintf("%.1fms", s.P95), fmt.Sprintf("%.1fms", s.Max))
+	if r.Errors > 0 {
+		printf("   %s %s\n", labelString(name), errorString("%s", row))
+	} else {
+		printf("   %s %s\n", labelString(name), valueString("%s", row))
+	}
+}
+
+// printBench prints the result of each worker, then of them all.
+func printBench(results []WorkerResult, all WorkerResult) {
+	width := len(all.Worker)
+	for _, r := range results {
+		width = max(width, len(r.Worker))
+	}
+	printf("   %s\n", labelString("%-*s %9s %7s %9s %9s %9s %9s", width, "Worker", "Requests", "Errors", "Rate", "p50", "p95", "Max"))
+	ran := 0
+	for _, r := range results {
+		printWorkerResult(r, width)
+		if r.Error == "" {
+			ran++
+		}
+	}
+	if ran == 0 {
+		printf("\n%s\n", errorString("No worker ran the job."))
+		return
+	}
+	printWorkerResult(all, width)
+
+	statuses := make([]string, 0, len(all.Status))
+	for s := range all.Status {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	counts := make([]string, len(statuses))
+	for i, s := range statuses {
+		counts[i] = valueString("%d %s %s", all.Status[s], glyph("×", "x"), s)
+		if s == "failed" || statusNumber(s) >= 500 {
+			counts[i] = errorString("%d %s %s", all.Status[s], glyph("×", "x"), s)
+		}
+	}
+	printf("\n%s %s\n", labelString("Summary of %d requests:", all.Requests), strings.Join(counts, labelString(", ")))
+	if len(all.Latencies) > 0 {
+		printStats("Total:", newStats(all.Latencies))
+	}
+}
+
+// runBench is the bench subcommand, which has workers on other machines
+// generate load on a URL at once and aggregates what they measured.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bench -workers HOST[:PORT],... [OPTIONS] URL\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Have each of the workers, started with the worker subcommand, make -c requests to URL at a time")
+		fmt.Fprintln(os.Stderr, "for -duration, and report the latency each measured and that of them all.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	workers := fs.String("workers", "", "comma-separated workers to run the load on, as host[:port]; the port defaults to "+defaultWorkerPort)
+	concurrency := fs.Int("c", 10, "requests each worker makes at a time")
+	duration := fs.Duration("duration", 30*time.Second, "how long the workers make requests for")
+	token := fs.String("token", os.Getenv("HTTPSTAT_WORKER_TOKEN"), "token the workers were started with; defaults to $HTTPSTAT_WORKER_TOKEN")
+	method := fs.String("X", "GET", "HTTP method to use")
+	body := fs.String("d", "", "the body of the requests")
+	var hdrs headers
+	fs.Var(&hdrs, "H", "set HTTP header; repeatable")
+	fs.BoolVar(&insecure, "k", false, "allow insecure SSL connections")
+	fs.DurationVar(&maxTime, "m", 0, "maximum time each request may take")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *workers == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	header, err := httpHeader(hdrs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	job := BenchJob{
+		URL:         parseURL(fs.Arg(0)).String(),
+		Method:      *method,
+		Header:      header,
+		Body:        *body,
+		Concurrency: max(*concurrency, 1),
+		Duration:    *duration,
+		MaxTime:     maxTime,
+		Insecure:    insecure,
+	}
+	addrs := strings.Split(*workers, ",")
+	printf("%s\n\n", labelString("Running %d concurrent requests to %s on each of %d workers for %s:", job.Concurrency, job.URL, len(addrs), job.Duration))
+
+	results := make([]WorkerResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			results[i] = sendJob(http.DefaultClient, strings.TrimSpace(addr), *token, job)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	all := aggregateResults(results)
+	printBench(results, all)
+	for _, r := range results {
+		if r.Error != "" {
+			exitStatus = 1
+		}
+	}
+	if all.Errors > 0 {
+		exitStatus = 1
+	}
+	os.Exit(exitStatus)
+}
+
+// runWorker is the worker subcommand, which generates the load bench
+// coordinators ask it to.
+func runWorker(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s worker [OPTIONS]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Listen for the jobs of the bench subcommand, make their requests and send back what was measured.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	listen := fs.String("listen", ":"+defaultWorkerPort, "address to listen on")
+	token := fs.String("token", os.Getenv("HTTPSTAT_WORKER_TOKEN"), "only run jobs from coordinators with this token; defaults to $HTTPSTAT_WORKER_TOKEN")
+	maxDuration := fs.Duration("max-duration", 10*time.Minute, "refuse jobs longer than this; no limit if 0")
+	maxConcurrency := fs.Int("max-concurrency", 100, "refuse jobs of more concurrent requests than this; no limit if 0")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *token == "" && !loopbackAddr(*listen) {
+		log.Fatalf("no -token, anyone who can reach %s could have this worker make requests; set one or listen on a loopback address", *listen)
+	}
+	log.Printf("listening for bench jobs on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, workerHandler(*token, *maxDuration, *maxConcurrency)))
+}
+
+// loopbackAddr reports whether the listen address addr is only reachable
+// from this machine.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
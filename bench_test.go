@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkerURL(t *testing.T) {
+	for addr, want := range map[string]string{
+		"host1":                   "http://host1:7070/",
+		"host1:8000":              "http://host1:8000/",
+		"[::1]:8000":              "http://[::1]:8000/",
+		"https://w.example/bench": "https://w.example/bench",
+	} {
+		if got := workerURL(addr); got != want {
+			t.Errorf("workerURL(%q) = %s, want %s", addr, got, want)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.Header.Get("X-Bench") != "1" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+	worker := httptest.NewServer(workerHandler("secret", time.Minute, 4))
+	defer worker.Close()
+	addr := strings.TrimPrefix(worker.URL, "http://")
+
+	job := BenchJob{URL: target.URL, Method: "PUT", Header: http.Header{"X-Bench": {"1"}}, Concurrency: 2, Duration: 50 * time.Millisecond}
+	results := []WorkerResult{
+		sendJob(worker.Client(), addr, "secret", job),
+		sendJob(worker.Client(), addr, "secret", job),
+		sendJob(worker.Client(), addr, "wrong", job),
+	}
+	for _, r := range results[:2] {
+		if r.Error != "" || r.Worker != addr || r.Requests == 0 || r.Errors != 0 || len(r.Latencies) != r.Requests {
+			t.Errorf("worker result %+v", r)
+		}
+	}
+	if !strings.HasPrefix(results[2].Error, "401") {
+		t.Errorf("with the wrong token: %+v", results[2])
+	}
+
+	all := aggregateResults(results)
+	if n := results[0].Requests + results[1].Requests; all.Requests != n || len(all.Latencies) != n || all.Status["200 OK"] != n {
+		t.Errorf("aggregated %+v", all)
+	}
+
+	job.Duration = time.Hour
+	if r := sendJob(worker.Client(), addr, "secret", job); !strings.Contains(r.Error, "longer than") {
+		t.Errorf("a job over the maximum duration: %+v", r)
+	}
+	job.Duration, job.Concurrency = time.Second, 5
+	if r := sendJob(worker.Client(), addr, "secret", job); !strings.Contains(r.Error, "more than the 4 concurrent") {
+		t.Errorf("a job over the maximum concurrency: %+v", r)
+	}
+	job.Concurrency = 1
+	for _, bad := range []BenchJob{
+		{URL: "://nowhere", Method: "GET", Concurrency: 1, Duration: time.Second},
+		{URL: "/relative", Method: "GET", Concurrency: 1, Duration: time.Second},
+		{URL: target.URL, Method: "BAD METHOD", Concurrency: 1, Duration: time.Second},
+	} {
+		if r := sendJob(worker.Client(), addr, "secret", bad); !strings.HasPrefix(r.Error, "400") {
+			t.Errorf("job %+v: %+v", bad, r)
+		}
+		if r := runJob(bad); r.Error == "" {
+			t.Errorf("runJob(%+v) = %+v, want an error", bad, r)
+		}
+	}
+
+	job.Duration = 300 * time.Millisecond
+	busy := make(chan WorkerResult)
+	go func() { busy <- sendJob(worker.Client(), addr, "secret", job) }()
+	time.Sleep(100 * time.Millisecond)
+	if r := sendJob(worker.Client(), addr, "secret", job); !strings.HasPrefix(r.Error, "503") {
+		t.Errorf("a job while another runs: %+v", r)
+	}
+	if r := <-busy; r.Error != "" {
+		t.Errorf("the running job: %+v", r)
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:7070": true,
+		"[::1]:7070":     true,
+		"localhost:7070": true,
+		":7070":          false,
+		"0.0.0.0:7070":   false,
+		"10.0.0.5:7070":  false,
+		"example.com:80": false,
+	} {
+		if got := loopbackAddr(addr); got != want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
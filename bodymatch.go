@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// bodyMatchMax is how much of the body -body-match scans.
+const bodyMatchMax = 16 << 20
+
+// BodyMatch is the result of matching the body against -body-match.
+type BodyMatch struct {
+	Pattern string
+	Matched bool
+	// Groups are the capture groups of the first match, and Named
+	// those of them with names.
+	Groups []string          `json:",omitempty"`
+	Named  map[string]string `json:",omitempty"`
+}
+
+// regexpFlag is a flag holding a compiled regular expression.
+type regexpFlag struct{ *regexp.Regexp }
+
+func (r regexpFlag) String() string {
+	if r.Regexp == nil {
+		return ""
+	}
+	return r.Regexp.String()
+}
+
+func (r *regexpFlag) Set(v string) error {
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	r.Regexp = re
+	return nil
+}
+
+// matchBody matches body against re.
+func matchBody(re *regexp.Regexp, body []byte) *BodyMatch {
+	m := &BodyMatch{Pattern: re.String()}
+	sm := re.FindSubmatch(body)
+	if sm == nil {
+		return m
+	}
+	m.Matched = true
+	for i, g := range sm[1:] {
+		m.Groups = append(m.Groups, string(g))
+		if name := re.SubexpNames()[i+1]; name != "" {
+			if m.Named == nil {
+				m.Named = map[string]string{}
+			}
+			m.Named[name] = string(g)
+		}
+	}
+	return m
+}
+
+func printBodyMatch(m *BodyMatch) {
+	label := labelString
+	if !m.Matched {
+		printf("\n%s %s\n", label("Body match:"), errorString("no match for %s", m.Pattern))
+		return
+	}
+	groups := ""
+	if len(m.Groups) > 0 {
+		groups = " " + label("(%s)", strings.Join(m.Groups, ", "))
+	}
+	printf("\n%s %s%s\n", label("Body match:"), okString("matched"), groups)
+}
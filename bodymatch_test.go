@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestMatchBody(t *testing.T) {
+	re := regexp.MustCompile(`build=(\w+) (?P<env>\w+)`)
+	got := matchBody(re, []byte("version 2\nbuild=abc123 prod\n"))
+	want := &BodyMatch{
+		Pattern: re.String(),
+		Matched: true,
+		Groups:  []string{"abc123", "prod"},
+		Named:   map[string]string{"env": "prod"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if m := matchBody(re, []byte("nothing here")); m.Matched || m.Groups != nil {
+		t.Errorf("unexpected match %+v", m)
+	}
+}
+
+func TestRegexpFlag(t *testing.T) {
+	var r regexpFlag
+	if r.String() != "" {
+		t.Errorf("unset flag is %q", r.String())
+	}
+	if err := r.Set("a(b"); err == nil {
+		t.Errorf("invalid expression accepted")
+	}
+	if err := r.Set(`a\d`); err != nil || r.String() != `a\d` {
+		t.Errorf("Set: %v, %q", err, r.String())
+	}
+}
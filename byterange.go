@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RangeInfo reports how the server answered a -range request.
+type RangeInfo struct {
+	Requested string
+	// Partial is whether the server sent just the range, with a 206.
+	Partial      bool
+	ContentRange string `json:",omitempty"`
+}
+
+// parseByteRanges validates a list of byte ranges as in a Range header,
+// such as 0-1023, 1024- or -500 for the last 500 bytes.
+func parseByteRanges(s string) error {
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		first, last, ok := strings.Cut(r, "-")
+		if !ok || (first == "" && last == "") {
+			return fmt.Errorf("invalid byte range %q, want FIRST-LAST, FIRST- or -SUFFIX", r)
+		}
+		var a, b int64 = 0, -1
+		var err error
+		if first != "" {
+			if a, err = strconv.ParseInt(first, 10, 64); err != nil || a < 0 {
+				return fmt.Errorf("invalid byte range %q", r)
+			}
+		}
+		if last != "" {
+			if b, err = strconv.ParseInt(last, 10, 64); err != nil || b < 0 {
+				return fmt.Errorf("invalid byte range %q", r)
+			}
+		}
+		if first != "" && last != "" && b < a {
+			return fmt.Errorf("invalid byte range %q, it ends before it starts", r)
+		}
+	}
+	return nil
+}
+
+// checkRange reports whether resp answers the byte ranges requested.
+func checkRange(resp *http.Response, requested string) *RangeInfo {
+	return &RangeInfo{
+		Requested:    requested,
+		Partial:      resp.StatusCode == http.StatusPartialContent,
+		ContentRange: resp.Header.Get("Content-Range"),
+	}
+}
+
+func printRange(r *RangeInfo, status string) {
+	label := labelString
+	if !r.Partial {
+		printf("\n%s %s\n", label("Range:"), warnString("%s not honored, server sent %s", r.Requested, status))
+		return
+	}
+	got := "multipart/byteranges"
+	if r.ContentRange != "" {
+		got = r.ContentRange
+	}
+	printf("\n%s %s %s\n", label("Range:"), okString(got), label("(asked for %s)", r.Requested))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseByteRanges(t *testing.T) {
+	for _, s := range []string{"0-1023", "1024-", "-500", "0-0", "0-99, 200-299"} {
+		if err := parseByteRanges(s); err != nil {
+			t.Errorf("parseByteRanges(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "-", "100", "a-b", "10-5", "0-9,", "-1-2"} {
+		if err := parseByteRanges(s); err == nil {
+			t.Errorf("parseByteRanges(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCheckRange(t *testing.T) {
+	resp := &http.Response{StatusCode: 206, Header: http.Header{"Content-Range": {"bytes 0-9/100"}}}
+	if r := checkRange(resp, "0-9"); !r.Partial || r.ContentRange != "bytes 0-9/100" {
+		t.Errorf("206 response: got %+v", r)
+	}
+	resp = &http.Response{StatusCode: 200, Header: http.Header{}}
+	if r := checkRange(resp, "0-9"); r.Partial {
+		t.Errorf("200 response: got %+v", r)
+	}
+}
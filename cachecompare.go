@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// cacheBustParam is the query parameter added to make a request miss
+// any cache.
+const cacheBustParam = "httpstat-nocache"
+
+// CacheComparison holds the timing of a cache busting request and a
+// normal one made straight after it.
+type CacheComparison struct {
+	Cold      Timing
+	Warm      Timing
+	ColdCache *CacheInfo `json:",omitempty"`
+	WarmCache *CacheInfo `json:",omitempty"`
+}
+
+// compareCache requests url with a unique query parameter and no-cache
+// headers, then as normal. Both reuse the client's open connection so
+// the difference is in the server processing and transfer phases.
+func compareCache(client *http.Client, url *url.URL) *CacheComparison {
+	busted := *url
+	q := busted.Query()
+	q.Set(cacheBustParam, strconv.FormatInt(time.Now().UnixNano(), 36))
+	busted.RawQuery = q.Encode()
+
+	cold := newRequest(httpMethod, &busted, postBody)
+	cold.Header.Set("Cache-Control", "no-cache")
+	cold.Header.Set("Pragma", "no-cache")
+
+	c := &CacheComparison{}
+	c.Cold, c.ColdCache = timeRequest(client, cold)
+	c.Warm, c.WarmCache = timeRequest(client, newRequest(httpMethod, url, postBody))
+	return c
+}
+
+func timeRequest(client *http.Client, req *http.Request) (Timing, *CacheInfo) {
+	var report Report
+	resp := roundTrip(client, req, &report)
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	report.finish()
+	return report.Timing, detectCache(resp.Header)
+}
+
+func printCacheComparison(c *CacheComparison) {
+	label := labelString
+	status := func(ci *CacheInfo) string {
+		if ci == nil || ci.Status == "" {
+			return "-"
+		}
+		return ci.Status
+	}
+	printf("\n%s\n", label("Cache comparison:          cold       warm"))
+	rows := []struct {
+		name       string
+		cold, warm int
+	}{
+		{"Server Processing", c.Cold.Server, c.Warm.Server},
+		{"Content Transfer ", c.Cold.Transfer, c.Warm.Transfer},
+		{"Total            ", c.Cold.Total, c.Warm.Total},
+	}
+	for _, r := range rows {
+		printf("   %s %s %s %s\n", label(r.name), valueString("%8dms", r.cold), valueString("%8dms", r.warm),
+			label("(%+dms)", r.warm-r.cold))
+	}
+	printf("   %s %s %s\n", label("Cache status     "), valueString("%10s", status(c.ColdCache)), valueString("%10s", status(c.WarmCache)))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy explains how caches may store the response, following
+// the rules of RFC 9111.
+type CachePolicy struct {
+	Cacheable      bool
+	SharedCache    bool // whether shared caches such as CDNs may store it
+	Lifetime       int  // freshness lifetime in seconds
+	Age            int  // current age in seconds
+	Heuristic      bool // lifetime is estimated from Last-Modified
+	Revalidatable  bool // has an ETag or Last-Modified validator
+	MustRevalidate bool
+	Verdict        []string
+}
+
+// heuristicStatuses are the status codes caches may store without
+// explicit freshness information, RFC 9110 section 15.1.
+var heuristicStatuses = map[int]bool{
+	200: true, 203: true, 204: true, 206: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// parseCacheControl returns the Cache-Control directives, lower cased,
+// mapped to their unquoted values.
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range splitQuoted(v, ',') {
+			k, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if k != "" {
+				directives[strings.ToLower(k)] = strings.Trim(val, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func analyzeCachePolicy(status int, h http.Header, now time.Time) *CachePolicy {
+	cc := parseCacheControl(h)
+	p := &CachePolicy{
+		Revalidatable: h.Get("ETag") != "" || h.Get("Last-Modified") != "",
+	}
+	_, p.MustRevalidate = cc["must-revalidate"]
+	if _, ok := cc["proxy-revalidate"]; ok {
+		p.MustRevalidate = true
+	}
+	p.Age, _ = strconv.Atoi(strings.TrimSpace(h.Get("Age")))
+
+	date := now
+	if d, err := http.ParseTime(h.Get("Date")); err == nil {
+		date = d
+	}
+
+	if _, ok := cc["no-store"]; ok {
+		p.Verdict = append(p.Verdict, "not cacheable: no-store")
+		return p
+	}
+	p.Cacheable = true
+	_, private := cc["private"]
+	p.SharedCache = !private
+
+	_, noCache := cc["no-cache"]
+	lifetime := -1
+	if v, ok := cc["s-maxage"]; ok && p.SharedCache {
+		lifetime, _ = strconv.Atoi(v)
+	} else if v, ok := cc["max-age"]; ok {
+		lifetime, _ = strconv.Atoi(v)
+	} else if v := h.Get("Expires"); v != "" {
+		lifetime = 0
+		if exp, err := http.ParseTime(v); err == nil && exp.After(date) {
+			lifetime = int(exp.Sub(date) / time.Second)
+		}
+	}
+
+	_, public := cc["public"]
+	switch {
+	case noCache:
+		lifetime = 0
+	case lifetime == -1 && (heuristicStatuses[status] || public):
+		if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil && lm.Before(date) {
+			// the 10% of the time since modification suggested by
+			// RFC 9111 section 4.2.2.
+			lifetime = int(date.Sub(lm) / 10 / time.Second)
+			p.Heuristic = true
+		}
+	}
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	p.Lifetime = lifetime
+
+	scope := "browser and shared caches"
+	if !p.SharedCache {
+		scope = "browser caches only"
+	}
+	switch {
+	case noCache:
+		p.Verdict = append(p.Verdict, "cacheable by "+scope+", but must be revalidated before every use (no-cache)")
+	case p.Heuristic:
+		p.Verdict = append(p.Verdict, fmt.Sprintf("cacheable by %s for about %s, estimated from Last-Modified as no freshness is given", scope, formatSeconds(lifetime)))
+	case lifetime == 0:
+		p.Verdict = append(p.Verdict, "cacheable by "+scope+", but already stale")
+	default:
+		p.Verdict = append(p.Verdict, fmt.Sprintf("cacheable by %s for %s", scope, formatSeconds(lifetime)))
+		if p.Age > 0 {
+			if remaining := lifetime - p.Age; remaining > 0 {
+				p.Verdict = append(p.Verdict, fmt.Sprintf("already %s old, fresh for another %s", formatSeconds(p.Age), formatSeconds(remaining)))
+			} else {
+				p.Verdict = append(p.Verdict, fmt.Sprintf("already %s old, stale", formatSeconds(p.Age)))
+			}
+		}
+	}
+	if _, ok := cc["immutable"]; ok {
+		p.Verdict = append(p.Verdict, "immutable, browsers won't revalidate it on reload")
+	}
+	if v, ok := cc["stale-while-revalidate"]; ok {
+		p.Verdict = append(p.Verdict, "may be served stale for "+v+"s while revalidating")
+	}
+
+	switch {
+	case p.Revalidatable && h.Get("ETag") != "":
+		p.Verdict = append(p.Verdict, "revalidatable with If-None-Match")
+	case p.Revalidatable:
+		p.Verdict = append(p.Verdict, "revalidatable with If-Modified-Since")
+	default:
+		p.Verdict = append(p.Verdict, "not revalidatable, no ETag or Last-Modified, so it is downloaded again once stale")
+	}
+	if p.MustRevalidate {
+		p.Verdict = append(p.Verdict, "must not be served stale (must-revalidate)")
+	}
+	return p
+}
+
+// formatSeconds formats n seconds in the largest whole unit.
+func formatSeconds(n int) string {
+	switch {
+	case n >= 86400 && n%86400 == 0:
+		return strconv.Itoa(n/86400) + "d"
+	case n >= 3600 && n%3600 == 0:
+		return strconv.Itoa(n/3600) + "h"
+	case n >= 60 && n%60 == 0:
+		return strconv.Itoa(n/60) + "m"
+	default:
+		return strconv.Itoa(n) + "s"
+	}
+}
+
+func printCachePolicy(p *CachePolicy) {
+	printf("\n%s\n", labelString("Cache policy:"))
+	for _, v := range p.Verdict {
+		printf("  %s\n", valueString(v))
+	}
+}
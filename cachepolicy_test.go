@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeCachePolicy(t *testing.T) {
+	now := time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   []string
+	}{
+		{"no-store", 200, http.Header{"Cache-Control": {"no-store, max-age=60"}},
+			[]string{"not cacheable: no-store"}},
+		{"max-age", 200, http.Header{"Cache-Control": {"public, max-age=300"}, "Age": {"60"}, "Etag": {`"abc"`}},
+			[]string{"cacheable by browser and shared caches for 5m", "already 1m old, fresh for another 4m", "revalidatable with If-None-Match"}},
+		{"private", 200, http.Header{"Cache-Control": {"private, max-age=3600, must-revalidate"}},
+			[]string{"cacheable by browser caches only for 1h", "not revalidatable, no ETag or Last-Modified, so it is downloaded again once stale", "must not be served stale (must-revalidate)"}},
+		{"s-maxage", 200, http.Header{"Cache-Control": {"max-age=60, s-maxage=86400"}, "Last-Modified": {date}},
+			[]string{"cacheable by browser and shared caches for 1d", "revalidatable with If-Modified-Since"}},
+		{"no-cache", 200, http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"abc"`}},
+			[]string{"cacheable by browser and shared caches, but must be revalidated before every use (no-cache)", "revalidatable with If-None-Match"}},
+		{"expires", 200, http.Header{"Date": {date}, "Expires": {now.Add(2 * time.Hour).Format(http.TimeFormat)}},
+			[]string{"cacheable by browser and shared caches for 2h", "not revalidatable, no ETag or Last-Modified, so it is downloaded again once stale"}},
+		{"heuristic", 200, http.Header{"Date": {date}, "Last-Modified": {now.Add(-10 * 24 * time.Hour).Format(http.TimeFormat)}},
+			[]string{"cacheable by browser and shared caches for about 1d, estimated from Last-Modified as no freshness is given", "revalidatable with If-Modified-Since"}},
+	}
+
+	for _, test := range tests {
+		got := analyzeCachePolicy(test.status, test.header, now).Verdict
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\nwant %q\n got %q", test.name, test.want, got)
+		}
+	}
+}
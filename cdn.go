@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CacheInfo is the cache status reported by a CDN or proxy cache,
+// normalized to HIT, MISS or STALE.
+type CacheInfo struct {
+	Status string `json:",omitempty"`
+	CDN    string `json:",omitempty"`
+	POP    string `json:",omitempty"`
+	Age    int    `json:",omitempty"` // seconds
+}
+
+// cacheStatuses normalizes the statuses used by the common CDNs.
+var cacheStatuses = map[string]string{
+	"HIT":             "HIT",
+	"TCP_HIT":         "HIT",
+	"TCP_MEM_HIT":     "HIT",
+	"REFRESHHIT":      "HIT",
+	"REVALIDATED":     "HIT",
+	"MISS":            "MISS",
+	"TCP_MISS":        "MISS",
+	"EXPIRED":         "MISS",
+	"BYPASS":          "MISS",
+	"DYNAMIC":         "MISS",
+	"PASS":            "MISS",
+	"NONE":            "MISS",
+	"STALE":           "STALE",
+	"UPDATING":        "STALE",
+	"TCP_REFRESH_HIT": "HIT",
+}
+
+// popSuffix matches the airport code ending a CF-Ray or Fastly
+// X-Served-By value, eg. 8a1b2c3d4e5f-FRA or cache-fra-eddf8230111-FRA.
+var popSuffix = regexp.MustCompile(`-([A-Z]{3})$`)
+
+// detectCache inspects the X-Cache, CF-Cache-Status, Age, X-Served-By
+// and Via headers, returning nil if none of them indicate a cache.
+func detectCache(h http.Header) *CacheInfo {
+	c := &CacheInfo{}
+
+	if v := h.Get("CF-Cache-Status"); v != "" {
+		c.CDN = "Cloudflare"
+		c.Status = cacheStatuses[strings.ToUpper(v)]
+		if m := popSuffix.FindStringSubmatch(h.Get("CF-Ray")); m != nil {
+			c.POP = m[1]
+		}
+	}
+
+	if v := h.Get("X-Cache"); v != "" && c.Status == "" {
+		// Fastly lists the status at each cache the request passed
+		// through, the last one is the edge nearest the client.
+		parts := strings.Split(v, ",")
+		last := strings.Fields(strings.TrimSpace(parts[len(parts)-1]))
+		if len(last) > 0 {
+			c.Status = cacheStatuses[strings.ToUpper(last[0])]
+		}
+		switch {
+		case strings.Contains(strings.ToLower(v), "cloudfront"):
+			c.CDN = "CloudFront"
+		case strings.HasPrefix(strings.ToUpper(v), "TCP_"):
+			c.CDN = "Akamai"
+		}
+	}
+	if v := h.Get("X-Amz-Cf-Pop"); v != "" {
+		c.CDN = "CloudFront"
+		c.POP = v
+	}
+
+	if v := h.Get("X-Served-By"); v != "" {
+		parts := strings.Split(v, ",")
+		last := strings.TrimSpace(parts[len(parts)-1])
+		if strings.HasPrefix(last, "cache-") {
+			c.CDN = "Fastly"
+			if m := popSuffix.FindStringSubmatch(last); m != nil {
+				c.POP = m[1]
+			}
+		}
+	}
+
+	if v := h.Get("X-Vercel-Cache"); v != "" {
+		c.CDN = "Vercel"
+		c.Status = cacheStatuses[strings.ToUpper(v)]
+		if id := h.Get("X-Vercel-Id"); id != "" {
+			c.POP, _, _ = strings.Cut(id, ":")
+		}
+	}
+
+	if c.CDN == "" {
+		via := strings.ToLower(h.Get("Via"))
+		server := strings.ToLower(h.Get("Server"))
+		switch {
+		case strings.Contains(via, "cloudfront"):
+			c.CDN = "CloudFront"
+		case server == "cloudflare":
+			c.CDN = "Cloudflare"
+		case strings.Contains(via, "google"):
+			c.CDN = "Google Cloud CDN"
+		case strings.Contains(server, "akamai"):
+			c.CDN = "Akamai"
+		case strings.Contains(via, "varnish"):
+			c.CDN = "Varnish"
+		}
+	}
+
+	if age, err := strconv.Atoi(strings.TrimSpace(h.Get("Age"))); err == nil {
+		c.Age = age
+		// an Age header means the response came from a cache.
+		if c.Status == "" && age > 0 {
+			c.Status = "HIT"
+		}
+	}
+
+	if c.Status == "" && c.CDN == "" {
+		return nil
+	}
+	return c
+}
+
+func printCacheInfo(c *CacheInfo) {
+	status := c.Status
+	if status == "" {
+		status = "unknown"
+	}
+	var details []string
+	for _, d := range []string{c.CDN, c.POP} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	if c.Age > 0 {
+		details = append(details, "age "+strconv.Itoa(c.Age)+"s")
+	}
+	detail := ""
+	if len(details) > 0 {
+		detail = " " + labelString("("+strings.Join(details, ", ")+")")
+	}
+	printf("\n%s %s%s\n", labelString("Cache:"), valueString(status), detail)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   *CacheInfo
+	}{
+		{"none", http.Header{"Server": {"nginx"}}, nil},
+		{"cloudflare", http.Header{"Cf-Cache-Status": {"EXPIRED"}, "Cf-Ray": {"8a1b2c3d4e5f6a7b-FRA"}, "Server": {"cloudflare"}},
+			&CacheInfo{Status: "MISS", CDN: "Cloudflare", POP: "FRA"}},
+		{"cloudfront", http.Header{"X-Cache": {"Hit from cloudfront"}, "X-Amz-Cf-Pop": {"LHR62-C2"}, "Age": {"42"}},
+			&CacheInfo{Status: "HIT", CDN: "CloudFront", POP: "LHR62-C2", Age: 42}},
+		{"fastly", http.Header{"X-Cache": {"MISS, HIT"}, "X-Served-By": {"cache-iad-kiad7000025-IAD, cache-lhr7342-LHR"}},
+			&CacheInfo{Status: "HIT", CDN: "Fastly", POP: "LHR"}},
+		{"akamai", http.Header{"X-Cache": {"TCP_MISS from a23-45-67-89.deploy.akamaitechnologies.com"}},
+			&CacheInfo{Status: "MISS", CDN: "Akamai"}},
+		{"age only", http.Header{"Age": {"300"}, "Via": {"1.1 varnish"}},
+			&CacheInfo{Status: "HIT", CDN: "Varnish", Age: 300}},
+		{"vercel", http.Header{"X-Vercel-Cache": {"STALE"}, "X-Vercel-Id": {"cdg1::abcde"}},
+			&CacheInfo{Status: "STALE", CDN: "Vercel", POP: "cdg1"}},
+	}
+
+	for _, test := range tests {
+		got := detectCache(test.header)
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("%s: want %+v, got %+v", test.name, test.want, got)
+		}
+	}
+}
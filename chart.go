@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// chartHeight is the number of rows latency charts are drawn in.
+const chartHeight = 8
+
+// latencyChart draws values, in milliseconds, as a bar chart height
+// rows high. Failed requests are drawn as errors, or marked along the
+// bottom if they took no time.
+func latencyChart(values []float64, failed []bool, height int) []string {
+	top := 1.0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	blocks := []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+	lines := make([]string, height)
+	for row := range lines {
+		axis := ""
+		switch row {
+		case 0:
+			axis = fmt.Sprintf("%.0fms", top)
+		case height - 1:
+			axis = "0ms"
+		}
+		var b strings.Builder
+		// the eighths of a row below this one.
+		floor := float64((height - 1 - row) * 8)
+		for i, v := range values {
+			fill := int(math.Round(v/top*float64(height*8) - floor))
+			bar := valueString
+			if failed[i] {
+				bar = errorString
+			}
+			switch {
+			case failed[i] && v == 0 && row == height-1:
+				b.WriteString(errorString(glyph("×", "x")))
+			case fill <= 0:
+				b.WriteString(" ")
+			case asciiOutput:
+				b.WriteString(bar("#"))
+			default:
+				b.WriteString(bar(blocks[min(fill, 8)-1]))
+			}
+		}
+		lines[row] = labelString("%7s %s", axis, glyph("│", "|")) + b.String()
+	}
+	return lines
+}
+
+// printLatencyChart charts the total time of each request of a run in
+// turn, drawing those answered with an error status as errors.
+func printLatencyChart(reports []Report) {
+	width := 60
+	if terminalWidth > 0 {
+		width = max(terminalWidth-10, 10)
+	}
+	totals := phaseTimes(reports, func(t Timing) int { return t.Total })
+	failed := make([]bool, len(reports))
+	for i, r := range reports {
+		failed[i] = statusCode(r.Status) >= "400"
+	}
+	// runs too long to fit show the slowest request of each stretch.
+	if len(totals) > width {
+		t, f := make([]float64, width), make([]bool, width)
+		for i := range t {
+			lo, hi := i*len(totals)/width, (i+1)*len(totals)/width
+			for j := lo; j < hi; j++ {
+				t[i] = math.Max(t[i], totals[j])
+				f[i] = f[i] || failed[j]
+			}
+		}
+		totals, failed = t, f
+	}
+
+	printf("\n%s\n", labelString("Total time of each request:"))
+	for _, line := range latencyChart(totals, failed, chartHeight) {
+		printf("%s\n", line)
+	}
+	elapsed := reports[len(reports)-1].start.Sub(reports[0].start).Round(time.Millisecond)
+	axis := fmt.Sprintf("+%s", elapsed)
+	printf("%s\n", labelString("%7s %s%s", "", glyph("└", "+"), strings.Repeat(glyph("─", "-"), len(totals))))
+	printf("%s\n", labelString("%9s%s%*s", "", "0s", max(len(totals)-2, len(axis)+1), axis))
+}
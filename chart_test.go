@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestLatencyChart(t *testing.T) {
+	noColor, ascii := color.NoColor, asciiOutput
+	defer func() { color.NoColor, asciiOutput = noColor, ascii }()
+	color.NoColor, asciiOutput = true, false
+
+	got := latencyChart([]float64{10, 20, 40, 0}, []bool{false, false, false, true}, 4)
+	want := []string{
+		"   40ms │  █ ",
+		"        │  █ ",
+		"        │ ██ ",
+		"    0ms │███×",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// hashBody makes body hash what is read from it, returning the hash.
+func hashBody(body io.ReadCloser) (io.ReadCloser, hash.Hash) {
+	h := sha256.New()
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(body, h), body}, h
+}
+
+// parseSHA256 validates a hex SHA-256 digest, returning it lower case.
+func parseSHA256(s string) (string, error) {
+	s = strings.ToLower(strings.TrimPrefix(s, "sha256:"))
+	if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 digest %q, want 64 hex digits", s)
+	}
+	return s, nil
+}
+
+func printSHA256(sum, expected string) {
+	label := labelString
+	switch {
+	case expected == "":
+		printf("\n%s %s\n", label("SHA-256:"), valueString(sum))
+	case sum == expected:
+		printf("\n%s %s %s\n", label("SHA-256:"), okString(sum), label("(as expected)"))
+	default:
+		printf("\n%s %s %s\n", label("SHA-256:"), errorString(sum), label("(expected %s)", expected))
+	}
+}
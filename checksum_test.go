@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestHashBody(t *testing.T) {
+	body, h := hashBody(ioutil.NopCloser(strings.NewReader("hello")))
+	ioutil.ReadAll(body)
+	const want = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseSHA256(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	for _, s := range []string{sum, strings.ToUpper(sum), "sha256:" + sum} {
+		if got, err := parseSHA256(s); err != nil || got != sum {
+			t.Errorf("parseSHA256(%q) = %q, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "abc", sum[:62], sum + "00", strings.Replace(sum, "2", "g", 1)} {
+		if _, err := parseSHA256(s); err == nil {
+			t.Errorf("parseSHA256(%q) succeeded", s)
+		}
+	}
+}
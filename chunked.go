@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+)
+
+// chunkedRejections are the statuses a server or proxy answers a
+// chunked request body with when it doesn't support them.
+var chunkedRejections = map[int]bool{
+	http.StatusBadRequest:     true,
+	http.StatusLengthRequired: true,
+	http.StatusNotImplemented: true,
+}
+
+// ChunkedUpload reports how a request body sent without a
+// Content-Length with -chunked was handled.
+type ChunkedUpload struct {
+	Proto    string
+	Accepted bool
+	Status   string
+}
+
+// setChunked makes req send its body with chunked transfer encoding.
+func setChunked(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+}
+
+func checkChunked(resp *http.Response) *ChunkedUpload {
+	return &ChunkedUpload{
+		Proto:    resp.Proto,
+		Accepted: !chunkedRejections[resp.StatusCode],
+		Status:   resp.Status,
+	}
+}
+
+func printChunkedUpload(c *ChunkedUpload) {
+	label := labelString
+	how := "chunked"
+	if c.Proto == "HTTP/2.0" {
+		// HTTP/2 frames the body itself, there's no chunked coding.
+		how = "HTTP/2 DATA frames"
+	}
+	if c.Accepted {
+		printf("\n%s %s %s\n", label("Chunked Upload:"), okString("accepted"), label("(%s, no Content-Length)", how))
+		return
+	}
+	printf("\n%s %s %s\n", label("Chunked Upload:"), errorString("rejected with %s", c.Status), label("(%s, no Content-Length)", how))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetChunked(t *testing.T) {
+	var te []string
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		te = r.TransferEncoding
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		if r.Header.Get("Content-Length") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+	setChunked(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(te) != 1 || te[0] != "chunked" || body != "payload" {
+		t.Errorf("server got Transfer-Encoding %q and body %q", te, body)
+	}
+	if c := checkChunked(resp); !c.Accepted || c.Proto != "HTTP/1.1" {
+		t.Errorf("checkChunked = %+v, want accepted", c)
+	}
+}
+
+func TestCheckChunkedRejected(t *testing.T) {
+	resp := &http.Response{Proto: "HTTP/1.1", StatusCode: 411, Status: "411 Length Required"}
+	if c := checkChunked(resp); c.Accepted {
+		t.Errorf("checkChunked = %+v, want rejected", c)
+	}
+}
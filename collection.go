@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// postmanCollection is a Postman collection, format v2.0 or v2.1.
+type postmanCollection struct {
+	Info struct {
+		Name string `json:"name"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+	Auth     *postmanAuth      `json:"auth"`
+}
+
+// postmanItem is a request, or a folder of further items.
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Request *postmanRequest `json:"request"`
+	Auth    *postmanAuth    `json:"auth"` // of a folder
+}
+
+type postmanRequest struct {
+	Method string           `json:"method"`
+	URL    postmanURL       `json:"url"`
+	Header []postmanKeyPair `json:"header"`
+	Body   *struct {
+		Mode       string           `json:"mode"`
+		Raw        string           `json:"raw"`
+		URLEncoded []postmanKeyPair `json:"urlencoded"`
+		FormData   []postmanKeyPair `json:"formdata"`
+		GraphQL    *struct {
+			Query     string `json:"query"`
+			Variables string `json:"variables"`
+		} `json:"graphql"`
+		Options struct {
+			Raw struct {
+				Language string `json:"language"`
+			} `json:"raw"`
+		} `json:"options"`
+	} `json:"body"`
+	Auth *postmanAuth `json:"auth"`
+}
+
+// postmanURL is a URL, given as a string or an object with the string
+// as its raw member.
+type postmanURL string
+
+func (u *postmanURL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*u = postmanURL(s)
+		return nil
+	}
+	var o struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return err
+	}
+	*u = postmanURL(o.Raw)
+	return nil
+}
+
+type postmanKeyPair struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type"` // text or file, for form data
+	Src      string `json:"src"`  // the file of form data
+	Disabled bool   `json:"disabled"`
+}
+
+// postmanVariable is a collection variable, or one of an environment,
+// which are disabled by enabled being false.
+type postmanVariable struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// postmanAuth holds the settings of a type of authorization, such as
+// bearer's token.
+type postmanAuth struct {
+	Type   string           `json:"type"`
+	Bearer []postmanKeyPair `json:"bearer"`
+	Basic  []postmanKeyPair `json:"basic"`
+	APIKey []postmanKeyPair `json:"apikey"`
+}
+
+func (a *postmanAuth) get(pairs []postmanKeyPair, key string) string {
+	for _, p := range pairs {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// header returns the header the authorization is sent in, if it's one
+// that can be.
+func (a *postmanAuth) header() (string, bool) {
+	switch a.Type {
+	case "bearer":
+		return "Authorization: Bearer " + a.get(a.Bearer, "token"), true
+	case "basic":
+		creds := a.get(a.Basic, "username") + ":" + a.get(a.Basic, "password")
+		return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(creds)), true
+	case "apikey":
+		if a.get(a.APIKey, "in") != "query" {
+			return a.get(a.APIKey, "key") + ": " + a.get(a.APIKey, "value"), true
+		}
+	}
+	return "", false
+}
+
+// collectionRequest is a request of a collection, with the authorization
+// it inherits.
+type collectionRequest struct {
+	name string // with the folders it's in
+	*postmanRequest
+	auth *postmanAuth
+}
+
+// collectionRequests returns the requests of items in order, naming
+// each after the folders it's in.
+func collectionRequests(items []postmanItem, prefix string, auth *postmanAuth) []collectionRequest {
+	var reqs []collectionRequest
+	for _, item := range items {
+		name := prefix + item.Name
+		a := auth
+		if item.Auth != nil {
+			a = item.Auth
+		}
+		if item.Request != nil {
+			if item.Request.Auth != nil {
+				a = item.Request.Auth
+			}
+			reqs = append(reqs, collectionRequest{name, item.Request, a})
+		}
+		reqs = append(reqs, collectionRequests(item.Item, name+" / ", a)...)
+	}
+	return reqs
+}
+
+// postmanDynamic translates the Postman dynamic variables that have
+// template placeholders.
+var postmanDynamic = strings.NewReplacer(
+	"{{$guid}}", "{{uuid}}",
+	"{{$randomUUID}}", "{{uuid}}",
+	"{{$randomInt}}", "{{rand 1001}}",
+)
+
+// readPostmanVariables reads the enabled variables of vars into m.
+func readPostmanVariables(m map[string]string, vars []postmanVariable) {
+	for _, v := range vars {
+		if v.Enabled == nil || *v.Enabled {
+			m[v.Key] = v.Value
+		}
+	}
+}
+
+// setCollectionRequest sets up the flags to make the request r, with
+// headers added to base.
+func setCollectionRequest(r collectionRequest, base headers) error {
+	httpMethod = strings.ToUpper(r.Method)
+	if httpMethod == "" {
+		httpMethod = "GET"
+	}
+	httpHeaders = append(headers(nil), base...)
+	postBody, formData, graphqlQuery = "", nil, ""
+
+	if r.auth != nil {
+		if h, ok := r.auth.header(); ok {
+			httpHeaders = append(httpHeaders, postmanDynamic.Replace(h))
+		}
+	}
+	for _, h := range r.Header {
+		if !h.Disabled {
+			httpHeaders = append(httpHeaders, h.Key+": "+postmanDynamic.Replace(h.Value))
+		}
+	}
+
+	if r.Body == nil {
+		return nil
+	}
+	switch r.Body.Mode {
+	case "raw":
+		postBody = postmanDynamic.Replace(r.Body.Raw)
+		if r.Body.Options.Raw.Language == "json" && !httpHeaders.has("Content-Type") {
+			httpHeaders = append(httpHeaders, "Content-Type: application/json")
+		}
+	case "urlencoded":
+		form := url.Values{}
+		for _, p := range r.Body.URLEncoded {
+			if !p.Disabled {
+				form.Add(p.Key, postmanDynamic.Replace(p.Value))
+			}
+		}
+		postBody = form.Encode()
+		if !httpHeaders.has("Content-Type") {
+			httpHeaders = append(httpHeaders, "Content-Type: application/x-www-form-urlencoded")
+		}
+	case "formdata":
+		for _, p := range r.Body.FormData {
+			if p.Disabled {
+				continue
+			}
+			field := p.Key + "=" + p.Value
+			if p.Type == "file" {
+				field = p.Key + "=@" + p.Src
+			}
+			if err := formData.Set(field); err != nil {
+				return err
+			}
+		}
+	case "graphql":
+		if r.Body.GraphQL != nil {
+			body, err := graphqlBody(r.Body.GraphQL.Query, r.Body.GraphQL.Variables)
+			if err != nil {
+				return err
+			}
+			postBody = body
+			if !httpHeaders.has("Content-Type") {
+				httpHeaders = append(httpHeaders, "Content-Type: application/json")
+			}
+		}
+	}
+	if strings.HasPrefix(postBody, "@") {
+		return fmt.Errorf("a body starting with @ would be read as a file")
+	}
+	return nil
+}
+
+// runCollection is the collection subcommand, which makes each request
+// of a Postman collection in turn.
+func runCollection(args []string) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s collection [OPTIONS] COLLECTION\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Make each request of a Postman collection in turn, timing each as httpstat does a URL.")
+		fmt.Fprintln(os.Stderr, "{{variables}} are taken from the collection and -environment.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		flag.PrintDefaults()
+	}
+	envFile := flag.String("environment", "", "read variables from this exported Postman environment")
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var c postmanCollection
+	b, err := ioutil.ReadFile(flag.Arg(0))
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		log.Fatalf("unable to read collection: %v", err)
+	}
+	vars := map[string]string{}
+	readPostmanVariables(vars, c.Variable)
+	if *envFile != "" {
+		var env struct {
+			Values []postmanVariable `json:"values"`
+		}
+		b, err := ioutil.ReadFile(*envFile)
+		if err == nil {
+			err = json.Unmarshal(b, &env)
+		}
+		if err != nil {
+			log.Fatalf("unable to read environment: %v", err)
+		}
+		readPostmanVariables(vars, env.Values)
+	}
+	templateVars = vars
+
+	reqs := collectionRequests(c.Item, "", c.Auth)
+	if len(reqs) == 0 {
+		log.Fatal("no requests in the collection")
+	}
+	configure(expandTemplate(postmanDynamic.Replace(string(reqs[0].URL))))
+	base := httpHeaders
+	numRequests = 1
+
+	type result struct {
+		name, status, err string
+		total             int
+	}
+	var results []result
+	for i, r := range reqs {
+		if i > 0 {
+			time.Sleep(requestDelay)
+		}
+		target := expandTemplate(postmanDynamic.Replace(string(r.URL)))
+		printf("\n%s %s\n", headerString(r.name), labelString("%s %s", r.Method, target))
+		res := result{name: r.name}
+		err := setCollectionRequest(r, base)
+		if err == nil {
+			redirectsFollowed = 0
+			var reports []Report
+			reports, err = tryVisit(parseURL(target))
+			if len(reports) > 0 {
+				res.status, res.total = reports[0].Status, reports[0].micros.Total
+			}
+		}
+		if err != nil {
+			res.err = err.Error()
+			printf("%s\n", errorString(res.err))
+			exitStatus = 1
+		}
+		results = append(results, res)
+	}
+
+	printf("\n%s\n", labelString("Ran %d requests of %s:", len(results), c.Info.Name))
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.name))
+	}
+	for _, r := range results {
+		switch {
+		case r.err != "":
+			printf("   %-*s %s\n", width, r.name, errorString(r.err))
+		case statusCode(r.status) >= "400":
+			printf("   %-*s %s %s\n", width, r.name, errorString(r.status), valueString(formatTiming(r.total)))
+		default:
+			printf("   %-*s %s %s\n", width, r.name, statusString(r.status), valueString(formatTiming(r.total)))
+		}
+	}
+	closeKeyLog()
+	os.Exit(exitStatus)
+}
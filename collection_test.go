@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testCollection = `{
+  "info": {"name": "API"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+  "item": [
+    {"name": "Home", "request": {"method": "GET", "url": "{{base}}/"}},
+    {"name": "Users", "auth": {"type": "basic", "basic": [{"key": "username", "value": "u"}, {"key": "password", "value": "p"}]}, "item": [
+      {"name": "Create", "request": {
+        "method": "post",
+        "url": {"raw": "{{base}}/users", "host": ["{{base}}"]},
+        "header": [{"key": "X-Id", "value": "{{$guid}}"}, {"key": "X-Off", "value": "1", "disabled": true}],
+        "body": {"mode": "raw", "raw": "{\"name\": \"{{name}}\"}", "options": {"raw": {"language": "json"}}}
+      }},
+      {"name": "Search", "request": {
+        "method": "POST",
+        "url": "{{base}}/search",
+        "auth": {"type": "apikey", "apikey": [{"key": "key", "value": "X-Key"}, {"key": "value", "value": "k"}]},
+        "body": {"mode": "urlencoded", "urlencoded": [{"key": "q", "value": "a b"}, {"key": "off", "value": "1", "disabled": true}]}
+      }}
+    ]}
+  ]
+}`
+
+func TestCollectionRequests(t *testing.T) {
+	var c postmanCollection
+	if err := json.Unmarshal([]byte(testCollection), &c); err != nil {
+		t.Fatal(err)
+	}
+	reqs := collectionRequests(c.Item, "", c.Auth)
+
+	var names, urls, auths []string
+	for _, r := range reqs {
+		names = append(names, r.name)
+		urls = append(urls, string(r.URL))
+		auths = append(auths, r.auth.Type)
+	}
+	if want := []string{"Home", "Users / Create", "Users / Search"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	if want := []string{"{{base}}/", "{{base}}/users", "{{base}}/search"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("URLs = %q, want %q", urls, want)
+	}
+	if want := []string{"bearer", "basic", "apikey"}; !reflect.DeepEqual(auths, want) {
+		t.Errorf("auths = %q, want %q", auths, want)
+	}
+}
+
+func TestSetCollectionRequest(t *testing.T) {
+	defer func(m string, h headers, b string, f formFields, q string) {
+		httpMethod, httpHeaders, postBody, formData, graphqlQuery = m, h, b, f, q
+	}(httpMethod, httpHeaders, postBody, formData, graphqlQuery)
+
+	var c postmanCollection
+	if err := json.Unmarshal([]byte(testCollection), &c); err != nil {
+		t.Fatal(err)
+	}
+	reqs := collectionRequests(c.Item, "", c.Auth)
+	base := headers{"Accept: */*"}
+
+	if err := setCollectionRequest(reqs[1], base); err != nil {
+		t.Fatal(err)
+	}
+	want := headers{"Accept: */*", "Authorization: Basic dTpw", "X-Id: {{uuid}}", "Content-Type: application/json"}
+	if httpMethod != "POST" || !reflect.DeepEqual(httpHeaders, want) || postBody != `{"name": "{{name}}"}` {
+		t.Errorf("Create: %s %q %q", httpMethod, httpHeaders, postBody)
+	}
+
+	if err := setCollectionRequest(reqs[2], base); err != nil {
+		t.Fatal(err)
+	}
+	want = headers{"Accept: */*", "X-Key: k", "Content-Type: application/x-www-form-urlencoded"}
+	if !reflect.DeepEqual(httpHeaders, want) || postBody != "q=a+b" {
+		t.Errorf("Search: %q %q", httpHeaders, postBody)
+	}
+
+	if err := setCollectionRequest(reqs[0], base); err != nil {
+		t.Fatal(err)
+	}
+	want = headers{"Accept: */*", "Authorization: Bearer {{token}}"}
+	if httpMethod != "GET" || !reflect.DeepEqual(httpHeaders, want) || postBody != "" {
+		t.Errorf("Home: %s %q %q", httpMethod, httpHeaders, postBody)
+	}
+	if len(base) != 1 {
+		t.Errorf("base headers changed to %q", base)
+	}
+}
+
+func TestReadPostmanVariables(t *testing.T) {
+	var vars []postmanVariable
+	if err := json.Unmarshal([]byte(`[{"key": "a", "value": "1"}, {"key": "b", "value": "2", "enabled": false}, {"key": "c", "value": "3", "enabled": true}]`), &vars); err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]string{"b": "0"}
+	readPostmanVariables(m, vars)
+	if want := map[string]string{"a": "1", "b": "0", "c": "3"}; !reflect.DeepEqual(m, want) {
+		t.Errorf("variables = %v, want %v", m, want)
+	}
+}
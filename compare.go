@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// significance is the p-value below which compare calls a difference
+// significant.
+const significance = 0.05
+
+// PhaseComparison compares the times two URLs took in a phase.
+type PhaseComparison struct {
+	Phase  string
+	A, B   float64 // the median milliseconds
+	Change float64 // the fraction B is slower than A, negative when faster
+	P      float64 // the p-value of the difference
+}
+
+func (c PhaseComparison) significant() bool {
+	return c.P < significance
+}
+
+// comparePhases compares the time each phase took in the reports of
+// requests to A and to B.
+func comparePhases(a, b []Report) []PhaseComparison {
+	var cs []PhaseComparison
+	for _, p := range summaryPhases {
+		ta, tb := phaseTimes(a, p.timed), phaseTimes(b, p.timed)
+		c := PhaseComparison{
+			Phase: p.name,
+			A:     newStats(ta).P50,
+			B:     newStats(tb).P50,
+			P:     mannWhitney(ta, tb),
+		}
+		if c.A > 0 {
+			c.Change = (c.B - c.A) / c.A
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test
+// of whether a and b come from the same distribution. It makes no
+// assumption about the shape of the distribution, which for latencies
+// is rarely normal, and uses the normal approximation of U corrected
+// for ties.
+func mannWhitney(a, b []float64) float64 {
+	type sample struct {
+		v     float64
+		fromA bool
+	}
+	all := make([]sample, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range b {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// rank the samples, giving ties the average of their ranks.
+	var rankA, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for _, s := range all[i:j] {
+			if s.fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	n := n1 + n2
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	u := rankA - n1*(n1+1)/2
+	sigma := math.Sqrt(n1 * n2 / 12 * (n + 1 - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := math.Max(math.Abs(u-n1*n2/2)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// compareRounds times rounds requests to each of a and b, taking turns.
+func compareRounds(a, b *url.URL, rounds int) (ra, rb []Report) {
+	numRequests, summaryOnly = 1, true
+	out := color.Output
+	defer func() { color.Output = out }()
+	// each visit follows its own redirects, up to the maximum.
+	visitOnce := func(u *url.URL) []Report {
+		redirectsFollowed = 0
+		return visit(u)
+	}
+	for i := 0; i < rounds; i++ {
+		if i > 0 {
+			time.Sleep(requestDelay)
+		}
+		color.Output = ioutil.Discard
+		// take turns going first, so neither URL gains from the other
+		// having warmed up a shared route or resolver.
+		if i%2 == 0 {
+			ra = append(ra, visitOnce(a)...)
+			rb = append(rb, visitOnce(b)...)
+		} else {
+			rb = append(rb, visitOnce(b)...)
+			ra = append(ra, visitOnce(a)...)
+		}
+	}
+	return ra, rb
+}
+
+// runCompare is the compare subcommand, which times requests to two
+// URLs in turn and compares their phases.
+func runCompare(args []string) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s compare [OPTIONS] URL1 URL2\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Time -n requests to each URL, taking turns, and compare the phases of the two.")
+		fmt.Fprintln(os.Stderr, "Credentials are only sent to URL1, unless both are on the same host.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	a := configure(flag.Arg(0))
+	b := parseURL(flag.Arg(1))
+
+	ra, rb := compareRounds(a, b, numRequests)
+	printf("\n%s\n", labelString("Compared %d requests to each, taking turns:", len(ra)))
+	printComparison(a.String(), b.String(), ra, rb, a.Scheme == "https" || b.Scheme == "https")
+	closeKeyLog()
+	os.Exit(exitStatus)
+}
+
+// printComparison prints the phases of the requests to A and B side by
+// side. With a single request to either, there's no telling whether a
+// difference is significant.
+func printComparison(a, b string, ra, rb []Report, showTLS bool) {
+	label := labelString
+	for _, t := range []struct {
+		name, target string
+		reports      []Report
+	}{{"A", a, ra}, {"B", b, rb}} {
+		s := summarize(t.reports)
+		statuses := make([]string, 0, len(s.Status))
+		for status, n := range s.Status {
+			statuses = append(statuses, valueString("%d %s %s", n, glyph("×", "x"), status))
+		}
+		sort.Strings(statuses)
+		printf("   %s %s %s\n", headerString(t.name), valueString(t.target), strings.Join(statuses, label(", ")))
+	}
+
+	sampled := len(ra) > 1 && len(rb) > 1
+	heading := fmt.Sprintf("\n   %-19s %s %s %s", "", label("%10s", "A median"), label("%10s", "B median"), label("%16s", "change"))
+	if !sampled {
+		heading = fmt.Sprintf("\n   %-19s %s %s %s", "", label("%10s", "A"), label("%10s", "B"), label("%16s", "change"))
+	} else {
+		heading += " " + label("%8s", "p-value")
+	}
+	printf("%s\n", heading)
+
+	var total PhaseComparison
+	for _, c := range comparePhases(ra, rb) {
+		if c.Phase == "TLS Handshake" && !showTLS {
+			continue
+		}
+		change := fmt.Sprintf("%+.1fms", c.B-c.A)
+		if c.A > 0 {
+			change += fmt.Sprintf(" %+4.0f%%", c.Change*100)
+		}
+		line := fmt.Sprintf("   %s %s %s %s", label("%-19s", c.Phase+":"),
+			valueString("%8.1fms", c.A), valueString("%8.1fms", c.B), valueString("%16s", change))
+		if sampled {
+			line += " " + valueString("%8.3f", c.P)
+			if c.significant() {
+				line += "  " + warnString("significant")
+			}
+		}
+		printf("%s\n", line)
+		total = c
+	}
+
+	diff := fmt.Sprintf("%.1fms", math.Abs(total.B-total.A))
+	if total.A > 0 {
+		diff += fmt.Sprintf(" (%.0f%%)", math.Abs(total.Change)*100)
+	}
+	switch {
+	case sampled && !total.significant():
+		printf("%s\n", label("No significant difference in total time."))
+	case total.B == total.A:
+		printf("%s\n", label("No difference in total time."))
+	case total.B < total.A:
+		printf("%s\n", okString("B is faster in total by %s.", diff))
+	default:
+		printf("%s\n", errorString("B is slower in total by %s.", diff))
+	}
+}
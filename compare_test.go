@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMannWhitney(t *testing.T) {
+	for _, tt := range []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.0122},
+		{[]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 0.0122},
+		{[]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 0.6761},
+		{[]float64{5, 5, 5}, []float64{5, 5, 5}, 1},
+		{[]float64{5}, nil, 1},
+	} {
+		if got := mannWhitney(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("mannWhitney(%v, %v) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestComparePhases(t *testing.T) {
+	var a, b []Report
+	for i := 0; i < 8; i++ {
+		a = append(a, Report{Timing: Timing{DNS: 2, Server: 100 + i, Total: 102 + i}})
+		b = append(b, Report{Timing: Timing{DNS: 2, Server: 50 + i, Total: 52 + i}})
+	}
+	for _, c := range comparePhases(a, b) {
+		switch c.Phase {
+		case "DNS Lookup":
+			if c.Change != 0 || c.significant() {
+				t.Errorf("%s: %+v", c.Phase, c)
+			}
+		case "Server Processing":
+			if c.A != 103 || c.B != 53 || !c.significant() || math.Abs(c.Change+50.0/103) > 1e-9 {
+				t.Errorf("%s: %+v", c.Phase, c)
+			}
+		}
+	}
+}
+
+func TestCompareRedirects(t *testing.T) {
+	defer func(f bool, n int, s bool, d time.Duration) {
+		followRedirects, numRequests, summaryOnly, requestDelay = f, n, s, d
+	}(followRedirects, numRequests, summaryOnly, requestDelay)
+	followRedirects, requestDelay = true, 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer ts.Close()
+	a, _ := url.Parse(ts.URL + "/old")
+	b, _ := url.Parse(ts.URL + "/new")
+
+	// more redirects in all than a single visit may follow.
+	ra, rb := compareRounds(a, b, maxRedirects+2)
+	if len(ra) != maxRedirects+2 || len(rb) != maxRedirects+2 {
+		t.Errorf("got %d and %d reports, want %d of each", len(ra), len(rb), maxRedirects+2)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding is sent with -compressed.
+const acceptEncoding = "gzip, br, zstd"
+
+// Compression describes a compressed response body.
+type Compression struct {
+	Encoding     string
+	WireBytes    int64
+	DecodedBytes int64
+	Decode       int // milliseconds spent decompressing
+}
+
+// decoder returns a reader decompressing r according to the given
+// Content-Encoding, or false if the encoding isn't supported.
+func decoder(encoding string, r io.Reader) (io.ReadCloser, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		return zr, true, err
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		return zr, true, err
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), true, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, true, err
+		}
+		return zr.IOReadCloser(), true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// encoder returns a writer compressing into w with the given
+// Content-Encoding, or false if the encoding isn't supported.
+func encoder(encoding string, w io.Writer) (io.WriteCloser, bool, error) {
+	switch encoding {
+	case "gzip":
+		return gzip.NewWriter(w), true, nil
+	case "deflate":
+		return zlib.NewWriter(w), true, nil
+	case "br":
+		return brotli.NewWriter(w), true, nil
+	case "zstd":
+		zw, err := zstd.NewWriter(w)
+		return zw, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// setCompressedBody makes the body of req compressed on the fly with
+// the given encoding, sent without a Content-Length as its compressed
+// size isn't known in advance.
+func setCompressedBody(req *http.Request, encoding string) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = compressReader(req.Body, encoding)
+	req.ContentLength = -1
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return compressReader(body, encoding), nil
+		}
+	}
+	req.Header.Set("Content-Encoding", encoding)
+}
+
+// compressReader returns a reader of the contents of r compressed with
+// the given encoding.
+func compressReader(r io.ReadCloser, encoding string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer r.Close()
+		zw, _, err := encoder(encoding, pw)
+		if err == nil {
+			_, err = io.Copy(zw, r)
+			if cerr := zw.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// decodeBody decompresses the body of the given encoding into w,
+// timing the decoding separately from the transfer.
+func decodeBody(w io.Writer, encoding string, body []byte) (*Compression, error) {
+	c := &Compression{Encoding: encoding, WireBytes: int64(len(body))}
+	start := time.Now()
+	r, ok, err := decoder(encoding, bytes.NewReader(body))
+	if !ok {
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s body: %v", encoding, err)
+	}
+	defer r.Close()
+	c.DecodedBytes, err = io.Copy(w, r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s body: %v", encoding, err)
+	}
+	c.Decode = msSince(start)
+	return c, nil
+}
+
+func printCompression(c *Compression) {
+	ratio := 0.0
+	if c.DecodedBytes > 0 {
+		ratio = 100 * float64(c.WireBytes) / float64(c.DecodedBytes)
+	}
+	printf("\n%s %s %s\n", labelString("Content Decoding:"), valueString("%dms", c.Decode),
+		labelString("(%s, %d bytes on the wire, %d decoded, %.1f%%)", c.Encoding, c.WireBytes, c.DecodedBytes, ratio))
+}
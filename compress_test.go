@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecodeBody(t *testing.T) {
+	want := strings.Repeat("httpstat ", 1000)
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"br":   func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+	}
+
+	for encoding, enc := range encoders {
+		var wire bytes.Buffer
+		zw := enc(&wire)
+		io.WriteString(zw, want)
+		zw.Close()
+
+		var got bytes.Buffer
+		c, err := decodeBody(&got, encoding, wire.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", encoding, err)
+			continue
+		}
+		if got.String() != want {
+			t.Errorf("%s: decoded body does not match", encoding)
+		}
+		if c.WireBytes != int64(wire.Len()) || c.DecodedBytes != int64(len(want)) {
+			t.Errorf("%s: want %d wire and %d decoded bytes, got %d and %d",
+				encoding, wire.Len(), len(want), c.WireBytes, c.DecodedBytes)
+		}
+	}
+
+	if _, err := decodeBody(io.Discard, "compress", nil); err == nil {
+		t.Error("want error for unsupported encoding")
+	}
+}
+
+func TestSetCompressedBody(t *testing.T) {
+	want := strings.Repeat("httpstat ", 1000)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader(want))
+		setCompressedBody(req, encoding)
+		if req.ContentLength != -1 || req.Header.Get("Content-Encoding") != encoding {
+			t.Errorf("%s: ContentLength %d, Content-Encoding %q", encoding, req.ContentLength, req.Header.Get("Content-Encoding"))
+		}
+
+		// the body is compressed again for a retry.
+		retry, err := req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, body := range []io.ReadCloser{req.Body, retry} {
+			wire, _ := io.ReadAll(body)
+			var got bytes.Buffer
+			if _, err := decodeBody(&got, encoding, wire); err != nil {
+				t.Errorf("%s: %v", encoding, err)
+			} else if got.String() != want || len(wire) >= len(want) {
+				t.Errorf("%s: %d compressed bytes don't decode to the body", encoding, len(wire))
+			}
+		}
+	}
+}
+
+func TestCorruptCompressedBody(t *testing.T) {
+	defer func(c, r bool) { compressed, recoverFailures = c, r }(compressed, recoverFailures)
+	compressed, recoverFailures = true, true
+
+	var report Report
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": {"gzip"}},
+		Body:       io.NopCloser(strings.NewReader("not gzip")),
+	}
+	defer func() {
+		// the request fails, rather than the run.
+		if _, ok := recover().(*requestError); !ok {
+			t.Error("a corrupt body didn't fail the request")
+		}
+	}()
+	readResponseBody(&http.Request{Method: "GET"}, resp, &report)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configPath is the default config file, config.toml in
+// $XDG_CONFIG_HOME/httpstat or ~/.config/httpstat.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "httpstat", "config.toml")
+}
+
+// dataPath is the path of a file httpstat keeps data in, in
+// $XDG_DATA_HOME/httpstat or ~/.local/share/httpstat.
+func dataPath(elem ...string) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(append([]string{dir, "httpstat"}, elem...)...)
+}
+
+// loadConfig returns the settings for the named profile in the TOML
+// config file. Settings are keyed by flag name; those at the top level
+// apply to every run, and those in a [profile.NAME] table override
+// them when that profile is chosen.
+func loadConfig(filename, profile string) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if _, err := toml.DecodeFile(filename, &config); err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	for k, v := range config {
+		if k != "profile" {
+			settings[k] = v
+		}
+	}
+	if profile == "" {
+		return settings, nil
+	}
+	profiles, _ := config["profile"].(map[string]interface{})
+	p, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no profile %q in %s", profile, filename)
+	}
+	for k, v := range p {
+		settings[k] = v
+	}
+	return settings, nil
+}
+
+// applyConfig sets the flags of fs not given on the command line from
+// the config settings. The proxy setting is used for HTTP_PROXY and
+// HTTPS_PROXY unless they are already set, and the [colors] table sets
+// themeColors.
+func applyConfig(fs *flag.FlagSet, settings map[string]interface{}) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	names := make([]string, 0, len(settings))
+	for k := range settings {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := settings[name]
+		if name == "proxy" {
+			s, ok := v.(string)
+			if !ok {
+				return errors.New("proxy must be a string")
+			}
+			for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY"} {
+				if os.Getenv(env) == "" {
+					os.Setenv(env, s)
+				}
+			}
+			continue
+		}
+
+		if name == "colors" {
+			colors, ok := v.(map[string]interface{})
+			if !ok {
+				return errors.New("colors must be a table of role = \"color\"")
+			}
+			themeColors = make(map[string]string)
+			for role, c := range colors {
+				s, ok := c.(string)
+				if !ok {
+					return fmt.Errorf("colors: %s must be a string", role)
+				}
+				themeColors[role] = s
+			}
+			continue
+		}
+
+		switch name {
+		case "config", "profile", "v":
+			return fmt.Errorf("%s can't be set in the config file", name)
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, value := range values {
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			if given[name] {
+				continue
+			}
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configString formats a TOML value as a flag would be given it.
+func configString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+H = ["User-Agent: httpstat"]
+k = false
+
+[profile.prod]
+k = true
+n = 3
+w = "500ms"
+`
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(filename, []byte(testConfig), 0600)
+
+	settings, err := loadConfig(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["k"] != false || settings["profile"] != nil {
+		t.Errorf("default settings = %v", settings)
+	}
+
+	settings, err = loadConfig(filename, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["k"] != true || settings["n"] != int64(3) || settings["H"] == nil {
+		t.Errorf("prod settings = %v", settings)
+	}
+
+	if _, err := loadConfig(filename, "staging"); err == nil {
+		t.Error("want an error for a missing profile")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	fs := flag.NewFlagSet("httpstat", flag.ContinueOnError)
+	var h headers
+	fs.Var(&h, "H", "")
+	insecure := fs.Bool("k", false, "")
+	n := fs.Int("n", 1, "")
+	w := fs.Duration("w", time.Second, "")
+	fs.Parse([]string{"-n", "5"})
+
+	err := applyConfig(fs, map[string]interface{}{
+		"H": []interface{}{"A: 1", "B: 2"},
+		"k": true,
+		"n": int64(3),
+		"w": "500ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 || !*insecure || *w != 500*time.Millisecond {
+		t.Errorf("got H %q, k %v, w %v", h, *insecure, *w)
+	}
+	if *n != 5 {
+		t.Errorf("n = %d, the command line should win", *n)
+	}
+
+	for _, bad := range []map[string]interface{}{{"nope": "x"}, {"w": "soon"}, {"k": map[string]interface{}{}}} {
+		fs := flag.NewFlagSet("httpstat", flag.ContinueOnError)
+		fs.Bool("k", false, "")
+		fs.Duration("w", time.Second, "")
+		if err := applyConfig(fs, bad); err == nil {
+			t.Errorf("applyConfig(%v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestApplyConfigColors(t *testing.T) {
+	defer func(c map[string]string) { themeColors = c }(themeColors)
+
+	fs := flag.NewFlagSet("httpstat", flag.ContinueOnError)
+	err := applyConfig(fs, map[string]interface{}{
+		"colors": map[string]interface{}{"value": "bold red"},
+	})
+	if err != nil || themeColors["value"] != "bold red" {
+		t.Errorf("got %v, %v", themeColors, err)
+	}
+	if err := applyConfig(fs, map[string]interface{}{"colors": "red"}); err == nil {
+		t.Errorf("colors given as a string accepted")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxCookieLifetime is the longest expiry browsers honour, longer ones
+// are capped, see RFC 6265bis section 5.5.
+const maxCookieLifetime = 400 * 24 * time.Hour
+
+// CookieAudit lists the security problems found with a cookie set by
+// the response.
+type CookieAudit struct {
+	Name   string
+	Issues []string `json:",omitempty"`
+}
+
+// auditCookies checks each Set-Cookie header in h received from u.
+func auditCookies(u *url.URL, h http.Header) []CookieAudit {
+	var audits []CookieAudit
+	host := strings.ToLower(u.Hostname())
+	for _, line := range h.Values("Set-Cookie") {
+		c, err := http.ParseSetCookie(line)
+		if err != nil {
+			audits = append(audits, CookieAudit{Name: line, Issues: []string{"unparsable: " + err.Error()}})
+			continue
+		}
+		audits = append(audits, CookieAudit{Name: c.Name, Issues: cookieIssues(u.Scheme, host, c, time.Now())})
+	}
+	return audits
+}
+
+func cookieIssues(scheme, host string, c *http.Cookie, now time.Time) []string {
+	var issues []string
+	if !c.Secure {
+		issues = append(issues, "missing Secure")
+	} else if scheme != "https" {
+		issues = append(issues, "Secure cookie set over plain HTTP, browsers will reject it")
+	}
+	if !c.HttpOnly {
+		issues = append(issues, "missing HttpOnly")
+	}
+	switch c.SameSite {
+	case 0:
+		issues = append(issues, "missing SameSite")
+	case http.SameSiteNoneMode:
+		if !c.Secure {
+			issues = append(issues, "SameSite=None without Secure, browsers will reject it")
+		}
+	}
+
+	var lifetime time.Duration
+	switch {
+	case c.MaxAge > 0:
+		lifetime = time.Duration(c.MaxAge) * time.Second
+	case c.MaxAge == 0 && !c.Expires.IsZero():
+		lifetime = c.Expires.Sub(now)
+	}
+	if lifetime > maxCookieLifetime {
+		issues = append(issues, fmt.Sprintf("expires in %d days, browsers cap it at 400", int(lifetime.Hours()/24)))
+	}
+
+	if c.Domain != "" {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		switch {
+		case host != domain && !strings.HasSuffix(host, "."+domain):
+			issues = append(issues, fmt.Sprintf("Domain=%s does not match %s, browsers will reject it", c.Domain, host))
+		case isPublicSuffix(domain):
+			issues = append(issues, fmt.Sprintf("Domain=%s is a public suffix, browsers will reject it", c.Domain))
+		default:
+			issues = append(issues, fmt.Sprintf("Domain=%s shares the cookie with every subdomain of %s", c.Domain, domain))
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(c.Name, "__Host-"):
+		if !c.Secure || c.Path != "/" || c.Domain != "" {
+			issues = append(issues, "__Host- prefix requires Secure, Path=/ and no Domain")
+		}
+	case strings.HasPrefix(c.Name, "__Secure-"):
+		if !c.Secure {
+			issues = append(issues, "__Secure- prefix requires Secure")
+		}
+	}
+	return issues
+}
+
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+func printCookieAudit(audits []CookieAudit) {
+	if len(audits) == 0 {
+		printf("\n%s %s\n", labelString("Cookie audit:"), "no cookies set")
+		return
+	}
+	printf("\n%s\n", labelString("Cookie audit:"))
+	for _, a := range audits {
+		if len(a.Issues) == 0 {
+			printf("  %s %s\n", valueString(a.Name+":"), okString("ok"))
+			continue
+		}
+		printf("  %s %s\n", valueString(a.Name+":"), warnString(strings.Join(a.Issues, "; ")))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCookieIssues(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		scheme string
+		cookie string
+		want   []string
+	}{
+		{"https", "sid=1; Secure; HttpOnly; SameSite=Lax", nil},
+		{"https", "sid=1", []string{"missing Secure", "missing HttpOnly", "missing SameSite"}},
+		{"http", "sid=1; Secure; HttpOnly; SameSite=Strict", []string{"Secure cookie set over plain HTTP, browsers will reject it"}},
+		{"https", "sid=1; HttpOnly; SameSite=None", []string{"missing Secure", "SameSite=None without Secure, browsers will reject it"}},
+		{"https", "sid=1; Secure; HttpOnly; SameSite=Lax; Max-Age=63072000", []string{"expires in 730 days, browsers cap it at 400"}},
+		{"https", "sid=1; Secure; HttpOnly; SameSite=Lax; Domain=example.com", []string{"Domain=example.com shares the cookie with every subdomain of example.com"}},
+		{"https", "sid=1; Secure; HttpOnly; SameSite=Lax; Domain=other.org", []string{"Domain=other.org does not match www.example.com, browsers will reject it"}},
+		{"https", "sid=1; Secure; HttpOnly; SameSite=Lax; Domain=com", []string{"Domain=com is a public suffix, browsers will reject it"}},
+		{"https", "__Host-sid=1; Secure; HttpOnly; SameSite=Lax; Path=/app", []string{"__Host- prefix requires Secure, Path=/ and no Domain"}},
+	}
+
+	for _, test := range tests {
+		c, err := http.ParseSetCookie(test.cookie)
+		if err != nil {
+			t.Fatalf("%s: %v", test.cookie, err)
+		}
+		got := cookieIssues(test.scheme, "www.example.com", c, now)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: want %q, got %q", test.cookie, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookieJar is an http.CookieJar which remembers what it stored so it
+// can be saved to a Netscape format cookie file, as used by curl.
+type cookieJar struct {
+	jar     *cookiejar.Jar
+	mu      sync.Mutex // guards entries, for the concurrent requests of -ramp
+	entries map[string]*jarEntry
+}
+
+// jarEntry is one line of a Netscape cookie file.
+type jarEntry struct {
+	domain   string
+	hostOnly bool
+	path     string
+	secure   bool
+	httpOnly bool
+	expires  time.Time // zero for session cookies
+	name     string
+	value    string
+}
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+func newCookieJar() *cookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &cookieJar{jar: jar, entries: make(map[string]*jarEntry)}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, c := range cookies {
+		e := &jarEntry{
+			domain:   host,
+			hostOnly: true,
+			path:     c.Path,
+			secure:   c.Secure,
+			httpOnly: c.HttpOnly,
+			expires:  c.Expires,
+			name:     c.Name,
+			value:    c.Value,
+		}
+		if c.Domain != "" {
+			domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				// the jar rejects cookies for other domains.
+				continue
+			}
+			e.domain, e.hostOnly = domain, false
+		}
+		if !strings.HasPrefix(e.path, "/") {
+			e.path = defaultCookiePath(u.Path)
+		}
+		if c.MaxAge > 0 {
+			e.expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := e.domain + ";" + e.path + ";" + e.name
+		if c.MaxAge < 0 || (!e.expires.IsZero() && !e.expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = e
+	}
+}
+
+// defaultCookiePath is the cookie path for a Set-Cookie without one,
+// RFC 6265 section 5.1.4.
+func defaultCookiePath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.Count(p, "/") == 1 {
+		return "/"
+	}
+	return path.Dir(p)
+}
+
+// loadCookieJar reads the Netscape format cookie file filename into a
+// new jar. A missing file gives an empty jar, it is created on save.
+func loadCookieJar(filename string) (*cookieJar, error) {
+	j := newCookieJar()
+	if err := j.load(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load adds the cookies in the Netscape format cookie file filename
+// to the jar.
+func (j *cookieJar) load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("%s:%d: expected 7 tab separated fields", filename, n)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid expiry %q", filename, n, fields[4])
+		}
+
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   fields[3] == "TRUE",
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+			if !c.Expires.After(now) {
+				continue
+			}
+		}
+		domain := strings.TrimPrefix(fields[0], ".")
+		if fields[1] == "TRUE" {
+			c.Domain = domain
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		j.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: c.Path}, []*http.Cookie{c})
+	}
+	return sc.Err()
+}
+
+// save writes the jar to filename in Netscape cookie file format.
+func (j *cookieJar) save(filename string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]*jarEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].domain != entries[b].domain {
+			return entries[a].domain < entries[b].domain
+		}
+		if entries[a].path != entries[b].path {
+			return entries[a].path < entries[b].path
+		}
+		return entries[a].name < entries[b].name
+	})
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# Written by httpstat, edit at your own risk.\n\n")
+	bools := map[bool]string{true: "TRUE", false: "FALSE"}
+	for _, e := range entries {
+		domain := e.domain
+		if !e.hostOnly {
+			domain = "." + domain
+		}
+		if e.httpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !e.expires.IsZero() {
+			expires = e.expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, bools[!e.hostOnly], e.path, bools[e.secure], expires, e.name, e.value)
+	}
+	return ioutil.WriteFile(filename, []byte(b.String()), 0600)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCookieJarSaveLoad(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/app/login")
+	jar := newCookieJar()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", HttpOnly: true, Secure: true},
+		{Name: "pref", Value: "dark", Domain: "example.com", Path: "/", MaxAge: 3600},
+		{Name: "gone", Value: "x", Expires: time.Now().Add(-time.Hour)},
+		{Name: "other", Value: "x", Domain: "example.org"},
+	})
+
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := jar.save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadCookieJar(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.entries) != 2 {
+		t.Errorf("want 2 cookies saved, got %d", len(loaded.entries))
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://www.example.com/app/x", []string{"session=abc", "pref=dark"}},
+		{"http://www.example.com/app/x", []string{"pref=dark"}},
+		{"https://api.example.com/", []string{"pref=dark"}},
+		{"https://example.org/", nil},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		var got []string
+		for _, c := range loaded.Cookies(u) {
+			got = append(got, c.String())
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: want %v, got %v", test.url, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: want %v, got %v", test.url, test.want, got)
+			}
+		}
+	}
+}
+
+func TestLoadCookieJarMissing(t *testing.T) {
+	jar, err := loadCookieJar(filepath.Join(t.TempDir(), "none.txt"))
+	if err != nil || len(jar.entries) != 0 {
+		t.Errorf("want empty jar, got %v, %v", jar, err)
+	}
+}
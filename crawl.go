@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// crawlAgent is the user agent whose robots.txt rules are followed.
+const crawlAgent = "httpstat"
+
+// sitemapDepth is how deep sitemap indexes may nest.
+const sitemapDepth = 3
+
+// sitemap is a sitemap or sitemap index, which lists further sitemaps.
+type sitemap struct {
+	URLs     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+// crawlClient makes the requests of the crawl subcommand, with
+// connections for each worker and redirects left unfollowed so they
+// are timed on their own.
+func crawlClient(concurrency int) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = concurrency
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+	return &http.Client{
+		Transport: tr,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: maxTime,
+	}
+}
+
+// fetch gets the body of u, decompressing it if it is gzipped as
+// sitemaps often are.
+func fetch(client *http.Client, u string) ([]byte, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	return b, nil
+}
+
+// parseSitemap reads a sitemap or sitemap index.
+func parseSitemap(b []byte) (*sitemap, error) {
+	var s sitemap
+	if err := xml.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("invalid sitemap: %v", err)
+	}
+	for i := range s.URLs {
+		s.URLs[i] = strings.TrimSpace(s.URLs[i])
+	}
+	for i := range s.Sitemaps {
+		s.Sitemaps[i] = strings.TrimSpace(s.Sitemaps[i])
+	}
+	return &s, nil
+}
+
+// sitemapURLs returns the pages listed by the sitemap at u, following
+// sitemap indexes, at most limit of them unless limit is 0.
+func sitemapURLs(client *http.Client, u string, limit, depth int) ([]string, error) {
+	b, err := fetch(client, u)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSitemap(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	urls := s.URLs
+	for _, child := range s.Sitemaps {
+		if limit > 0 && len(urls) >= limit {
+			break
+		}
+		if depth >= sitemapDepth {
+			return nil, fmt.Errorf("%s: sitemap indexes nested too deep", child)
+		}
+		more, err := sitemapURLs(client, child, limit-len(urls), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, more...)
+	}
+	if limit > 0 && len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+// robotsRule is an Allow or Disallow line of robots.txt.
+type robotsRule struct {
+	allow   bool
+	length  int // of the path pattern, the longest matching rule wins
+	pattern *regexp.Regexp
+}
+
+// robots are the rules of a robots.txt for an agent.
+type robots struct {
+	rules []robotsRule
+	delay time.Duration // the Crawl-delay
+}
+
+// parseRobots reads the rules of robots.txt that apply to agent, those
+// of the group naming it, or else those for every agent.
+func parseRobots(r io.Reader, agent string) *robots {
+	groups := map[string]*robots{}
+	var current []string // the agents of the group being read
+	inRules := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if key == "user-agent" {
+			if inRules {
+				current, inRules = nil, false
+			}
+			a := strings.ToLower(value)
+			current = append(current, a)
+			if groups[a] == nil {
+				groups[a] = &robots{}
+			}
+			continue
+		}
+		inRules = true
+		for _, a := range current {
+			g := groups[a]
+			switch key {
+			case "allow", "disallow":
+				if value == "" {
+					continue
+				}
+				g.rules = append(g.rules, robotsRule{key == "allow", len(value), robotsPattern(value)})
+			case "crawl-delay":
+				if d, err := strconv.ParseFloat(value, 64); err == nil {
+					g.delay = time.Duration(d * float64(time.Second))
+				}
+			}
+		}
+	}
+	if g := groups[strings.ToLower(agent)]; g != nil {
+		return g
+	}
+	if g := groups["*"]; g != nil {
+		return g
+	}
+	return &robots{}
+}
+
+// robotsPattern compiles a robots.txt path, in which * matches anything
+// and a final $ the end of the path.
+func robotsPattern(path string) *regexp.Regexp {
+	end := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	parts := strings.Split(path, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if end {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed reports whether the rules allow fetching u.
+func (r *robots) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if rule.length >= longest && rule.pattern.MatchString(path) {
+			// on a tie Allow wins, being the less restrictive.
+			if rule.length > longest || rule.allow {
+				allow = rule.allow
+			}
+			longest = rule.length
+		}
+	}
+	return allow
+}
+
+// crawlRobots fetches the robots.txt rules of each host of urls and
+// leaves out the URLs they disallow, returning the longest Crawl-delay.
+func crawlRobots(client *http.Client, urls []string) (allowed []string, skipped int, delay time.Duration) {
+	hosts := map[string]*robots{}
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			allowed = append(allowed, s)
+			continue
+		}
+		origin := u.Scheme + "://" + u.Host
+		r, ok := hosts[origin]
+		if !ok {
+			r = &robots{}
+			// a missing robots.txt allows everything.
+			if b, err := fetch(client, origin+"/robots.txt"); err == nil {
+				r = parseRobots(bytes.NewReader(b), crawlAgent)
+			}
+			hosts[origin] = r
+			delay = max(delay, r.delay)
+		}
+		if r.allowed(u) {
+			allowed = append(allowed, s)
+		} else {
+			skipped++
+		}
+	}
+	return allowed, skipped, delay
+}
+
+// runCrawl is the crawl subcommand, which times every page of a
+// sitemap.
+func runCrawl(args []string) {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s crawl [OPTIONS] SITEMAP\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Time a request to each page listed in a sitemap, or the sitemaps of a sitemap index, and summarize them.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	concurrency := fs.Int("c", 4, "make this many requests at once")
+	rate := fs.Float64("rate", 0, "make at most this many requests a second; 0 is no limit")
+	limit := fs.Int("limit", 0, "time at most this many pages; 0 is every page")
+	respectRobots := fs.Bool("robots", false, "skip pages robots.txt disallows, and keep to its Crawl-delay")
+	slowest := fs.Int("slowest", 5, "list this many of the slowest pages")
+	fs.BoolVar(&insecure, "k", false, "allow insecure SSL connections")
+	fs.DurationVar(&maxTime, "m", 0, "maximum time each request may take")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+	*concurrency = max(*concurrency, 1)
+
+	client := crawlClient(*concurrency)
+	urls, err := sitemapURLs(client, parseURL(fs.Arg(0)).String(), *limit, 0)
+	if err != nil {
+		log.Fatalf("unable to read sitemap: %v", err)
+	}
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) / *rate)
+	}
+	if *respectRobots {
+		var skipped int
+		var delay time.Duration
+		urls, skipped, delay = crawlRobots(client, urls)
+		if skipped > 0 {
+			printf("%s\n", labelString("Skipped %d disallowed by robots.txt.", skipped))
+		}
+		interval = max(interval, delay)
+	}
+	if len(urls) == 0 {
+		printf("%s\n", warnString("No pages to crawl."))
+		return
+	}
+
+	results := crawl(client, urls, *concurrency, interval, func(r *Resource) {
+		printCrawled(r)
+	})
+	printCrawlSummary(results, *slowest)
+	os.Exit(exitStatus)
+}
+
+// crawl times a request to each of urls, concurrency at a time and at
+// most one every interval, calling done as each completes.
+func crawl(client *http.Client, urls []string, concurrency int, interval time.Duration, done func(*Resource)) []Resource {
+	results := make([]Resource, len(urls))
+	next := make(chan int)
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for i := range urls {
+			if tick != nil && i > 0 {
+				<-tick
+			}
+			next <- i
+		}
+		close(next)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &results[i]
+				r.URL, r.Type = urls[i], "page"
+				fetchResource(client, r, nil, time.Now())
+				mu.Lock()
+				done(r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func printCrawled(r *Resource) {
+	if r.Error != "" {
+		exitStatus = 1
+		printf("%s %s %s\n", errorString("%-3s", "ERR"), errorString("%8s", formatTiming(r.micros.Total)), r.URL)
+		return
+	}
+	status := statusString("%-3s", statusCode(r.Status))
+	if statusCode(r.Status) >= "400" {
+		exitStatus = 1
+	}
+	printf("%s %s %s\n", status, valueString("%8s", formatTiming(r.micros.Total)), r.URL)
+}
+
+func printCrawlSummary(results []Resource, slowest int) {
+	label := labelString
+	statuses := map[string]int{}
+	var ttfb, total []float64
+	var ok []*Resource
+	for i := range results {
+		r := &results[i]
+		if r.Error != "" {
+			statuses["failed"]++
+			continue
+		}
+		statuses[r.Status]++
+		ttfb = append(ttfb, float64(r.micros.StartTransfer)/1000)
+		total = append(total, float64(r.micros.Total)/1000)
+		ok = append(ok, r)
+	}
+
+	counts := make([]string, 0, len(statuses))
+	for status, n := range statuses {
+		s := valueString("%d %s %s", n, glyph("×", "x"), status)
+		if status == "failed" || statusCode(status) >= "400" {
+			s = errorString("%d %s %s", n, glyph("×", "x"), status)
+		}
+		counts = append(counts, s)
+	}
+	sort.Strings(counts)
+	printf("\n%s %s\n", label("Crawled %d pages:", len(results)), strings.Join(counts, label(", ")))
+	if len(ok) == 0 {
+		return
+	}
+	printStats("First byte:", newStats(ttfb))
+	printStats("Total:     ", newStats(total))
+
+	sort.SliceStable(ok, func(i, j int) bool { return ok[i].micros.Total > ok[j].micros.Total })
+	if slowest > 0 {
+		printf("\n%s\n", label("Slowest:"))
+		for _, r := range ok[:min(slowest, len(ok))] {
+			printf("   %s %s\n", valueString("%8s", formatTiming(r.micros.Total)), r.URL)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemapURLs(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/a.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/b.xml.gz</loc></sitemap>
+</sitemapindex>`, srv.URL)
+		case "/a.xml":
+			fmt.Fprint(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/ </loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`)
+		case "/b.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, `<urlset><url><loc>https://example.com/blog</loc></url></urlset>`)
+			gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := crawlClient(1)
+	urls, err := sitemapURLs(client, srv.URL+"/sitemap.xml", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/", "https://example.com/about", "https://example.com/blog"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %q, want %q", urls, want)
+	}
+
+	urls, err = sitemapURLs(client, srv.URL+"/sitemap.xml", 2, 0)
+	if err != nil || len(urls) != 2 {
+		t.Errorf("limited to 2, got %q, %v", urls, err)
+	}
+
+	if _, err := sitemapURLs(client, srv.URL+"/missing.xml", 0, 0); err == nil {
+		t.Error("missing sitemap read")
+	}
+}
+
+func TestRobots(t *testing.T) {
+	txt := `# robots
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+User-agent: httpstat
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow:
+Crawl-delay: 2.5
+`
+	r := parseRobots(strings.NewReader(txt), "HTTPStat")
+	if r.delay != 2500*time.Millisecond {
+		t.Errorf("got crawl delay %v, want 2.5s", r.delay)
+	}
+	for path, want := range map[string]bool{
+		"/":                      true,
+		"/private/":              false,
+		"/private/x":             false,
+		"/private/public/y":      true,
+		"/docs/guide.pdf":        false,
+		"/docs/guide.pdf?inline": true,
+		"/docs/guide.pdfx":       true,
+	} {
+		u, _ := url.Parse("https://example.com" + path)
+		if got := r.allowed(u); got != want {
+			t.Errorf("allowed(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	r = parseRobots(strings.NewReader(txt), "Googlebot")
+	if u, _ := url.Parse("https://example.com/"); r.allowed(u) {
+		t.Error("Googlebot group not applied")
+	}
+	if r := parseRobots(strings.NewReader(""), crawlAgent); len(r.rules) != 0 {
+		t.Error("rules from an empty robots.txt")
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(bytes.Repeat([]byte("x"), 100))
+	}))
+	defer srv.Close()
+
+	urls := []string{srv.URL + "/", srv.URL + "/a", srv.URL + "/missing", "http://127.0.0.1:0/"}
+	var done int
+	results := crawl(crawlClient(2), urls, 2, 0, func(*Resource) { done++ })
+	if done != len(urls) {
+		t.Errorf("done called %d times, want %d", done, len(urls))
+	}
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("result %d is for %s, want %s", i, r.URL, urls[i])
+		}
+	}
+	if results[0].Status != "200 OK" || results[0].Size != 100 {
+		t.Errorf("got %s with %d bytes", results[0].Status, results[0].Size)
+	}
+	if results[2].Status != "404 Not Found" {
+		t.Errorf("got %s for a missing page", results[2].Status)
+	}
+	if results[3].Error == "" {
+		t.Error("no error for an unreachable page")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadDataSource reads the rows of a -data-source file, whose columns
+// become template placeholders. JSON Lines files, named .jsonl, .ndjson
+// or .json, have an object per line; anything else is read as CSV with
+// the column names in the first row.
+func loadDataSource(filename string) ([]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []map[string]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson", ".json":
+		rows, err = readJSONLines(f)
+	default:
+		rows, err = readCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no rows", filename)
+	}
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSONLines(r io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(line, &obj); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			// strings are used unquoted, anything else as JSON.
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				row[k] = s
+			} else {
+				row[k] = string(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, errors.New("line longer than 1MB")
+		}
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDataSource(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content string
+		want          []map[string]string
+	}{
+		{"rows.csv", "id,name\n1,ann\n2,\"bob, jr\"\n", []map[string]string{
+			{"id": "1", "name": "ann"},
+			{"id": "2", "name": "bob, jr"},
+		}},
+		{"rows.jsonl", "{\"id\":1,\"name\":\"ann\"}\n\n{\"id\":2,\"tags\":[\"a\"]}\n", []map[string]string{
+			{"id": "1", "name": "ann"},
+			{"id": "2", "tags": `["a"]`},
+		}},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.name)
+		os.WriteFile(filename, []byte(tt.content), 0600)
+		got, err := loadDataSource(filename)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for name, content := range map[string]string{"empty.csv": "id,name\n", "ragged.csv": "a,b\n1\n", "bad.jsonl": "{\n"} {
+		filename := filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(content), 0600)
+		if _, err := loadDataSource(filename); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestSetSequenceCyclesRows(t *testing.T) {
+	defer func(rows []map[string]string, n int) {
+		dataRows, templateVars = rows, nil
+		requestSeq = n
+	}(dataRows, requestSeq)
+	dataRows = []map[string]string{{"id": "a"}, {"id": "b"}}
+
+	var got []string
+	for n := 1; n <= 3; n++ {
+		setSequence(n)
+		got = append(got, expandTemplate("{{id}}-{{sequence}}"))
+	}
+	if want := []string{"a-1", "b-2", "a-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
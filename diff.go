@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// readReports reads the reports saved in a file: the output of -J, the
+// JSON Lines of -log-file or the history kept by -record.
+func readReports(filename string) ([]Report, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reports []Report
+	d := json.NewDecoder(f)
+	for {
+		// history entries hold the report in a field of its own.
+		var v struct {
+			Report
+			Entry *Report `json:"Report"`
+		}
+		if err := d.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if v.Entry != nil {
+			v.Report = *v.Entry
+		}
+		reports = append(reports, v.Report)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("%s: no reports", filename)
+	}
+	return reports, nil
+}
+
+// runDiff is the diff subcommand, which compares the phases of reports
+// saved earlier.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff A.json B.json\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Compare the phases of the reports saved in two files, by -J, -log-file or -record.")
+		fmt.Fprintln(os.Stderr, "Files of several reports are compared by their medians.")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	ra, err := readReports(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("unable to read reports: %v", err)
+	}
+	rb, err := readReports(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("unable to read reports: %v", err)
+	}
+
+	showTLS := false
+	for _, r := range append(append([]Report(nil), ra...), rb...) {
+		if r.Timing.TLS > 0 || r.TLS != nil || r.TimingMicros != nil && r.TimingMicros.TLS > 0 {
+			showTLS = true
+		}
+	}
+	printComparison(fs.Arg(0), fs.Arg(1), ra, rb, showTLS)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadReports(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, data string
+		totals     []int
+	}{
+		{"report.json", "{\n  \"Status\": \"200 OK\",\n  \"Timing\": {\"Total\": 12}\n}\n", []int{12}},
+		{"log.jsonl", `{"Timing":{"Total":12}}` + "\n" + `{"Timing":{"Total":15}}` + "\n", []int{12, 15}},
+		{"history.jsonl", `{"Time":"2024-03-05T14:07:09Z","URL":"https://example.com/","Report":{"Timing":{"Total":30}}}` + "\n", []int{30}},
+	} {
+		filename := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		reports, err := readReports(filename)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(reports) != len(tt.totals) {
+			t.Errorf("%s: got %d reports, want %d", tt.name, len(reports), len(tt.totals))
+			continue
+		}
+		for i, r := range reports {
+			if r.Timing.Total != tt.totals[i] {
+				t.Errorf("%s: report %d total %d, want %d", tt.name, i, r.Timing.Total, tt.totals[i])
+			}
+		}
+	}
+
+	filename := filepath.Join(dir, "empty.json")
+	os.WriteFile(filename, nil, 0644)
+	if _, err := readReports(filename); err == nil {
+		t.Error("empty file accepted")
+	}
+}
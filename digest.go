@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// digestChallenge is a parsed RFC 7616 Digest WWW-Authenticate challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+// digestAlgorithms lists the supported algorithms, strongest first.
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+// digestAlgorithm returns the index in digestAlgorithms of the named
+// algorithm, ignoring any -sess suffix, or -1 if it is unsupported.
+func digestAlgorithm(algorithm string) int {
+	name := strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")
+	for i, a := range digestAlgorithms {
+		if a.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseDigestChallenge picks the strongest supported Digest challenge
+// from the WWW-Authenticate headers of a 401 response.
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	var best *digestChallenge
+	var rank int
+	for _, v := range h.Values("WWW-Authenticate") {
+		if len(v) < 7 || !strings.EqualFold(v[:7], "Digest ") {
+			continue
+		}
+		params := parseAuthParams(v[7:])
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		for _, q := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(q) == "auth" {
+				c.qop = "auth"
+			}
+		}
+		if params["qop"] != "" && c.qop == "" {
+			// only auth-int is offered, which we don't support.
+			continue
+		}
+		if i := digestAlgorithm(c.algorithm); i != -1 && (best == nil || i < rank) {
+			best, rank = c, i
+		}
+	}
+	return best, best != nil
+}
+
+// parseAuthParams parses the comma separated name=value pairs of an
+// authentication challenge, unquoting quoted values.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, " ,") {
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimSpace(s[eq+1:])
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value, s = b.String(), s[min(i+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end == -1 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		params[name] = value
+	}
+	return params
+}
+
+// authorize computes the Authorization header answering the challenge
+// for a request with the given method and request URI.
+func (c *digestChallenge) authorize(method, uri, user, password, cnonce string) string {
+	newHash := digestAlgorithms[digestAlgorithm(c.algorithm)].hash
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	const nc = "00000001"
+	ha1 := h(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", uri="%s", algorithm=%s, nonce="%s", response="%s"`,
+		user, c.realm, uri, c.algorithm, c.nonce, response)
+	if c.qop != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	if c.opaque != "" {
+		auth += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	return auth
+}
+
+func newCnonce() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDigestAuthorize(t *testing.T) {
+	// example from RFC 7616, section 3.9.1
+	h := http.Header{}
+	h.Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	h.Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+
+	c, ok := parseDigestChallenge(h)
+	if !ok {
+		t.Fatal("no digest challenge found")
+	}
+	if c.algorithm != "SHA-256" {
+		t.Errorf("want strongest algorithm SHA-256, got %s", c.algorithm)
+	}
+
+	const cnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	tests := []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", `response="8ca523f5e9506fed4657c9700eebdbec"`},
+		{"SHA-256", `response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"`},
+	}
+	for _, test := range tests {
+		c.algorithm = test.algorithm
+		auth := c.authorize("GET", "/dir/index.html", "Mufasa", "Circle of Life", cnonce)
+		if !strings.Contains(auth, test.response) {
+			t.Errorf("Given: %s\nwant: %s\ngot: %s", test.algorithm, test.response, auth)
+		}
+	}
+}
+
+func TestDigestExpandedURI(t *testing.T) {
+	defer func(user, password, host, scheme string, vars map[string]string) {
+		authUser, authPassword, authHost, authScheme, templateVars = user, password, host, scheme, vars
+	}(authUser, authPassword, authHost, authScheme, templateVars)
+
+	var uri string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(auth, `uri="`+r.URL.RequestURI()+`"`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		uri = r.URL.RequestURI()
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/items/{{id}}?v={{id}}")
+	authUser, authPassword, authHost, authScheme = "user", "pass", u.Host, "digest"
+	templateVars = map[string]string{"id": "42"}
+
+	var report Report
+	resp := authenticate(http.DefaultClient, u, roundTrip(http.DefaultClient, prepareRequest(u), &report), &report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || uri != "/items/42?v=42" {
+		t.Errorf("got %s for %s, want 200 OK for /items/42?v=42", resp.Status, uri)
+	}
+}
+
+func TestDigestRetrySameRequest(t *testing.T) {
+	defer func(user, password, host, scheme, method, body string) {
+		authUser, authPassword, authHost, authScheme, httpMethod, postBody = user, password, host, scheme, method, body
+	}(authUser, authPassword, authHost, authScheme, httpMethod, postBody)
+
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		sent = append(sent, r.URL.RequestURI()+" "+string(b))
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+	file := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(file, []byte("from a file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL + "/items?id={{uuid}}")
+	authUser, authPassword, authHost, authScheme, httpMethod = "user", "pass", u.Host, "digest", "POST"
+
+	for _, body := range []string{`{"id": "{{uuid}}"}`, "@" + file} {
+		postBody, sent = body, nil
+		var report Report
+		resp := authenticate(http.DefaultClient, u, roundTrip(http.DefaultClient, prepareRequest(u), &report), &report)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(sent) != 2 || sent[0] != sent[1] || strings.HasSuffix(sent[1], " ") {
+			t.Errorf("with the body %s, got %s having sent %q, want the challenged request repeated", body, resp.Status, sent)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// printDryRun shows what visiting req with tr would send, without
+// connecting to anything.
+func printDryRun(req *http.Request, tr *http.Transport) {
+	label := labelString
+	printf("%s\n", warnString("Dry run, no request sent."))
+
+	// DumpRequestOut runs the request through a transport writing to
+	// memory, so it includes the headers the transport adds.
+	dump, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		printf("\n%s %s\n", label("Request:"), errorString("%v", err))
+	} else {
+		printSentLines(strings.Split(strings.TrimRight(string(dump), "\r\n"), "\r\n"))
+	}
+
+	printf("\n%s %s\n", label("URL:"), valueString(req.URL.String()))
+
+	proxy := "none"
+	if tr.Proxy != nil {
+		if u, err := tr.Proxy(req); err != nil {
+			proxy = err.Error()
+		} else if u != nil {
+			proxy = u.Redacted()
+		}
+	}
+	printf("%s %s\n", label("Proxy:"), valueString(proxy))
+	printf("%s %s\n", label("Body:"), valueString(describeBody()))
+	if oauth2TokenURL != "" {
+		printf("%s %s\n", label("OAuth2:"), valueString("token not fetched from %s", oauth2TokenURL))
+	}
+
+	if c := tr.TLSClientConfig; c != nil && req.URL.Scheme == "https" {
+		printf("%s\n", label("TLS:"))
+		for _, s := range describeTLSConfig(c) {
+			printf("  %s\n", valueString(s))
+		}
+	}
+}
+
+// describeBody says where the request body would come from.
+func describeBody() string {
+	var desc string
+	switch {
+	case len(formData) > 0:
+		desc = "multipart form with 1 field"
+		if len(formData) > 1 {
+			desc = fmt.Sprintf("multipart form with %d fields", len(formData))
+		}
+	case bodyRandom.max > 0:
+		desc = "random " + bodyRandom.String()
+	case graphqlQuery != "":
+		desc = fmt.Sprintf("GraphQL query, %d bytes", len(postBody))
+	case postBody == "@-":
+		desc = "stdin"
+		if !chunkedUpload {
+			desc = fmt.Sprintf("stdin, %d bytes", len(stdinBody))
+		}
+	case strings.HasPrefix(postBody, "@"):
+		desc = "file " + postBody[1:]
+	case postBody != "":
+		desc = fmt.Sprintf("%d bytes inline", len(postBody))
+	default:
+		return "none"
+	}
+	if compressBody != "" {
+		desc += ", " + compressBody + " compressed"
+	}
+	if chunkedUpload || len(requestTrailers) > 0 {
+		desc += ", chunked"
+	}
+	return desc
+}
+
+// describeTLSConfig lists the TLS settings c would connect with.
+func describeTLSConfig(c *tls.Config) []string {
+	verify := "verified"
+	switch {
+	case c.InsecureSkipVerify:
+		verify = "not verified (-k)"
+	case cacert != "":
+		verify = "verified against " + cacert
+	}
+	versions := "default versions"
+	if c.MinVersion != 0 || c.MaxVersion != 0 {
+		min, max := "default", "default"
+		if c.MinVersion != 0 {
+			min = tlsVersionName(c.MinVersion)
+		}
+		if c.MaxVersion != 0 {
+			max = tlsVersionName(c.MaxVersion)
+		}
+		versions = "versions " + min + " to " + max
+	}
+
+	s := []string{
+		"server name " + c.ServerName,
+		"certificate " + verify,
+		versions,
+	}
+	if len(c.Certificates) > 0 {
+		s = append(s, "client certificate")
+	}
+	if len(publicKeyPins) > 0 {
+		s = append(s, fmt.Sprintf("%d public key pins", len(publicKeyPins)))
+	}
+	if useECH || echConfigFile != "" {
+		s = append(s, "Encrypted Client Hello")
+	}
+	if len(c.NextProtos) > 0 {
+		s = append(s, "ALPN "+strings.Join(c.NextProtos, ", "))
+	}
+	if c.KeyLogWriter != nil {
+		s = append(s, "TLS secrets logged")
+	}
+	return s
+}
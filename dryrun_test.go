@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestDescribeBody(t *testing.T) {
+	defer func(body, enc string) { postBody, compressBody = body, enc }(postBody, compressBody)
+
+	for _, tt := range []struct{ body, compress, want string }{
+		{"", "", "none"},
+		{"a=1", "", "3 bytes inline"},
+		{"@data.json", "", "file data.json"},
+		{"@data.json", "gzip", "file data.json, gzip compressed"},
+	} {
+		postBody, compressBody = tt.body, tt.compress
+		if got := describeBody(); got != tt.want {
+			t.Errorf("body %q: got %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeTLSConfig(t *testing.T) {
+	got := describeTLSConfig(&tls.Config{
+		ServerName:         "example.com",
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"h2", "http/1.1"},
+	})
+	want := []string{
+		"server name example.com",
+		"certificate not verified (-k)",
+		"versions TLS 1.2 to default",
+		"ALPN h2, http/1.1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ECHInfo reports whether Encrypted Client Hello was offered and accepted.
+type ECHInfo struct {
+	Source   string
+	Accepted bool
+	Error    string `json:",omitempty"`
+}
+
+func newECHInfo(cs tls.ConnectionState, err error) *ECHInfo {
+	info := &ECHInfo{Source: "DNS HTTPS record", Accepted: cs.ECHAccepted}
+	if echConfigFile != "" {
+		info.Source = echConfigFile
+	}
+	var rejected *tls.ECHRejectionError
+	if errors.As(err, &rejected) {
+		info.Error = "server rejected ECH and fell back to the outer ClientHello"
+		if len(rejected.RetryConfigList) > 0 {
+			info.Error += "; it offered retry configs"
+		}
+	}
+	return info
+}
+
+func printECHInfo(e *ECHInfo) {
+	result := okString("accepted")
+	if !e.Accepted {
+		result = errorString("not accepted")
+	}
+	printf("\n%s %s %s\n", labelString("ECH:"), result, labelString("(config from "+e.Source+")"))
+}
+
+const (
+	typeHTTPS    = dnsmessage.Type(65) // RFC 9460
+	svcParamECH  = 5
+	resolvConf   = "/etc/resolv.conf"
+	dnsQueryWait = 5 * time.Second
+)
+
+// loadECHConfig returns the ECHConfigList to offer to host, read from
+// filename if given, otherwise from the host's DNS HTTPS record.
+func loadECHConfig(host, filename string) ([]byte, error) {
+	if filename != "" {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ECH config file: %v", err)
+		}
+		// accept the base64 presentation format used in DNS zone files
+		// as well as the raw wire format.
+		if dec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b))); err == nil {
+			return dec, nil
+		}
+		return b, nil
+	}
+
+	server, err := nameserver()
+	if err != nil {
+		return nil, err
+	}
+	records, err := lookupHTTPS(server, host)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPS record lookup for %s failed: %v", host, err)
+	}
+	for _, rr := range records {
+		if ech, ok := svcParam(rr, svcParamECH); ok {
+			return ech, nil
+		}
+	}
+	return nil, fmt.Errorf("no ECH config published in the HTTPS record for %s", host)
+}
+
+// nameserver returns the first DNS server listed in /etc/resolv.conf.
+func nameserver() (string, error) {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return "", fmt.Errorf("unable to find a DNS server, use -ech-config instead: %v", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver found in %s, use -ech-config instead", resolvConf)
+}
+
+// lookupHTTPS queries server for the HTTPS records of host, returning
+// the raw RDATA of each record, retrying over TCP if the UDP response
+// was truncated.
+func lookupHTTPS(server, host string) ([][]byte, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: typeHTTPS, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchange("udp", server, query)
+	if err != nil {
+		return nil, err
+	}
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Truncated {
+		if resp, err = exchange("tcp", server, query); err != nil {
+			return nil, err
+		}
+		if hdr, err = p.Start(resp); err != nil {
+			return nil, err
+		}
+	}
+	if hdr.ID != id {
+		return nil, errors.New("mismatched DNS response id")
+	}
+	if hdr.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("DNS server returned %v", hdr.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	var records [][]byte
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Type != typeHTTPS {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rr, err := p.UnknownResource()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rr.Data)
+	}
+}
+
+func exchange(network, server string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, server, dnsQueryWait)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsQueryWait))
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		return buf[:n], err
+	}
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var l uint16
+	if err := binary.Read(conn, binary.BigEndian, &l); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, l)
+	_, err = io.ReadFull(conn, resp)
+	return resp, err
+}
+
+// svcParam extracts the value of the given SvcParamKey from the RDATA
+// of a SVCB or HTTPS record.
+func svcParam(rdata []byte, key uint16) ([]byte, bool) {
+	r := bytes.NewReader(rdata)
+	var priority uint16
+	if binary.Read(r, binary.BigEndian, &priority) != nil || priority == 0 {
+		// AliasMode records carry no parameters.
+		return nil, false
+	}
+	// skip the uncompressed TargetName.
+	for {
+		l, err := r.ReadByte()
+		if err != nil {
+			return nil, false
+		}
+		if l == 0 {
+			break
+		}
+		if _, err := r.Seek(int64(l), io.SeekCurrent); err != nil {
+			return nil, false
+		}
+	}
+	for r.Len() > 0 {
+		var k, l uint16
+		if binary.Read(r, binary.BigEndian, &k) != nil || binary.Read(r, binary.BigEndian, &l) != nil {
+			return nil, false
+		}
+		v := make([]byte, l)
+		if _, err := io.ReadFull(r, v); err != nil {
+			return nil, false
+		}
+		if k == key {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSvcParam(t *testing.T) {
+	rdata := []byte{
+		0, 1, // SvcPriority
+		3, 'c', 'd', 'n', 0, // TargetName
+		0, 1, 0, 3, 2, 'h', '2', // alpn="h2"
+		0, 5, 0, 4, 0xde, 0xad, 0xbe, 0xef, // ech
+	}
+	ech, ok := svcParam(rdata, svcParamECH)
+	if !ok || !bytes.Equal(ech, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("want ech param deadbeef, got %x (found: %v)", ech, ok)
+	}
+	if _, ok := svcParam(rdata, 6); ok {
+		t.Errorf("unexpected ipv6hint param")
+	}
+	if _, ok := svcParam([]byte{0, 0, 0}, svcParamECH); ok {
+		t.Errorf("unexpected param in AliasMode record")
+	}
+}
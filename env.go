@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// envRef matches the ${VAR} references expanded with -expand-env. Bare
+// $VAR is left alone as it is common in header values and bodies.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references in s with the values of the
+// environment variables, which must be set.
+func expandEnv(s string) (string, error) {
+	var err error
+	s = envRef.ReplaceAllStringFunc(s, func(m string) string {
+		name := envRef.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v
+	})
+	return s, err
+}
+
+// expandHeaderEnv expands the ${VAR} references in the values of h.
+func expandHeaderEnv(h headers) error {
+	for i, v := range h {
+		k, val := headerKeyValue(v)
+		val, err := expandEnv(val)
+		if err != nil {
+			return fmt.Errorf("header %s: %v", k, err)
+		}
+		h[i] = k + ": " + val
+	}
+	return nil
+}
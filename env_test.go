@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("HTTPSTAT_TOKEN", "s3cr3t")
+	t.Setenv("HTTPSTAT_EMPTY", "")
+
+	for in, want := range map[string]string{
+		"Bearer ${HTTPSTAT_TOKEN}":   "Bearer s3cr3t",
+		"a${HTTPSTAT_EMPTY}b":        "ab",
+		"$HTTPSTAT_TOKEN and ${ x }": "$HTTPSTAT_TOKEN and ${ x }",
+	} {
+		if got, err := expandEnv(in); err != nil || got != want {
+			t.Errorf("expandEnv(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := expandEnv("${HTTPSTAT_UNSET_VARIABLE}"); err == nil {
+		t.Error("want an error for an unset variable")
+	}
+
+	h := headers{"Authorization: Bearer ${HTTPSTAT_TOKEN}"}
+	if err := expandHeaderEnv(h); err != nil || h[0] != "Authorization: Bearer s3cr3t" {
+		t.Errorf("got %q, %v", h, err)
+	}
+}
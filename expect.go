@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Continue times the wait for the interim 100 Continue response to a
+// request sent with Expect: 100-continue.
+type Continue struct {
+	Wait     int  // milliseconds from sending the headers to the 100 Continue
+	Received bool // whether the 100 Continue arrived
+	BodySent bool // whether the body was sent, after the 100 Continue or a timeout
+
+	wait int // Wait in microseconds
+}
+
+// setExpectContinue asks the server to confirm it wants the body of
+// req before it is sent.
+func setExpectContinue(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Header.Set("Expect", "100-continue")
+}
+
+func printContinue(c *Continue) {
+	label := labelString
+	switch {
+	case c.Received:
+		printf("\n%s %s %s\n", label("100 Continue:"), valueString(formatTiming(c.wait)), label("(waiting to send the body)"))
+	case c.BodySent:
+		printf("\n%s %s\n", label("100 Continue:"), warnString("not received, body sent after waiting for it timed out"))
+	default:
+		printf("\n%s %s\n", label("100 Continue:"), warnString("not received, the server responded without the body"))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpectContinue(t *testing.T) {
+	defer func(j bool) { jsonOutput = j }(jsonOutput)
+	jsonOutput = true
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		// the server sends 100 Continue when the body is first read.
+		time.Sleep(20 * time.Millisecond)
+		ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Second}}
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+	setExpectContinue(req)
+	var report Report
+	roundTrip(client, req, &report).Body.Close()
+	c := report.Continue
+	if c == nil || !c.Received || !c.BodySent || c.Wait < 15 {
+		t.Errorf("Continue = %+v, want a 100 Continue after about 20ms", c)
+	}
+	if report.Upload == nil || report.Upload.Bytes != 7 {
+		t.Errorf("Upload = %+v, want 7 bytes", report.Upload)
+	}
+
+	req, _ = http.NewRequest("POST", ts.URL+"/reject", strings.NewReader("payload"))
+	setExpectContinue(req)
+	report = Report{}
+	roundTrip(client, req, &report).Body.Close()
+	if c := report.Continue; c == nil || c.Received || c.BodySent {
+		t.Errorf("Continue = %+v, want the body not sent", c)
+	}
+}
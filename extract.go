@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extracted is a value taken from a JSON response body with -extract.
+type Extracted struct {
+	Name  string `json:",omitempty"`
+	Path  string
+	Value interface{}
+	Found bool
+}
+
+// pathStep is one step of a JSONPath expression: an object key, an
+// array index, or every member of either.
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// extraction is a -extract expression, optionally named.
+type extraction struct {
+	name, path string
+	steps      []pathStep
+}
+
+// extractions are the -extract expressions, given as a JSONPath like
+// $.data.items[0].id, or name=PATH to also set the {{name}} template
+// value for the requests that follow.
+type extractions []extraction
+
+func (e extractions) String() string {
+	var o []string
+	for _, x := range e {
+		o = append(o, x.path)
+	}
+	return strings.Join(o, ",")
+}
+
+func (e *extractions) Set(v string) error {
+	x := extraction{path: v}
+	if !strings.HasPrefix(v, "$") && !strings.HasPrefix(v, ".") {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("extract expression %q must start with $ or be of the form name=$.path", v)
+		}
+		x.name, x.path = name, path
+	}
+	var err error
+	if x.steps, err = parseJSONPath(x.path); err != nil {
+		return err
+	}
+	*e = append(*e, x)
+	return nil
+}
+
+// parseJSONPath parses the subset of JSONPath made of .key, ['key'],
+// [index] and the wildcards .* and [*]. The leading $ is optional, as
+// in jq's .data.id.
+func parseJSONPath(path string) ([]pathStep, error) {
+	bad := func(why string) ([]pathStep, error) {
+		return nil, fmt.Errorf("invalid JSONPath %q: %s", path, why)
+	}
+	s := strings.TrimPrefix(path, "$")
+	if s == path && !strings.HasPrefix(s, ".") {
+		return bad("must start with $ or .")
+	}
+	if s == "." {
+		return nil, nil
+	}
+
+	var steps []pathStep
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			key := s[1:end]
+			switch key {
+			case "":
+				return bad("empty key")
+			case "*":
+				steps = append(steps, pathStep{wildcard: true})
+			default:
+				steps = append(steps, pathStep{key: key})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return bad("missing ]")
+			}
+			sub := s[1:end]
+			switch {
+			case sub == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case len(sub) >= 2 && (sub[0] == '\'' || sub[0] == '"') && sub[len(sub)-1] == sub[0]:
+				steps = append(steps, pathStep{key: sub[1 : len(sub)-1]})
+			default:
+				i, err := strconv.Atoi(sub)
+				if err != nil {
+					return bad("index " + sub + " is not a number")
+				}
+				steps = append(steps, pathStep{index: i, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			return bad("expected . or [")
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns the values steps lead to from v.
+func evalJSONPath(v interface{}, steps []pathStep) []interface{} {
+	if len(steps) == 0 {
+		return []interface{}{v}
+	}
+	st, rest := steps[0], steps[1:]
+	var out []interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if st.wildcard {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				out = append(out, evalJSONPath(v[k], rest)...)
+			}
+		} else if m, ok := v[st.key]; ok && !st.isIndex {
+			out = evalJSONPath(m, rest)
+		}
+	case []interface{}:
+		if st.wildcard {
+			for _, m := range v {
+				out = append(out, evalJSONPath(m, rest)...)
+			}
+		} else if st.isIndex {
+			i := st.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = evalJSONPath(v[i], rest)
+			}
+		}
+	}
+	return out
+}
+
+// extract evaluates the expressions against a JSON body. An expression
+// with a wildcard gives the array of the values it matched.
+func (e extractions) extract(body []byte) ([]Extracted, error) {
+	var doc interface{}
+	err := json.Unmarshal(body, &doc)
+	var out []Extracted
+	for _, x := range e {
+		r := Extracted{Name: x.name, Path: x.path}
+		if err == nil {
+			matches := evalJSONPath(doc, x.steps)
+			wildcard := false
+			for _, st := range x.steps {
+				wildcard = wildcard || st.wildcard
+			}
+			switch {
+			case wildcard:
+				r.Value, r.Found = matches, len(matches) > 0
+			case len(matches) == 1:
+				r.Value, r.Found = matches[0], true
+			}
+		}
+		out = append(out, r)
+	}
+	if err != nil {
+		return out, fmt.Errorf("unable to extract values, response body is not JSON: %v", err)
+	}
+	return out, nil
+}
+
+// templateValue formats an extracted value for use in a template:
+// strings as they are, anything else as JSON.
+func templateValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func printExtracted(values []Extracted) {
+	label := labelString
+	printf("\n%s\n", label("Extracted:"))
+	for _, x := range values {
+		name := x.Path
+		if x.Name != "" {
+			name = x.Name
+		}
+		if !x.Found {
+			printf("  %s %s\n", label(name+":"), warnString("no match"))
+			continue
+		}
+		b, _ := json.Marshal(x.Value)
+		printf("  %s %s\n", label(name+":"), valueString(string(b)))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	var e extractions
+	for _, expr := range []string{
+		"$.data.id",
+		"name=$.data.items[0]['name']",
+		".data.items[-1].name",
+		"$.data.items[*].id",
+		"$.missing",
+		"$",
+	} {
+		if err := e.Set(expr); err != nil {
+			t.Fatalf("Set(%q): %v", expr, err)
+		}
+	}
+	body := `{"data":{"id":7,"items":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}}`
+	got, err := e.extract([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Extracted{
+		{Path: "$.data.id", Value: 7.0, Found: true},
+		{Name: "name", Path: "$.data.items[0]['name']", Value: "a", Found: true},
+		{Path: ".data.items[-1].name", Value: "b", Found: true},
+		{Path: "$.data.items[*].id", Value: []interface{}{1.0, 2.0}, Found: true},
+		{Path: "$.missing"},
+	}
+	if !reflect.DeepEqual(got[:5], want) {
+		t.Errorf("got %+v, want %+v", got[:5], want)
+	}
+	if !got[5].Found {
+		t.Errorf("$ did not match the document")
+	}
+
+	if _, err := e.extract([]byte("<html>")); err == nil {
+		t.Errorf("no error extracting from a non-JSON body")
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, path := range []string{"data.id", "$.a..b", "$.a[0", "$.a[x]", "$a"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded", path)
+		}
+	}
+}
+
+func TestTemplateValue(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		want string
+	}{
+		{"abc", "abc"},
+		{42.0, "42"},
+		{[]interface{}{1.0, "x"}, `[1,"x"]`},
+		{nil, "null"},
+	} {
+		if got := templateValue(tt.v); got != tt.want {
+			t.Errorf("templateValue(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// formatEscapes are the escapes interpreted in a -format template, as
+// shells don't make it easy to give newlines and tabs.
+var formatEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+// formatFlag is the -format template.
+type formatFlag struct {
+	*template.Template
+	text string
+}
+
+func (f formatFlag) String() string { return f.text }
+
+func (f *formatFlag) Set(v string) error {
+	t, err := parseFormat(v)
+	if err != nil {
+		return err
+	}
+	*f = formatFlag{t, v}
+	return nil
+}
+
+// parseFormat parses a -format template, which is given a Report. The
+// output ends with a newline, added if the template doesn't.
+func parseFormat(s string) (*template.Template, error) {
+	s = formatEscapes.Replace(s)
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		// join takes the separator first to be used in pipelines:
+		// {{index .Header "Vary" | join ", "}}.
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+	}).Option("missingkey=zero").Parse(s)
+}
+
+// textReport reports whether the results of each request are printed
+// in full as text, rather than as JSON or Markdown, through -format or
+// -write-out, or cut down by -q or -summary-only.
+func textReport() bool {
+	return !jsonOutput && !markdownOutput && outputFormat.Template == nil && writeOutFormat == "" && !quiet && !summaryOnly
+}
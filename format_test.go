@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestFormatFlag(t *testing.T) {
+	var f formatFlag
+	if err := f.Set(`{{.Timing.Total}} {{.Status}}\t{{index .Header "Vary" | join ", "}} {{json .Size.BodyBytes}}`); err != nil {
+		t.Fatal(err)
+	}
+	r := Report{Status: "200 OK", Header: http.Header{"Vary": {"Accept", "Origin"}}}
+	r.Timing.Total = 42
+	r.Size.BodyBytes = 7
+
+	var buf bytes.Buffer
+	if err := f.Execute(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if want := "42 200 OK\tAccept, Origin 7\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := f.Set("{{.Status"); err == nil {
+		t.Errorf("invalid template accepted")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// mismatchedETag is sent in If-None-Match to check the origin doesn't
+// answer 304 whatever the validator.
+const mismatchedETag = `"httpstat-no-such-etag"`
+
+// freshnessResponse is a response to one request of the freshness probe.
+type freshnessResponse struct {
+	status int
+	header http.Header
+	size   int64 // the bytes of the body
+	total  int   // microseconds
+	at     time.Time
+	err    error
+}
+
+func (r freshnessResponse) age() (int, bool) {
+	age, err := strconv.Atoi(strings.TrimSpace(r.header.Get("Age")))
+	return age, err == nil
+}
+
+// freshnessCheck is the result of one check of the probe: pass, warn or
+// fail.
+type freshnessCheck struct {
+	name   string
+	result string
+	detail string
+}
+
+// probeFreshness requests u with the extra headers.
+func probeFreshness(client *http.Client, u string, header http.Header) freshnessResponse {
+	var r freshnessResponse
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		r.size, err = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		r.status, r.header = resp.StatusCode, resp.Header
+	}
+	r.total, r.at, r.err = usSince(start), time.Now(), err
+	return r
+}
+
+// checkRevalidation checks a request made conditional on the validator
+// of full was answered 304 Not Modified.
+func checkRevalidation(name, validator string, full, r freshnessResponse) freshnessCheck {
+	c := freshnessCheck{name: name}
+	switch {
+	case r.err != nil:
+		c.result, c.detail = "fail", r.err.Error()
+	case r.status == http.StatusNotModified && r.size > 0:
+		c.result, c.detail = "warn", fmt.Sprintf("304 in %s, but with a %s body", formatTiming(r.total), formatBytes(r.size))
+	case r.status == http.StatusNotModified && r.header.Get("ETag") != "" && r.header.Get("ETag") != full.header.Get("ETag"):
+		c.result, c.detail = "warn", fmt.Sprintf("304 in %s, but with ETag %s", formatTiming(r.total), r.header.Get("ETag"))
+	case r.status == http.StatusNotModified:
+		c.result, c.detail = "pass", fmt.Sprintf("304 in %s", formatTiming(r.total))
+	case r.status == full.status:
+		c.result, c.detail = "fail", fmt.Sprintf("full %d in %s, %s again, revalidation not honored", r.status, formatTiming(r.total), formatBytes(r.size))
+		if strings.HasPrefix(validator, "W/") {
+			c.detail += ", though weak ETags match by weak comparison"
+		}
+	default:
+		c.result, c.detail = "fail", fmt.Sprintf("%d in %s", r.status, formatTiming(r.total))
+	}
+	return c
+}
+
+// checkMismatch checks a request with an ETag that can't match wasn't
+// answered 304, as misconfigured caches ignoring the validator do.
+func checkMismatch(r freshnessResponse) freshnessCheck {
+	c := freshnessCheck{name: "Other ETag"}
+	switch {
+	case r.err != nil:
+		c.result, c.detail = "fail", r.err.Error()
+	case r.status == http.StatusNotModified:
+		c.result, c.detail = "fail", "304 for an ETag that doesn't match, the validator is ignored"
+	default:
+		c.result, c.detail = "pass", fmt.Sprintf("%d in %s", r.status, formatTiming(r.total))
+	}
+	return c
+}
+
+// checkValidators checks the validators of a later response are those
+// of the first, as they are unless the resource changed or the caches
+// serving it disagree.
+func checkValidators(first, r freshnessResponse) freshnessCheck {
+	c := freshnessCheck{name: "Validators", result: "pass", detail: "unchanged"}
+	var changed []string
+	for _, h := range []string{"ETag", "Last-Modified"} {
+		if a, b := first.header.Get(h), r.header.Get(h); a != b {
+			changed = append(changed, fmt.Sprintf("%s %s to %s", h, a, b))
+		}
+	}
+	if len(changed) > 0 {
+		c.result, c.detail = "warn", "changed "+strings.Join(changed, ", ")+"; modified, or caches disagree"
+	}
+	return c
+}
+
+// checkAge checks the Age of a later response grew by the time since
+// the first, as it does while the same cached copy is served, and that
+// the copy isn't stale.
+func checkAge(first, r freshnessResponse) freshnessCheck {
+	c := freshnessCheck{name: "Age", result: "pass"}
+	a1, ok1 := first.age()
+	a2, ok2 := r.age()
+	if !ok1 && !ok2 {
+		c.detail = "none, not served from a cache"
+		return c
+	}
+	elapsed := int(r.at.Sub(first.at).Round(time.Second) / time.Second)
+	expected := a1 + elapsed
+	lifetime := analyzeCachePolicy(r.status, r.header, r.at).Lifetime
+	switch {
+	case !ok1 || !ok2:
+		c.result, c.detail = "warn", "only sent with some responses"
+	case a2 < a1:
+		c.result, c.detail = "warn", fmt.Sprintf("%ds, went back from %ds, another cache served it or it was refetched", a2, a1)
+	case a2 > expected+1 || a2 < expected-1:
+		c.result, c.detail = "warn", fmt.Sprintf("%ds, expected %ds, drifted by %+ds", a2, expected, a2-expected)
+	default:
+		c.detail = fmt.Sprintf("%ds, %ds after %ds", a2, elapsed, a1)
+	}
+	if ok2 && lifetime > 0 && a2 > lifetime {
+		c.result, c.detail = "fail", fmt.Sprintf("%ds, beyond its freshness lifetime of %ds, served stale", a2, lifetime)
+		if _, ok := parseCacheControl(r.header)["stale-while-revalidate"]; ok {
+			c.result = "warn"
+		}
+	}
+	return c
+}
+
+// printFreshnessChecks prints the checks of a round of the probe.
+func printFreshnessChecks(checks []freshnessCheck) {
+	results := map[string]func(string, ...interface{}) string{
+		"pass": okString,
+		"warn": warnString,
+		"fail": errorString,
+	}
+	for _, c := range checks {
+		printf("  %s %s %s\n", labelString("%-18s", c.name+":"), results[c.result]("%s", c.result), valueString(c.detail))
+	}
+}
+
+// runFreshness is the freshness subcommand, which checks the origin, or
+// the caches in front of it, revalidate a URL correctly.
+func runFreshness(args []string) {
+	fs := flag.NewFlagSet("freshness", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s freshness [OPTIONS] URL\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Fetch URL for its ETag and Last-Modified validators, then request it again conditionally on each,")
+		fmt.Fprintln(os.Stderr, "and on an ETag that can't match, reporting whether revalidation gives 304 or a full 200 and")
+		fmt.Fprintln(os.Stderr, "whether the Age of the cached copy grows as it should.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	var hdrs headers
+	fs.Var(&hdrs, "H", "set HTTP header; repeatable")
+	fs.BoolVar(&insecure, "k", false, "allow insecure SSL connections")
+	fs.DurationVar(&maxTime, "m", 0, "maximum time each request may take")
+	rounds := fs.Int("n", 1, "number of rounds of conditional requests")
+	wait := fs.Duration("wait", 2*time.Second, "wait this long before each round")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	u := parseURL(fs.Arg(0)).String()
+	header, err := httpHeader(hdrs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := crawlClient(1)
+	client.Transport = headerTransport{client.Transport, header}
+
+	first := probeFreshness(client, u, nil)
+	if first.err != nil {
+		log.Fatalf("unable to fetch %s: %v", u, first.err)
+	}
+	etag, lastModified := first.header.Get("ETag"), first.header.Get("Last-Modified")
+	printf("%s %s %s\n", labelString("Fetched:"), statusString("%d", first.status),
+		valueString("in %s, %s", formatTiming(first.total), formatBytes(first.size)))
+	for _, v := range []struct{ name, value string }{{"ETag:", etag}, {"Last-Modified:", lastModified}, {"Cache-Control:", first.header.Get("Cache-Control")}, {"Age:", first.header.Get("Age")}} {
+		if v.value != "" {
+			printf("  %s %s\n", labelString("%-18s", v.name), valueString(v.value))
+		}
+	}
+	if etag == "" && lastModified == "" {
+		printf("%s\n", errorString("No ETag or Last-Modified, so the response can't be revalidated and is downloaded again in full once stale."))
+		os.Exit(1)
+	}
+
+	for round := 1; round <= *rounds; round++ {
+		time.Sleep(*wait)
+		printf("\n%s\n", labelString("Round %d, %s after the first fetch:", round, time.Since(first.at).Round(time.Second)))
+		var checks []freshnessCheck
+		if etag != "" {
+			r := probeFreshness(client, u, http.Header{"If-None-Match": {etag}})
+			checks = append(checks, checkRevalidation("If-None-Match", etag, first, r))
+			checks = append(checks, checkMismatch(probeFreshness(client, u, http.Header{"If-None-Match": {mismatchedETag}})))
+		}
+		if lastModified != "" {
+			r := probeFreshness(client, u, http.Header{"If-Modified-Since": {lastModified}})
+			checks = append(checks, checkRevalidation("If-Modified-Since", lastModified, first, r))
+		}
+		if r := probeFreshness(client, u, nil); r.err == nil {
+			checks = append(checks, checkValidators(first, r), checkAge(first, r))
+		} else {
+			checks = append(checks, freshnessCheck{"Refetch", "fail", r.err.Error()})
+		}
+		printFreshnessChecks(checks)
+		for _, c := range checks {
+			if c.result == "fail" {
+				exitStatus = 1
+			}
+		}
+	}
+	os.Exit(exitStatus)
+}
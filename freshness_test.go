@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRevalidation(t *testing.T) {
+	full := freshnessResponse{status: 200, header: http.Header{"Etag": {`"a"`}}, size: 100}
+	tests := []struct {
+		r      freshnessResponse
+		result string
+		detail string
+	}{
+		{freshnessResponse{status: 304, header: http.Header{}}, "pass", "304"},
+		{freshnessResponse{status: 304, header: http.Header{"Etag": {`"a"`}}}, "pass", "304"},
+		{freshnessResponse{status: 304, header: http.Header{"Etag": {`"b"`}}}, "warn", `ETag "b"`},
+		{freshnessResponse{status: 304, header: http.Header{}, size: 10}, "warn", "10B body"},
+		{freshnessResponse{status: 200, header: http.Header{}, size: 100}, "fail", "not honored"},
+		{freshnessResponse{status: 500, header: http.Header{}}, "fail", "500"},
+		{freshnessResponse{err: errors.New("timeout")}, "fail", "timeout"},
+	}
+	for _, tt := range tests {
+		c := checkRevalidation("If-None-Match", `"a"`, full, tt.r)
+		if c.result != tt.result || !strings.Contains(c.detail, tt.detail) {
+			t.Errorf("%d: got %s %q, want %s containing %q", tt.r.status, c.result, c.detail, tt.result, tt.detail)
+		}
+	}
+
+	if c := checkRevalidation("If-None-Match", `W/"a"`, full, freshnessResponse{status: 200}); !strings.Contains(c.detail, "weak") {
+		t.Errorf("weak ETag not mentioned: %q", c.detail)
+	}
+}
+
+func TestCheckMismatch(t *testing.T) {
+	if c := checkMismatch(freshnessResponse{status: 304}); c.result != "fail" {
+		t.Errorf("304 for another ETag: got %s", c.result)
+	}
+	if c := checkMismatch(freshnessResponse{status: 200}); c.result != "pass" {
+		t.Errorf("200 for another ETag: got %s", c.result)
+	}
+}
+
+func TestCheckValidators(t *testing.T) {
+	first := freshnessResponse{header: http.Header{"Etag": {`"a"`}, "Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"}}}
+	if c := checkValidators(first, first); c.result != "pass" {
+		t.Errorf("same validators: got %s %q", c.result, c.detail)
+	}
+	r := freshnessResponse{header: http.Header{"Etag": {`"b"`}, "Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"}}}
+	if c := checkValidators(first, r); c.result != "warn" || !strings.Contains(c.detail, `ETag "a" to "b"`) {
+		t.Errorf("changed ETag: got %s %q", c.result, c.detail)
+	}
+}
+
+func TestCheckAge(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	response := func(age string, after time.Duration) freshnessResponse {
+		h := http.Header{"Cache-Control": {"max-age=60"}}
+		if age != "" {
+			h.Set("Age", age)
+		}
+		return freshnessResponse{status: 200, header: h, at: at.Add(after)}
+	}
+	tests := []struct {
+		first, r freshnessResponse
+		result   string
+		detail   string
+	}{
+		{response("", 0), response("", 5*time.Second), "pass", "not served from a cache"},
+		{response("10", 0), response("15", 5*time.Second), "pass", "15s, 5s after 10s"},
+		{response("10", 0), response("2", 5*time.Second), "warn", "went back"},
+		{response("10", 0), response("30", 5*time.Second), "warn", "drifted by +15s"},
+		{response("10", 0), response("", 5*time.Second), "warn", "only sent"},
+		{response("58", 0), response("63", 5*time.Second), "fail", "served stale"},
+	}
+	for _, tt := range tests {
+		c := checkAge(tt.first, tt.r)
+		if c.result != tt.result || !strings.Contains(c.detail, tt.detail) {
+			t.Errorf("Age %q then %q: got %s %q, want %s containing %q", tt.first.header.Get("Age"), tt.r.header.Get("Age"),
+				c.result, c.detail, tt.result, tt.detail)
+		}
+	}
+}
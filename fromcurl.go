@@ -0,0 +1,578 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shellWords splits a command line into words as a POSIX shell would,
+// with '...', "...", $'...' quoting and backslash escapes, joining lines
+// ending in a backslash.
+func shellWords(s string) ([]string, error) {
+	var words []string
+	var w strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, w.String())
+				w.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			i++
+			if i < len(s) && s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			if i < len(s) && s[i] != '\n' {
+				w.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '")
+			}
+			w.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCString(&w, s[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				w.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf(`unterminated "`)
+			}
+			inWord = true
+		default:
+			w.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, w.String())
+	}
+	return words, nil
+}
+
+// ansiCString writes the $'...' string s starts the body of to w,
+// returning the length of s it took up to and including the closing
+// quote.
+func ansiCString(w *strings.Builder, s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			if r, ok := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', 'a': '\a', 'b': '\b', 'e': 033, 'E': 033,
+				'f': '\f', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?'}[s[i]]; ok {
+				w.WriteByte(r)
+				continue
+			}
+			base, digits := 0, 0
+			switch s[i] {
+			case 'x':
+				base, digits = 16, 2
+			case 'u':
+				base, digits = 16, 4
+			case 'U':
+				base, digits = 16, 8
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				base, digits = 8, 3
+				i-- // the digit is part of the number
+			}
+			if base == 0 {
+				w.WriteByte('\\')
+				w.WriteByte(s[i])
+				continue
+			}
+			n := 0
+			for n < digits && i+1+n < len(s) {
+				if _, err := strconv.ParseUint(s[i+1+n:i+2+n], base, 8); err != nil {
+					break
+				}
+				n++
+			}
+			v, err := strconv.ParseUint(s[i+1:i+1+n], base, 32)
+			if err != nil {
+				return 0, fmt.Errorf("invalid escape in $'...'")
+			}
+			if s[i] == 'u' || s[i] == 'U' {
+				w.WriteRune(rune(v))
+			} else {
+				w.WriteByte(byte(v))
+			}
+			i += n
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+	return 0, fmt.Errorf("unterminated $'")
+}
+
+// curlCommand is a curl command line translated to httpstat's flags.
+type curlCommand struct {
+	args     []string
+	url      string
+	proxy    string // set from -x, as curl's environment would
+	noProxy  string
+	warnings []string // of what httpstat can't translate
+
+	method  string
+	data    []string // the -d parts, joined with &
+	get     bool     // -G, sending data in the query
+	json    bool
+	headers headers // those given with -H, checked for Content-Type
+}
+
+// curlOption translates an option of curl, given its value if it takes
+// one.
+type curlOption struct {
+	value     bool
+	translate func(c *curlCommand, v string) error
+}
+
+// flagOption returns a curlOption adding the httpstat flag name, given the
+// value of the curl option, if it takes one.
+func flagOption(name string, value bool) curlOption {
+	return curlOption{value, func(c *curlCommand, v string) error {
+		c.args = append(c.args, "-"+name)
+		if value {
+			c.args = append(c.args, v)
+		}
+		return nil
+	}}
+}
+
+// secondsOption returns a curlOption setting the duration flag name to
+// the seconds given the curl option.
+func secondsOption(option, name string) curlOption {
+	return curlOption{true, func(c *curlCommand, v string) error {
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --%s %q", option, v)
+		}
+		c.args = append(c.args, "-"+name, time.Duration(s*float64(time.Second)).String())
+		return nil
+	}}
+}
+
+// headerOption returns a curlOption setting the header name.
+func headerOption(name string) curlOption {
+	return curlOption{true, func(c *curlCommand, v string) error {
+		c.addHeader(name + ": " + v)
+		return nil
+	}}
+}
+
+// authOption returns a curlOption using the authentication scheme.
+func authOption(scheme string) curlOption {
+	return curlOption{false, func(c *curlCommand, _ string) error {
+		c.args = append(c.args, "-auth", scheme)
+		return nil
+	}}
+}
+
+// tlsOption returns a curlOption setting the minimum TLS version.
+func tlsOption(version string) curlOption {
+	return curlOption{false, func(c *curlCommand, _ string) error {
+		c.args = append(c.args, "-tls-min", version)
+		return nil
+	}}
+}
+
+// dataOption returns a curlOption adding a part of the body, translated
+// by encode.
+func dataOption(encode func(string) (string, error)) curlOption {
+	return curlOption{true, func(c *curlCommand, v string) error {
+		d, err := encode(v)
+		c.data = append(c.data, d)
+		return err
+	}}
+}
+
+func (c *curlCommand) addHeader(h string) {
+	c.headers = append(c.headers, h)
+	c.args = append(c.args, "-H", h)
+}
+
+// ignoredOption is one that only changes what curl prints.
+var ignoredOption = curlOption{false, func(*curlCommand, string) error { return nil }}
+
+// curlOptions are the options of curl httpstat translates, by long
+// name.
+var curlOptions = map[string]curlOption{
+	"request": {true, func(c *curlCommand, v string) error { c.method = v; return nil }},
+	"header":  {true, func(c *curlCommand, v string) error { c.addHeader(v); return nil }},
+	"url":     {true, func(c *curlCommand, v string) error { c.setURL(v); return nil }},
+
+	"data":        dataOption(keepData),
+	"data-ascii":  dataOption(keepData),
+	"data-binary": dataOption(keepData),
+	"data-raw": dataOption(func(v string) (string, error) {
+		if strings.HasPrefix(v, "@") {
+			return v, fmt.Errorf("--data-raw %q would be read as a file by httpstat's -d", v)
+		}
+		return v, nil
+	}),
+	"data-urlencode": dataOption(urlencodeData),
+	"json": {true, func(c *curlCommand, v string) error {
+		c.json = true
+		c.data = append(c.data, v)
+		return nil
+	}},
+	"get":  {false, func(c *curlCommand, _ string) error { c.get = true; return nil }},
+	"form": flagOption("F", true),
+	"form-string": {true, func(c *curlCommand, v string) error {
+		if _, value, _ := strings.Cut(v, "="); strings.HasPrefix(value, "@") || strings.HasPrefix(value, "<") {
+			return fmt.Errorf("--form-string %q would be read as a file by httpstat's -F", v)
+		}
+		c.args = append(c.args, "-F", v)
+		return nil
+	}},
+	"head": flagOption("I", false),
+
+	"user":          flagOption("u", true),
+	"basic":         authOption("basic"),
+	"digest":        authOption("digest"),
+	"ntlm":          authOption("ntlm"),
+	"negotiate":     authOption("negotiate"),
+	"oauth2-bearer": flagOption("token", true),
+	"aws-sigv4": {true, func(c *curlCommand, v string) error {
+		// provider1[:provider2[:region[:service]]]
+		p := strings.Split(v, ":")
+		if len(p) != 4 {
+			return fmt.Errorf("--aws-sigv4 %q needs a region and service", v)
+		}
+		c.args = append(c.args, "-aws-sigv4", p[2]+"/"+p[3])
+		return nil
+	}},
+	"netrc":      flagOption("netrc", false),
+	"netrc-file": flagOption("netrc-file", true),
+
+	"user-agent": headerOption("User-Agent"),
+	"referer": {true, func(c *curlCommand, v string) error {
+		if v = strings.TrimSuffix(strings.TrimSuffix(v, "auto"), ";"); v != "" {
+			c.addHeader("Referer: " + v)
+		}
+		return nil
+	}},
+	"cookie": {true, func(c *curlCommand, v string) error {
+		if !strings.Contains(v, "=") {
+			v = "@" + v // a cookie file
+		}
+		c.args = append(c.args, "-b", v)
+		return nil
+	}},
+	"cookie-jar": flagOption("cookie-jar", true),
+	"compressed": flagOption("compressed", false),
+	"range":      flagOption("range", true),
+
+	"location":         flagOption("L", false),
+	"location-trusted": flagOption("L", false),
+	"insecure":         flagOption("k", false),
+	"ipv4":             flagOption("4", false),
+	"ipv6":             flagOption("6", false),
+	"output":           flagOption("o", true),
+	"remote-name":      flagOption("O", false),
+	"continue-at":      flagOption("C", true),
+	"write-out":        flagOption("write-out", true),
+	"max-time":         secondsOption("max-time", "m"),
+	"connect-timeout":  secondsOption("connect-timeout", "connect-timeout"),
+
+	"cert": {true, func(c *curlCommand, v string) error {
+		cert, pass, _ := strings.Cut(v, ":")
+		c.args = append(c.args, "-E", cert)
+		if pass != "" {
+			if strings.HasSuffix(cert, ".p12") || strings.HasSuffix(cert, ".pfx") {
+				c.args = append(c.args, "-cert-pass", pass)
+			} else {
+				c.args = append(c.args, "-key-pass", pass)
+			}
+		}
+		return nil
+	}},
+	"key":    flagOption("key", true),
+	"pass":   flagOption("key-pass", true),
+	"cacert": flagOption("cacert", true),
+	"pinnedpubkey": {true, func(c *curlCommand, v string) error {
+		for _, pin := range strings.Split(v, ";") {
+			if !strings.HasPrefix(pin, "sha256//") {
+				return fmt.Errorf("--pinnedpubkey %q: only sha256// hashes are supported", pin)
+			}
+			c.args = append(c.args, "-pin", pin)
+		}
+		return nil
+	}},
+	"tlsv1.0": tlsOption("1.0"),
+	"tlsv1.1": tlsOption("1.1"),
+	"tlsv1.2": tlsOption("1.2"),
+	"tlsv1.3": tlsOption("1.3"),
+	"tls-max": flagOption("tls-max", true),
+
+	"proxy":      {true, func(c *curlCommand, v string) error { c.proxy = v; return nil }},
+	"noproxy":    {true, func(c *curlCommand, v string) error { c.noProxy = v; return nil }},
+	"proxy-user": flagOption("proxy-user", true),
+
+	"silent":            ignoredOption,
+	"show-error":        ignoredOption,
+	"verbose":           ignoredOption,
+	"include":           ignoredOption,
+	"fail":              ignoredOption,
+	"fail-with-body":    ignoredOption,
+	"globoff":           ignoredOption,
+	"progress-bar":      ignoredOption,
+	"no-progress-meter": ignoredOption,
+	"no-buffer":         ignoredOption,
+}
+
+// curlShort are curl's short options, with the long ones they are the
+// same as.
+var curlShort = map[byte]string{
+	'X': "request", 'H': "header", 'd': "data", 'G': "get", 'F': "form", 'I': "head",
+	'u': "user", 'n': "netrc", 'A': "user-agent", 'e': "referer", 'b': "cookie", 'c': "cookie-jar",
+	'r': "range", 'L': "location", 'k': "insecure", '4': "ipv4", '6': "ipv6", 'o': "output",
+	'O': "remote-name", 'C': "continue-at", 'w': "write-out", 'm': "max-time", 'E': "cert",
+	'x': "proxy", 's': "silent", 'S': "show-error", 'v': "verbose", 'i': "include", 'f': "fail",
+	'g': "globoff", '#': "progress-bar", 'N': "no-buffer", 'U': "proxy-user",
+	// httpstat has no equivalent of these.
+	'0': "http1.0", 'K': "config", 'T': "upload-file", 'z': "time-cond",
+	'Y': "speed-limit", 'y': "speed-time", 'D': "dump-header", 'j': "junk-session-cookies",
+}
+
+// curlValued are the options of curl httpstat has no equivalent of
+// that take a value, to skip it along with them.
+var curlValued = map[string]bool{
+	"config": true, "upload-file": true, "time-cond": true, "speed-limit": true,
+	"speed-time": true, "dump-header": true, "resolve": true, "connect-to": true,
+	"max-redirs": true, "retry": true, "retry-delay": true, "retry-max-time": true, "limit-rate": true,
+	"interface": true, "cert-type": true, "key-type": true, "ciphers": true, "capath": true,
+	"crlfile": true, "dns-servers": true, "trace": true, "trace-ascii": true, "output-dir": true,
+	"unix-socket": true, "abstract-unix-socket": true, "proto": true, "proto-redir": true,
+	"local-port": true, "keepalive-time": true, "expect100-timeout": true, "max-filesize": true,
+	"proxy-header": true, "proxy-cacert": true, "request-target": true, "stderr": true,
+	"url-query": true, "variable": true, "happy-eyeballs-timeout-ms": true, "tls13-ciphers": true,
+}
+
+func keepData(v string) (string, error) {
+	return v, nil
+}
+
+// urlencodeData translates a --data-urlencode value, content, =content
+// or name=content, encoding the content.
+func urlencodeData(v string) (string, error) {
+	name, content, ok := strings.Cut(v, "=")
+	if !ok {
+		if strings.Contains(v, "@") {
+			return v, fmt.Errorf("--data-urlencode %q reads a file, which isn't supported", v)
+		}
+		return url.QueryEscape(v), nil
+	}
+	if name == "" {
+		return url.QueryEscape(content), nil
+	}
+	return name + "=" + url.QueryEscape(content), nil
+}
+
+// parseCurl translates the words of a curl command line, with or without
+// the leading curl, to httpstat's flags.
+func parseCurl(words []string) (*curlCommand, error) {
+	if len(words) > 0 && (words[0] == "curl" || strings.HasSuffix(words[0], "/curl")) {
+		words = words[1:]
+	}
+	c := &curlCommand{}
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		var names []string
+		value := ""
+		valued := false
+		switch {
+		case w == "--":
+			for _, u := range words[i+1:] {
+				c.setURL(u)
+			}
+			i = len(words)
+			continue
+		case strings.HasPrefix(w, "--"):
+			names = []string{w[2:]}
+		case strings.HasPrefix(w, "-") && len(w) > 1:
+			// short options may be run together, with the value of the
+			// last attached: -sSL, -XPOST
+			for j := 1; j < len(w); j++ {
+				long, ok := curlShort[w[j]]
+				if !ok {
+					c.warnings = append(c.warnings, fmt.Sprintf("ignoring unsupported curl option -%c", w[j]))
+					continue
+				}
+				names = append(names, long)
+				if curlOptions[long].value || curlValued[long] {
+					if j+1 < len(w) {
+						value, valued = w[j+1:], true
+					}
+					break
+				}
+			}
+		default:
+			c.setURL(w)
+			continue
+		}
+
+		for _, name := range names {
+			opt, ok := curlOptions[name]
+			takesValue := opt.value || curlValued[name]
+			if takesValue && !valued {
+				if i+1 == len(words) {
+					return nil, fmt.Errorf("curl option --%s needs a value", name)
+				}
+				i++
+				value = words[i]
+			}
+			if !ok {
+				warning := "ignoring unsupported curl option --" + name
+				if takesValue {
+					warning += " " + value
+				}
+				c.warnings = append(c.warnings, warning)
+				continue
+			}
+			if err := opt.translate(c, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.url == "" {
+		return nil, fmt.Errorf("no URL in the curl command")
+	}
+
+	if len(c.data) > 0 {
+		if len(c.data) > 1 {
+			for _, d := range c.data {
+				if strings.HasPrefix(d, "@") {
+					return nil, fmt.Errorf("a body read from a file can't be joined to others with &")
+				}
+			}
+		}
+		body := strings.Join(c.data, "&")
+		if c.get {
+			sep := "?"
+			if strings.Contains(c.url, "?") {
+				sep = "&"
+			}
+			c.url += sep + body
+		} else {
+			c.args = append(c.args, "-d", body)
+			if c.method == "" {
+				c.method = "POST"
+			}
+			switch {
+			case c.json:
+				if !c.headers.has("Content-Type") {
+					c.addHeader("Content-Type: application/json")
+				}
+				if !c.headers.has("Accept") {
+					c.addHeader("Accept: application/json")
+				}
+			case !c.headers.has("Content-Type"):
+				c.addHeader("Content-Type: application/x-www-form-urlencoded")
+			}
+		}
+	}
+	if c.method != "" {
+		c.args = append(c.args, "-X", c.method)
+	}
+	return c, nil
+}
+
+// setURL sets the URL of the command, warning of any after the first,
+// which curl would also request.
+func (c *curlCommand) setURL(u string) {
+	if c.url != "" {
+		c.warnings = append(c.warnings, "ignoring all but the first URL, "+u)
+		return
+	}
+	c.url = u
+}
+
+// runFromCurl is the from-curl subcommand, which makes the request of a
+// curl command line, such as one copied from a browser's developer
+// tools, timing it as httpstat does.
+func runFromCurl(args []string) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s from-curl [OPTIONS] 'curl ...'\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Make the request of a curl command line, such as one copied with \"Copy as cURL\" in a browser's")
+		fmt.Fprintln(os.Stderr, "developer tools, translating its options to httpstat's and warning of those it can't.")
+		fmt.Fprintln(os.Stderr, "The command can be given as one quoted argument, or - to read it from stdin.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	words := flag.Args()
+	if len(words) == 1 {
+		line := words[0]
+		if line == "-" {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatalf("unable to read curl command: %v", err)
+			}
+			line = string(b)
+		}
+		var err error
+		if words, err = shellWords(line); err != nil {
+			log.Fatalf("unable to parse curl command: %v", err)
+		}
+	}
+	c, err := parseCurl(words)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, w := range c.warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], w)
+	}
+
+	if c.proxy != "" {
+		if !strings.Contains(c.proxy, "://") {
+			c.proxy = "http://" + c.proxy
+		}
+		os.Setenv("HTTP_PROXY", c.proxy)
+		os.Setenv("HTTPS_PROXY", c.proxy)
+	}
+	if c.noProxy != "" {
+		os.Setenv("NO_PROXY", c.noProxy)
+	}
+	if err := flag.CommandLine.Parse(c.args); err != nil {
+		log.Fatal(err)
+	}
+	measure(c.url)
+}
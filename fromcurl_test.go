@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`curl https://example.com/`, []string{"curl", "https://example.com/"}},
+		{"curl 'https://example.com/?a=1&b=2' \\\n  -H 'accept: */*' \\\r\n  --compressed",
+			[]string{"curl", "https://example.com/?a=1&b=2", "-H", "accept: */*", "--compressed"}},
+		{`-H "x: \"quoted\" \$HOME \n"`, []string{"-H", `x: "quoted" $HOME \n`}},
+		{`--data-raw $'{"a":"it\'s\\né\x41\101"}' -X POST`, []string{"--data-raw", "{\"a\":\"it's\\néAA\"}", "-X", "POST"}},
+		{`a''b "c"'d' e\ f`, []string{"ab", "cd", "e f"}},
+		{`''`, []string{""}},
+	}
+	for _, tt := range tests {
+		got, err := shellWords(tt.line)
+		if err != nil {
+			t.Errorf("shellWords(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{`curl 'x`, `curl "x`, `curl $'x`} {
+		if _, err := shellWords(line); err == nil {
+			t.Errorf("shellWords(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		words    []string
+		args     []string
+		url      string
+		warnings int
+	}{
+		{
+			[]string{"curl", "https://example.com/", "-H", "accept: */*", "--compressed", "-sSL"},
+			[]string{"-H", "accept: */*", "-compressed", "-L"},
+			"https://example.com/", 0,
+		},
+		{
+			[]string{"curl", "-XPUT", "--data-raw", `{"a":1}`, "-H", "Content-Type: application/json", "-u", "me:pw", "--digest", "https://example.com/"},
+			[]string{"-H", "Content-Type: application/json", "-u", "me:pw", "-auth", "digest", "-d", `{"a":1}`, "-X", "PUT"},
+			"https://example.com/", 0,
+		},
+		{
+			[]string{"-d", "a=1", "--data-urlencode", "q=x y", "https://example.com/"},
+			[]string{"-d", "a=1&q=x+y", "-H", "Content-Type: application/x-www-form-urlencoded", "-X", "POST"},
+			"https://example.com/", 0,
+		},
+		{
+			[]string{"-G", "-d", "a=1", "https://example.com/?b=2"},
+			nil,
+			"https://example.com/?b=2&a=1", 0,
+		},
+		{
+			[]string{"--json", `{}`, "-b", "cookies.txt", "-m", "2.5", "--connect-timeout", "1", "https://example.com/"},
+			[]string{"-b", "@cookies.txt", "-m", "2.5s", "-connect-timeout", "1s", "-d", "{}", "-H", "Content-Type: application/json", "-H", "Accept: application/json", "-X", "POST"},
+			"https://example.com/", 0,
+		},
+		{
+			[]string{"--max-redirs", "5", "--http2", "-Z", "--url", "https://example.com/", "https://example.org/"},
+			nil,
+			"https://example.com/", 4,
+		},
+	}
+	for _, tt := range tests {
+		c, err := parseCurl(tt.words)
+		if err != nil {
+			t.Errorf("parseCurl(%q): %v", tt.words, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.args, tt.args) || c.url != tt.url || len(c.warnings) != tt.warnings {
+			t.Errorf("parseCurl(%q) = %q %s %q, want %q %s and %d warnings", tt.words, c.args, c.url, c.warnings, tt.args, tt.url, tt.warnings)
+		}
+	}
+
+	for _, words := range [][]string{
+		{"curl", "-H"},
+		{"curl", "-X", "GET"},
+		{"curl", "--data-raw", "@file", "https://example.com/"},
+		{"curl", "-d", "@file", "-d", "a=1", "https://example.com/"},
+	} {
+		if _, err := parseCurl(words); err == nil {
+			t.Errorf("parseCurl(%q) succeeded, want an error", words)
+		}
+	}
+}
+
+func TestParseCurlProxy(t *testing.T) {
+	c, err := parseCurl([]string{"curl", "-x", "proxy:3128", "--noproxy", "localhost", "-U", "alice:keyring:proxy", "http://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.proxy != "proxy:3128" || c.noProxy != "localhost" || !reflect.DeepEqual(c.args, []string{"-proxy-user", "alice:keyring:proxy"}) {
+		t.Errorf("proxy %q, no proxy %q, args %q", c.proxy, c.noProxy, c.args)
+	}
+}
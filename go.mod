@@ -1,10 +1,22 @@
 module github.com/httpstat
 
+go 1.23
+
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/andybalholm/brotli v1.1.0
 	github.com/fatih/color v1.7.0
-	github.com/mattn/go-colorable v0.0.9
-	github.com/mattn/go-isatty v0.0.4
-	golang.org/x/net v0.0.0-20180921000356-2f5d2388922f
-	golang.org/x/sys v0.0.0-20180920110915-d641721ec2de
-	golang.org/x/text v0.3.0
+	github.com/klauspost/compress v1.17.9
+	github.com/miekg/pkcs11 v1.1.1
+	golang.org/x/crypto v0.28.0
+	golang.org/x/net v0.21.0
+	golang.org/x/term v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/mattn/go-colorable v0.0.9 // indirect
+	github.com/mattn/go-isatty v0.0.4 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
 )
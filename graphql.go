@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"strings"
+)
+
+// readArgument returns s, or the contents of the file named by s if it
+// starts with @, as for -d.
+func readArgument(s string) (string, error) {
+	if !strings.HasPrefix(s, "@") {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(s[1:])
+	return string(b), err
+}
+
+// graphqlBody builds the JSON body of a GraphQL request from the query
+// and variables given to -graphql and -graphql-vars.
+func graphqlBody(query, vars string) (string, error) {
+	q, err := readArgument(query)
+	if err != nil {
+		return "", fmt.Errorf("unable to read GraphQL query: %v", err)
+	}
+	req := struct {
+		Query     string          `json:"query"`
+		Variables json.RawMessage `json:"variables,omitempty"`
+	}{Query: q}
+
+	if vars != "" {
+		v, err := readArgument(vars)
+		if err != nil {
+			return "", fmt.Errorf("unable to read GraphQL variables: %v", err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &m); err != nil {
+			return "", fmt.Errorf("GraphQL variables must be a JSON object: %v", err)
+		}
+		req.Variables = json.RawMessage(v)
+	}
+
+	b, err := json.Marshal(req)
+	return string(b), err
+}
+
+// isJSON reports whether contentType is a JSON media type.
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// graphqlErrors returns the messages of the errors in a GraphQL
+// response, with the path of the field each applies to.
+func graphqlErrors(body []byte) ([]string, error) {
+	var resp struct {
+		Errors []struct {
+			Message string
+			Path    []interface{}
+		}
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid GraphQL response: %v", err)
+	}
+	var errs []string
+	for _, e := range resp.Errors {
+		msg := e.Message
+		if len(e.Path) > 0 {
+			path := make([]string, len(e.Path))
+			for i, p := range e.Path {
+				path[i] = fmt.Sprint(p)
+			}
+			msg += " (at " + strings.Join(path, ".") + ")"
+		}
+		errs = append(errs, msg)
+	}
+	return errs, nil
+}
+
+func printGraphQLErrors(errs []string) {
+	printf("\n%s\n", errorString("GraphQL errors:"))
+	for _, e := range errs {
+		printf("  %s\n", e)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphqlBody(t *testing.T) {
+	got, err := graphqlBody("{ user(id: $id) { name } }", `{"id": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"query":"{ user(id: $id) { name } }","variables":{"id":1}}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	got, _ = graphqlBody("{ me }", "")
+	if want := `{"query":"{ me }"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err := graphqlBody("{ me }", "[1]"); err == nil {
+		t.Error("expected an error for variables which aren't an object")
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/json":                  true,
+		"application/json; charset=utf-8":   true,
+		"application/graphql-response+json": true,
+		"text/html":                         false,
+		"":                                  false,
+	} {
+		if got := isJSON(ct); got != want {
+			t.Errorf("isJSON(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestGraphqlErrors(t *testing.T) {
+	errs, err := graphqlErrors([]byte(`{"data":null,"errors":[{"message":"boom","path":["a",0,"b"]},{"message":"bad"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"boom (at a.0.b)", "bad"}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("got %q, want %q", errs, want)
+	}
+
+	if errs, _ := graphqlErrors([]byte(`{"data":{"me":1}}`)); len(errs) != 0 {
+		t.Errorf("got %q for a response without errors", errs)
+	}
+	if _, err := graphqlErrors([]byte(`<html>`)); err == nil {
+		t.Error("expected an error for an invalid response")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/net/http2"
+)
+
+// grpcHealthPath is the method called by -grpc, from the standard gRPC
+// health checking protocol.
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// grpcServingStatus names the HealthCheckResponse.ServingStatus values.
+var grpcServingStatus = []string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+// grpcCodes names the gRPC status codes.
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// GRPCHealth is the outcome of a gRPC health check.
+type GRPCHealth struct {
+	Service string `json:",omitempty"`
+	Status  string // the serving status, empty if the call failed
+	Code    int    // the grpc-status of the call
+	Message string `json:",omitempty"`
+	Call    int    // milliseconds from sending the request to the trailers
+}
+
+// serving reports whether the service is healthy.
+func (g *GRPCHealth) serving() bool {
+	return g.Code == 0 && g.Status == "SERVING"
+}
+
+// setGRPCHealthRequest turns req into a unary call of the health
+// check method for service; the empty service is the whole server.
+func setGRPCHealthRequest(req *http.Request, service string) {
+	// HealthCheckRequest has a single string field, number 1.
+	var msg []byte
+	if service != "" {
+		msg = binary.AppendUvarint([]byte{0x0a}, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+	body := grpcFrame(msg)
+
+	u := *req.URL
+	u.Path, u.RawPath, u.RawQuery = grpcHealthPath, "", ""
+	req.URL = &u
+	req.Method = http.MethodPost
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// grpcFrame prefixes an uncompressed message with its length, as gRPC
+// sends messages over HTTP/2.
+func grpcFrame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// h2cTransport speaks HTTP/2 without TLS, as gRPC does for http URLs,
+// dialing with dial if set.
+func h2cTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) http.RoundTripper {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+}
+
+// readGRPCHealth reads the health check response for service from resp.
+func readGRPCHealth(resp *http.Response, service string) *GRPCHealth {
+	g := &GRPCHealth{Service: service, Code: -1}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		g.Message = fmt.Sprintf("failed to read response: %v", err)
+		return g
+	}
+
+	// a call failing straight away sends its status in the headers.
+	status := resp.Trailer.Get("Grpc-Status")
+	g.Message = resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		g.Message = resp.Header.Get("Grpc-Message")
+	}
+	if status == "" {
+		g.Message = "not a gRPC response: " + resp.Status
+		return g
+	}
+	g.Code, err = strconv.Atoi(status)
+	if err != nil {
+		g.Code = -1
+		g.Message = fmt.Sprintf("invalid grpc-status %q", status)
+		return g
+	}
+	if m, err := url.PathUnescape(g.Message); err == nil {
+		g.Message = m
+	}
+	if g.Code != 0 {
+		return g
+	}
+
+	s, err := parseHealthCheckResponse(body)
+	if err != nil {
+		g.Message = err.Error()
+		return g
+	}
+	g.Status = strconv.Itoa(s)
+	if s < len(grpcServingStatus) {
+		g.Status = grpcServingStatus[s]
+	}
+	return g
+}
+
+// parseHealthCheckResponse returns the status, field 1, of the single
+// HealthCheckResponse message in body.
+func parseHealthCheckResponse(body []byte) (int, error) {
+	if len(body) < 5 {
+		return 0, fmt.Errorf("short gRPC response of %d bytes", len(body))
+	}
+	if body[0] != 0 {
+		return 0, fmt.Errorf("compressed gRPC responses are not supported")
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	msg := body[5:]
+	if uint32(len(msg)) != n {
+		return 0, fmt.Errorf("gRPC message is %d bytes, expected %d", len(msg), n)
+	}
+
+	status := 0 // proto3 omits the default, UNKNOWN
+	for len(msg) > 0 {
+		key, k := binary.Uvarint(msg)
+		if k <= 0 {
+			return 0, fmt.Errorf("malformed HealthCheckResponse")
+		}
+		msg = msg[k:]
+		switch key & 7 {
+		case 0:
+			v, k := binary.Uvarint(msg)
+			if k <= 0 {
+				return 0, fmt.Errorf("malformed HealthCheckResponse")
+			}
+			msg = msg[k:]
+			if key>>3 == 1 {
+				status = int(v)
+			}
+		case 2:
+			l, k := binary.Uvarint(msg)
+			if k <= 0 || uint64(len(msg)-k) < l {
+				return 0, fmt.Errorf("malformed HealthCheckResponse")
+			}
+			msg = msg[k+int(l):]
+		default:
+			return 0, fmt.Errorf("unexpected wire type %d in HealthCheckResponse", key&7)
+		}
+	}
+	return status, nil
+}
+
+func printGRPCHealth(g *GRPCHealth) {
+	label := labelString
+	target := "server"
+	if g.Service != "" {
+		target = "service " + g.Service
+	}
+	switch {
+	case g.Code == -1:
+		printf("\n%s %s\n", label("gRPC health:"), errorString(g.Message))
+		return
+	case g.Code != 0:
+		code := strconv.Itoa(g.Code)
+		if g.Code < len(grpcCodes) {
+			code = grpcCodes[g.Code]
+		}
+		msg := code
+		if g.Message != "" {
+			msg += ": " + g.Message
+		}
+		printf("\n%s %s\n", label("gRPC health:"), errorString(msg))
+	case g.serving():
+		printf("\n%s %s %s\n", label("gRPC health:"), okString(g.Status), label("(%s)", target))
+	default:
+		printf("\n%s %s %s\n", label("gRPC health:"), errorString(g.Status), label("(%s)", target))
+	}
+	printf("%s %s\n", label("gRPC call:"), valueString("%dms", g.Call))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestSetGRPCHealthRequest(t *testing.T) {
+	u, _ := url.Parse("http://example.com/ignored?q=1")
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	setGRPCHealthRequest(req, "svc")
+
+	if req.Method != "POST" || req.URL.String() != "http://example.com"+grpcHealthPath {
+		t.Errorf("got %s %s", req.Method, req.URL)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	want := []byte{0, 0, 0, 0, 5, 0x0a, 3, 's', 'v', 'c'}
+	if !bytes.Equal(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+	if req.Header.Get("Content-Type") != "application/grpc" || req.Header.Get("TE") != "trailers" {
+		t.Errorf("missing gRPC headers: %v", req.Header)
+	}
+}
+
+func TestParseHealthCheckResponse(t *testing.T) {
+	tests := []struct {
+		body    []byte
+		status  int
+		wantErr bool
+	}{
+		{[]byte{0, 0, 0, 0, 2, 8, 1}, 1, false},
+		{[]byte{0, 0, 0, 0, 0}, 0, false},
+		// unknown fields are skipped
+		{[]byte{0, 0, 0, 0, 6, 0x12, 2, 'h', 'i', 8, 2}, 2, false},
+		{[]byte{0, 0, 0, 0, 3, 8, 1}, 0, true},
+		{[]byte{1, 0, 0, 0, 2, 8, 1}, 0, true},
+		{[]byte{0, 0}, 0, true},
+	}
+	for _, tt := range tests {
+		status, err := parseHealthCheckResponse(tt.body)
+		if (err != nil) != tt.wantErr || status != tt.status {
+			t.Errorf("parseHealthCheckResponse(%v) = %d, %v", tt.body, status, err)
+		}
+	}
+}
+
+func TestReadGRPCHealth(t *testing.T) {
+	resp := &http.Response{
+		Status:  "200 OK",
+		Header:  http.Header{},
+		Body:    ioutil.NopCloser(bytes.NewReader([]byte{0, 0, 0, 0, 2, 8, 2})),
+		Trailer: http.Header{"Grpc-Status": {"0"}},
+	}
+	if g := readGRPCHealth(resp, "db"); g.Status != "NOT_SERVING" || g.serving() {
+		t.Errorf("got %+v, want NOT_SERVING", g)
+	}
+
+	// trailers-only responses carry the status in the headers.
+	resp = &http.Response{
+		Status: "200 OK",
+		Header: http.Header{"Grpc-Status": {"12"}, "Grpc-Message": {"unknown%20method"}},
+		Body:   ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+	if g := readGRPCHealth(resp, ""); g.Code != 12 || g.Message != "unknown method" {
+		t.Errorf("got %+v, want UNIMPLEMENTED", g)
+	}
+
+	resp = &http.Response{Status: "404 Not Found", Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+	if g := readGRPCHealth(resp, ""); g.Code != -1 {
+		t.Errorf("got %+v for a non-gRPC response", g)
+	}
+}
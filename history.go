@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// historyEntry is a request recorded by -record.
+type historyEntry struct {
+	Time   time.Time
+	URL    string
+	Report Report
+}
+
+// historyPath is the file -record appends to.
+func historyPath() string {
+	return dataPath("history.jsonl")
+}
+
+// recordHistory adds the report of a request to u to the history file.
+func recordHistory(filename string, u *url.URL, report *Report) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return appendJSONLine(filename, historyEntry{Time: report.start, URL: u.String(), Report: *report})
+}
+
+// historyFilter selects the entries listed by the history subcommand.
+type historyFilter struct {
+	url    string // a URL, or the start of one
+	since  time.Time
+	status string // a status code, or its class such as 5xx
+}
+
+func (f historyFilter) match(e historyEntry) bool {
+	if f.url != "" && !strings.HasPrefix(e.URL, f.url) {
+		return false
+	}
+	if e.Time.Before(f.since) {
+		return false
+	}
+	if code := statusCode(e.Report.Status); f.status != "" {
+		if strings.HasSuffix(f.status, "xx") {
+			return len(code) == 3 && code[0] == f.status[0]
+		}
+		return code == f.status
+	}
+	return true
+}
+
+// readHistory returns the entries of the history file matching f.
+func readHistory(filename string, f historyFilter) ([]historyEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	s := bufio.NewScanner(file)
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		var e historyEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, n, err)
+		}
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, s.Err()
+}
+
+// runHistory is the history subcommand, which lists and summarizes the
+// requests recorded with -record.
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history [OPTIONS] [URL]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "List and summarize the requests recorded with -record, to URL or URLs starting with it.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	filename := fs.String("file", historyPath(), "history file to read")
+	since := fs.Duration("since", 0, "only requests made within this duration, eg. 24h")
+	status := fs.String("status", "", "only requests with this status code, or class such as 5xx")
+	last := fs.Int("n", 20, "list this many of the most recent requests; 0 lists none, only the summary")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	filter := historyFilter{url: fs.Arg(0), status: *status}
+	if *since > 0 {
+		filter.since = time.Now().Add(-*since)
+	}
+	entries, err := readHistory(*filename, filter)
+	if err != nil {
+		log.Fatalf("unable to read history: %v", err)
+	}
+	if len(entries) == 0 {
+		printf("%s\n", warnString("No recorded requests."))
+		return
+	}
+
+	scheme := "http"
+	reports := make([]Report, len(entries))
+	for i, e := range entries {
+		reports[i] = e.Report
+		if strings.HasPrefix(e.URL, "https:") {
+			scheme = "https"
+		}
+	}
+	for _, e := range entries[max(len(entries)-*last, 0):] {
+		printHistoryEntry(e)
+	}
+	printSummary(summarize(reports), scheme)
+}
+
+func printHistoryEntry(e historyEntry) {
+	t := e.Report.Timing
+	status := statusString("%s", statusCode(e.Report.Status))
+	if code := statusCode(e.Report.Status); code >= "400" {
+		status = errorString("%s", code)
+	}
+	phases := fmt.Sprintf("dns %d tcp %d", t.DNS, t.TCP)
+	if strings.HasPrefix(e.URL, "https:") {
+		phases += fmt.Sprintf(" tls %d", t.TLS)
+	}
+	phases += fmt.Sprintf(" server %d transfer %d", t.Server, t.Transfer)
+	printf("%s  %s  %s  %s  %s\n",
+		labelString(e.Time.Local().Format("2006-01-02 15:04:05")), status,
+		valueString("%6dms", t.Total), labelString(phases), e.URL)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "httpstat", "history.jsonl")
+	now := time.Now()
+	for _, e := range []struct {
+		url, status string
+		age         time.Duration
+	}{
+		{"https://example.com/", "200 OK", 48 * time.Hour},
+		{"https://example.com/api", "503 Service Unavailable", time.Hour},
+		{"https://example.org/", "200 OK", time.Minute},
+	} {
+		u, _ := url.Parse(e.url)
+		r := &Report{Status: e.status, start: now.Add(-e.age)}
+		if err := recordHistory(filename, u, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		filter historyFilter
+		want   int
+	}{
+		{historyFilter{}, 3},
+		{historyFilter{url: "https://example.com/"}, 2},
+		{historyFilter{url: "https://example.com/api"}, 1},
+		{historyFilter{since: now.Add(-2 * time.Hour)}, 2},
+		{historyFilter{status: "5xx"}, 1},
+		{historyFilter{status: "200"}, 2},
+		{historyFilter{url: "https://example.org/", status: "503"}, 0},
+	} {
+		entries, err := readHistory(filename, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%+v: got %d entries, want %d", tt.filter, len(entries), tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// htmlBins is how many bars the latency distribution of an HTML report
+// has at most.
+const htmlBins = 20
+
+// htmlPage is what an HTML report shows.
+type htmlPage struct {
+	URL       string
+	Generated string
+	Status    []htmlStatus
+	Summary   []htmlStats // empty for a single request
+	Histogram []htmlBin
+	Fastest   string // the ends of the histogram
+	Slowest   string
+	Requests  []htmlRequest
+}
+
+type htmlStatus struct {
+	Status string
+	Count  int
+	Error  bool
+}
+
+type htmlStats struct {
+	Phase                   string
+	Min, Avg, P50, P95, Max string
+}
+
+// htmlBin is a bar of the latency distribution, positioned in percent
+// of the chart.
+type htmlBin struct {
+	X, Width, Height float64
+	From, To         string
+	Count            int
+}
+
+type htmlRequest struct {
+	N      int
+	Time   string
+	Report Report
+	Total  string
+	Error  bool
+	Phases []htmlPhase
+}
+
+// htmlPhase is a bar of a request's waterfall, positioned in percent of
+// the slowest request.
+type htmlPhase struct {
+	Name        string
+	Class       string
+	Left, Width float64
+	Time        string
+}
+
+// newHTMLPage lays out the report of the requests made to u.
+func newHTMLPage(u *url.URL, reports []Report, generated time.Time) *htmlPage {
+	p := &htmlPage{URL: u.String(), Generated: generated.Format(time.RFC1123)}
+
+	statuses := map[string]int{}
+	for _, r := range reports {
+		statuses[r.Status]++
+	}
+	for status, n := range statuses {
+		p.Status = append(p.Status, htmlStatus{status, n, statusCode(status) >= "400"})
+	}
+	sort.Slice(p.Status, func(i, j int) bool { return p.Status[i].Status < p.Status[j].Status })
+
+	ms := "%.1fms"
+	if timingPrecision == "us" {
+		ms = "%.3fms"
+	}
+	if len(reports) > 1 {
+		s := summarize(reports)
+		for _, phase := range summaryPhases {
+			if phase.name == "TLS Handshake" && u.Scheme != "https" {
+				continue
+			}
+			st := phase.stats(s)
+			p.Summary = append(p.Summary, htmlStats{phase.name,
+				fmt.Sprintf(ms, st.Min), fmt.Sprintf(ms, st.Avg), fmt.Sprintf(ms, st.P50),
+				fmt.Sprintf(ms, st.P95), fmt.Sprintf(ms, st.Max)})
+		}
+		p.Histogram = latencyHistogram(phaseTimes(reports, func(t Timing) int { return t.Total }), htmlBins)
+		p.Fastest, p.Slowest = p.Histogram[0].From, p.Histogram[len(p.Histogram)-1].To
+	}
+
+	slowest := 1
+	for _, r := range reports {
+		slowest = max(slowest, r.micros.Total)
+	}
+	for i, r := range reports {
+		req := htmlRequest{
+			N:      i + 1,
+			Report: r,
+			Total:  formatTiming(r.micros.Total),
+			Error:  statusCode(r.Status) >= "400",
+		}
+		if !r.start.IsZero() {
+			req.Time = r.start.Format(timestampFormat)
+		}
+		t := r.micros
+		start := 0
+		for _, ph := range []struct {
+			name, class string
+			us          int
+		}{
+			{"DNS Lookup", "dns", t.DNS},
+			{"TCP Connection", "tcp", t.TCP},
+			{"TLS Handshake", "tls", t.TLS},
+			{"Server Processing", "server", t.Server},
+			{"Content Transfer", "transfer", t.Transfer},
+		} {
+			if ph.class == "tls" && u.Scheme != "https" {
+				continue
+			}
+			req.Phases = append(req.Phases, htmlPhase{ph.name, ph.class,
+				100 * float64(start) / float64(slowest), 100 * float64(ph.us) / float64(slowest),
+				formatTiming(ph.us)})
+			start += ph.us
+		}
+		p.Requests = append(p.Requests, req)
+	}
+	return p
+}
+
+// latencyHistogram counts values, in milliseconds, into at most bins
+// bars of equal width.
+func latencyHistogram(values []float64, bins int) []htmlBin {
+	if len(values) == 0 {
+		return nil
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	n := min(bins, len(values))
+	width := (hi - lo) / float64(n)
+	if width == 0 {
+		n, width = 1, 1
+	}
+	counts := make([]int, n)
+	most := 0
+	for _, v := range values {
+		i := min(int((v-lo)/width), n-1)
+		counts[i]++
+		most = max(most, counts[i])
+	}
+
+	h := make([]htmlBin, n)
+	for i, c := range counts {
+		h[i] = htmlBin{
+			X:      100 * float64(i) / float64(n),
+			Width:  100 / float64(n),
+			Height: 100 * float64(c) / float64(most),
+			From:   fmt.Sprintf("%.1fms", lo+float64(i)*width),
+			To:     fmt.Sprintf("%.1fms", lo+float64(i+1)*width),
+			Count:  c,
+		}
+	}
+	if n == 1 {
+		h[0].To = h[0].From
+	}
+	return h
+}
+
+// writeHTMLReport writes the report of the requests made to u as a
+// single HTML file, with its styles inline so it can be shared as is.
+func writeHTMLReport(filename string, u *url.URL, reports []Report) error {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, newHTMLPage(u, reports, time.Now())); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b.Bytes(), 0644)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>httpstat {{.URL}}</title>
+<style>
+body { font: 14px/1.4 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; margin: 2em auto; max-width: 60em; padding: 0 1em; }
+h1 { font-size: 1.4em; word-break: break-all; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em 0.2em 0; text-align: left; vertical-align: top; }
+td.ms, th.ms { text-align: right; }
+code, .mono { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+.muted { color: #777; }
+.ok { color: #080; }
+.error { color: #c00; }
+.chart { position: relative; height: 10em; border-left: 1px solid #999; border-bottom: 1px solid #999; }
+.chart div { position: absolute; bottom: 0; box-sizing: border-box; border: 1px solid #fff; background: #48c; }
+.chart div:hover { background: #26a; }
+.axis { display: flex; justify-content: space-between; }
+details { border-top: 1px solid #ddd; padding: 0.4em 0; }
+summary { cursor: pointer; display: flex; align-items: center; gap: 1em; }
+summary .n { width: 3em; }
+summary .status { width: 12em; }
+summary .total { width: 6em; text-align: right; }
+.waterfall { position: relative; flex: 1; height: 1em; }
+.waterfall span { position: absolute; top: 0; bottom: 0; min-width: 1px; }
+.dns { background: #7b5; } .tcp { background: #e93; } .tls { background: #a6c; }
+.server { background: #48c; } .transfer { background: #4bb; }
+.key span { display: inline-block; width: 0.8em; height: 0.8em; margin: 0 0.3em 0 1em; }
+.body { padding: 0.5em 0 0.5em 4em; }
+</style>
+</head>
+<body>
+<h1>{{.URL}}</h1>
+<p class="muted">{{len .Requests}} request{{if ne (len .Requests) 1}}s{{end}}, reported by httpstat on {{.Generated}}.
+{{range .Status}}<span class="{{if .Error}}error{{else}}ok{{end}}">{{.Count}} &times; {{.Status}}</span> {{end}}</p>
+{{if .Summary}}
+<h2>Summary</h2>
+<table>
+<tr><th></th><th class="ms">min</th><th class="ms">avg</th><th class="ms">p50</th><th class="ms">p95</th><th class="ms">max</th></tr>
+{{range .Summary}}<tr><th>{{.Phase}}</th><td class="ms">{{.Min}}</td><td class="ms">{{.Avg}}</td><td class="ms">{{.P50}}</td><td class="ms">{{.P95}}</td><td class="ms">{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Histogram}}
+<h2>Total time distribution</h2>
+<div class="chart">
+{{range .}}<div style="left: {{printf "%.2f" .X}}%; width: {{printf "%.2f" .Width}}%; height: {{printf "%.2f" .Height}}%" title="{{.Count}} request{{if ne .Count 1}}s{{end}}, {{.From}} to {{.To}}"></div>
+{{end}}</div>
+{{end}}
+{{with .Histogram}}<div class="axis muted"><span>{{$.Fastest}}</span><span>{{$.Slowest}}</span></div>{{end}}
+<h2>Requests</h2>
+<p class="key muted"><span class="dns"></span>DNS Lookup<span class="tcp"></span>TCP Connection<span class="tls"></span>TLS Handshake<span class="server"></span>Server Processing<span class="transfer"></span>Content Transfer</p>
+{{range .Requests}}
+<details{{if eq (len $.Requests) 1}} open{{end}}>
+<summary><span class="n muted">#{{.N}}</span><span class="status {{if .Error}}error{{else}}ok{{end}}">{{.Report.Status}}</span>
+<span class="waterfall">{{range .Phases}}<span class="{{.Class}}" style="left: {{printf "%.2f" .Left}}%; width: {{printf "%.2f" .Width}}%" title="{{.Name}} {{.Time}}"></span>{{end}}</span>
+<span class="total">{{.Total}}</span></summary>
+<div class="body">
+<table>
+{{if .Time}}<tr><th>Sent</th><td>{{.Time}}</td></tr>{{end}}
+<tr><th>Connected to</th><td>{{.Report.Address}}</td></tr>
+<tr><th>Protocol</th><td>{{.Report.Proto}}</td></tr>
+{{range .Phases}}<tr><th>{{.Name}}</th><td class="ms">{{.Time}}</td></tr>
+{{end}}</table>
+{{with .Report.TLS}}
+<h3>TLS</h3>
+<table>
+<tr><th>Version</th><td>{{.Version}}</td></tr>
+<tr><th>Cipher suite</th><td>{{.CipherSuite}}</td></tr>
+{{range $i, $c := .Certificates}}<tr><th>Certificate {{$i}}</th><td>{{.Subject}}<br><span class="muted">issued by {{.Issuer}}, valid until {{.NotAfter.Format "2006-01-02"}}, {{.KeyType}}</span></td></tr>
+{{end}}</table>
+{{end}}
+<h3>Headers</h3>
+<table class="mono">
+{{range $name, $values := .Report.Header}}{{range $values}}<tr><th>{{$name}}</th><td>{{.}}</td></tr>
+{{end}}{{end}}</table>
+{{with .Report.Warnings}}<h3>Warnings</h3>
+<ul>{{range .}}<li class="error">{{.}}</li>{{end}}</ul>
+{{end}}</div>
+</details>
+{{end}}
+</body>
+</html>
+`))
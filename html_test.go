@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := latencyHistogram([]float64{10, 12, 19, 30}, 2)
+	if len(h) != 2 {
+		t.Fatalf("got %d bins, want 2", len(h))
+	}
+	if h[0].Count != 3 || h[1].Count != 1 {
+		t.Errorf("got counts %d and %d, want 3 and 1", h[0].Count, h[1].Count)
+	}
+	if h[0].Height != 100 || h[1].Height != 100.0/3 {
+		t.Errorf("got heights %v and %v", h[0].Height, h[1].Height)
+	}
+	if h[0].From != "10.0ms" || h[1].To != "30.0ms" {
+		t.Errorf("got range %s to %s", h[0].From, h[1].To)
+	}
+
+	h = latencyHistogram([]float64{5, 5, 5}, 20)
+	if len(h) != 1 || h[0].Count != 3 {
+		t.Errorf("equal values give %+v, want a single bin of 3", h)
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	u, _ := url.Parse("https://example.com/<path>")
+	reports := []Report{
+		{Status: "200 OK", Timing: Timing{Total: 10}, Header: http.Header{"Server": {"<test>"}},
+			micros: Timing{DNS: 1000, TCP: 1000, TLS: 2000, Server: 4000, Transfer: 2000, Total: 10000}},
+		{Status: "503 Service Unavailable", Timing: Timing{Total: 20}, TLS: &TLSInfo{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256"},
+			micros: Timing{DNS: 0, TCP: 1000, TLS: 2000, Server: 16000, Transfer: 1000, Total: 20000}},
+	}
+	p := newHTMLPage(u, reports, time.Now())
+	if len(p.Summary) != 6 || len(p.Histogram) != 2 {
+		t.Errorf("got %d summary rows and %d bins, want 6 and 2", len(p.Summary), len(p.Histogram))
+	}
+	server := p.Requests[1].Phases[3]
+	if server.Left != 15 || server.Width != 80 {
+		t.Errorf("server processing of the slowest request at %v%%, %v%% wide, want 15%% and 80%%", server.Left, server.Width)
+	}
+	if !p.Requests[1].Error || p.Requests[0].Error {
+		t.Error("error status not flagged")
+	}
+
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, p); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"&lt;test&gt;", "TLS_AES_128_GCM_SHA256", "503 Service Unavailable", "Total time distribution"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("report doesn't contain %q", s)
+		}
+	}
+	if strings.Contains(b.String(), "<test>") {
+		t.Error("header not escaped")
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringSecret looks up name in the Secret Service keyring, matching
+// items stored with the attribute service=NAME, eg. by
+// secret-tool store --label=NAME service NAME.
+func keyringSecret(name string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", name)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("keyring lookup of %q failed: %s", name, msg)
+		}
+		return "", fmt.Errorf("keyring lookup of %q failed: %v", name, err)
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("no secret %q in the keyring", name)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
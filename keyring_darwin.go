@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringSecret looks up the generic password for service name in the
+// login keychain.
+func keyringSecret(name string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", name, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("no secret %q in the keychain: %v", name, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
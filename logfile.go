@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// appendJSONLine appends v as a line of JSON to the file name, holding
+// a lock on the file so that probes running at the same time don't
+// interleave their lines.
+func appendJSONLine(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("unable to lock %s: %v", name, err)
+	}
+	defer unlockFile(f)
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendJSONLine(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "results.jsonl")
+	for _, status := range []string{"200 OK", "503 Service Unavailable"} {
+		if err := appendJSONLine(name, &Report{Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var statuses []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r Report
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", s.Text(), err)
+		}
+		statuses = append(statuses, r.Status)
+	}
+	if len(statuses) != 2 || statuses[0] != "200 OK" || statuses[1] != "503 Service Unavailable" {
+		t.Errorf("got %q", statuses)
+	}
+}
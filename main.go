@@ -5,10 +5,13 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
@@ -24,18 +27,133 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
+	"golang.org/x/crypto/pkcs12"
 	"golang.org/x/net/http2"
+	"golang.org/x/term"
 )
 
 type Report struct {
+	// Time is when the request was sent, with -timestamps.
+	Time    string `json:",omitempty"`
 	Address string
 	Header  http.Header
 	Proto   string
 	Status  string
 	Timing  Timing
+	// TimingMicros is the timing in microseconds, with -precision us.
+	TimingMicros *Timing   `json:",omitempty"`
+	TLS          *TLSInfo  `json:",omitempty"`
+	OCSP         *OCSPInfo `json:",omitempty"`
+
+	Resumption *Resumption `json:",omitempty"`
+	ECH        *ECHInfo    `json:",omitempty"`
+
+	Warnings []string `json:",omitempty"`
+
+	// AuthRoundTrips holds the timing of exchanges answered with an
+	// authentication challenge before the final response.
+	AuthRoundTrips []Timing `json:",omitempty"`
+
+	// Attempts holds the tries of the request that failed and were
+	// retried with -retry before this one.
+	Attempts []Attempt `json:",omitempty"`
+
+	// CookieAudit lists the problems found with each Set-Cookie header
+	// when -cookie-audit is given.
+	CookieAudit []CookieAudit `json:",omitempty"`
+
+	// Cache is the cache status reported by a CDN or proxy.
+	Cache *CacheInfo `json:",omitempty"`
+
+	// CachePolicy explains the caching headers when -cache-analyze
+	// is given.
+	CachePolicy *CachePolicy `json:",omitempty"`
+
+	// Revalidation compares a conditional request with the full fetch
+	// when -revalidate is given.
+	Revalidation *Revalidation `json:",omitempty"`
+
+	// CacheComparison compares a cache busting request with a normal
+	// one when -cache-compare is given.
+	CacheComparison *CacheComparison `json:",omitempty"`
+
+	// ServerTiming holds the metrics of the Server-Timing header.
+	ServerTiming []ServerTimingMetric `json:",omitempty"`
+
+	// Security grades the security response headers when
+	// -security-audit is given.
+	Security *SecurityAudit `json:",omitempty"`
+
+	// TokenFetch is how long the -oauth2-token-url access token took
+	// to acquire, in milliseconds.
+	TokenFetch int `json:",omitempty"`
+
+	// Size counts the bytes sent and received.
+	Size Sizes
+
+	// WebSocket reports the upgrade and ping round trips with -ws.
+	WebSocket *WebSocketInfo `json:",omitempty"`
+
+	// GraphQLErrors lists the errors in a -graphql response.
+	GraphQLErrors []string `json:",omitempty"`
+
+	// GRPC is the result of the -grpc health check.
+	GRPC *GRPCHealth `json:",omitempty"`
+
+	// SSE times the events of a text/event-stream response with -sse.
+	SSE *SSEStats `json:",omitempty"`
+
+	// Stream records when each piece of the body arrived with
+	// -stream-trace.
+	Stream []StreamChunk `json:",omitempty"`
+
+	// Upload times sending the request body.
+	Upload *Upload `json:",omitempty"`
+
+	// Trailer holds the trailers sent after the response body.
+	Trailer http.Header `json:",omitempty"`
+
+	// Extracted holds the values taken from the body with -extract.
+	Extracted []Extracted `json:",omitempty"`
+
+	// BodyMatch is the result of -body-match.
+	BodyMatch *BodyMatch `json:",omitempty"`
+
+	// SHA256 is the hex SHA-256 digest of the response body as
+	// received, before any Content-Encoding is decoded.
+	SHA256 string `json:",omitempty"`
+
+	// Range reports how the server answered -range.
+	Range *RangeInfo `json:",omitempty"`
+
+	// Resume reports continuing a download with -continue-at.
+	Resume *Resume `json:",omitempty"`
+
+	// Continue times waiting for 100 Continue with -expect100.
+	Continue *Continue `json:",omitempty"`
+
+	// Chunked reports whether a -chunked request body was accepted.
+	Chunked *ChunkedUpload `json:",omitempty"`
+
+	// Compression describes the decoding of a compressed body with
+	// -compressed.
+	Compression *Compression `json:",omitempty"`
+
+	// Page times loading the subresources of an HTML page with -page.
+	Page *PageLoad `json:",omitempty"`
+
+	// when the exchange started, the first response byte arrived and,
+	// if the body was decoded afterwards, the last byte arrived.
+	start, firstByte, transferEnd time.Time
+	micros                        Timing   // the timing in microseconds
+	document                      []byte   // the HTML of the page with -page
+	tokenFetch                    int      // TokenFetch in microseconds
+	authMicros                    []Timing // AuthRoundTrips in microseconds
 }
 
 type Timing struct {
@@ -52,6 +170,9 @@ type Timing struct {
 	Total         int
 }
 
+// timestampFormat is RFC 3339 to the millisecond, for -timestamps.
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
 const (
 	httpsTemplate = `` +
 		`  DNS Lookup   TCP Connection   TLS Handshake   Server Processing   Content Transfer` + "\n" +
@@ -75,27 +196,142 @@ const (
 
 var (
 	// Command line flags.
-	httpMethod      string
-	postBody        string
-	followRedirects bool
-	onlyHeader      bool
-	insecure        bool
-	httpHeaders     headers
-	saveOutput      bool
-	outputFile      string
-	showVersion     bool
-	clientCertFile  string
-	fourOnly        bool
-	sixOnly         bool
-	maxTime         time.Duration
-	cacert          string
-	jsonOutput      bool
-	numRequests     int
-	requestDelay    time.Duration
+	httpMethod            string
+	postBody              string
+	formData              formFields
+	stdinBody             []byte
+	chunkedUpload         bool
+	expect100             bool
+	requestTrailers       headers
+	compressBody          string
+	dataSource            string
+	bodyRandom            randomBody
+	expandEnvVars         bool
+	configFile            string
+	profile               string
+	dryRun                bool
+	asCurl                bool
+	followRedirects       bool
+	onlyHeader            bool
+	insecure              bool
+	httpHeaders           headers
+	saveOutput            bool
+	outputFile            string
+	showVersion           bool
+	clientCertFile        string
+	clientKeyFile         string
+	clientCertPass        string
+	clientKeyPass         string
+	pkcs11Spec            string
+	pkcs11PIN             string
+	serverName            string
+	userCredentials       string
+	proxyCredentials      string
+	bearerToken           string
+	useNetrc              bool
+	cookieJarFile         string
+	sendCookies           string
+	cookieAudit           bool
+	securityAudit         bool
+	cacheAnalyze          bool
+	revalidateMode        bool
+	cacheCompare          bool
+	compressed            bool
+	streamTrace           bool
+	verbose               bool
+	traceDumpFile         string
+	saveRaw               string
+	showBody              bool
+	extractExprs          extractions
+	bodyMatch             regexpFlag
+	bodyMatchExit         bool
+	expectSHA256          string
+	continueAt            string
+	outputFormat          formatFlag
+	writeOutFormat        string
+	quiet                 bool
+	summaryOnly           bool
+	noColor               bool
+	themeName             string
+	asciiOutput           bool
+	compactWaterfall      bool
+	timestamps            bool
+	logFile               string
+	syslogTarget          syslogFlag
+	syslogFacility        string
+	syslogSeverity        string
+	syslogWriter          io.Writer
+	recordRuns            bool
+	latencyChartRun       bool
+	htmlReport            string
+	markdownOutput        bool
+	svgFile               string
+	pageLoad              bool
+	pageConcurrency       int
+	pageSameOrigin        bool
+	saveBaseline          string
+	compareBaseline       string
+	baselineTolerance     = percentage(0.1)
+	byteRange             string
+	traceDump             *traceDumper
+	sseMode               bool
+	sseEvents             int
+	wsMode                bool
+	wsPings               int
+	grpcMode              bool
+	grpcService           string
+	graphqlQuery          string
+	graphqlVars           string
+	netrcFile             string
+	authScheme            string
+	awsSigV4              string
+	oauth2TokenURL        string
+	oauth2ClientID        string
+	oauth2Secret          string
+	oauth2Scope           string
+	fourOnly              bool
+	sixOnly               bool
+	maxTime               time.Duration
+	dnsTimeout            time.Duration
+	connectTimeout        time.Duration
+	tlsTimeout            time.Duration
+	responseHeaderTimeout time.Duration
+	retries               int
+	retryDelay            time.Duration
+	retryMaxTime          time.Duration
+	retryOn               = retryConditions{"dns", "connect", "timeout", "reset"}
+	cacert                string
+	jsonOutput            bool
+	numRequests           int
+	totalTime             time.Duration
+	ramp                  rampProfile
+	rampSteps             int
+	requestDelay          time.Duration
+	showCertInfo          bool
+	certWarn              days
+	certWarnExit          bool
+	tlsMin                tlsVersion
+	tlsMax                tlsVersion
+	testResumption        bool
+	keyLogFile            string
+	keyLog                *os.File // -keylog, open for the run
+	publicKeyPins         pins
+	useECH                bool
+	echConfigFile         string
+
+	// cookies kept across redirects and runs with -cookie-jar, or
+	// read from a file with -b
+	cookies *cookieJar
+
+	// cookies given on the command line with -b
+	requestCookies []*http.Cookie
 
 	// number of redirects followed
 	redirectsFollowed int
 
+	// exit status reported once all requests have completed
+	exitStatus int
+
 	version = "devel" // for -v flag, updated during the release process with -ldflags=-X=main.version=...
 )
 
@@ -103,28 +339,145 @@ const maxRedirects = 10
 
 func init() {
 	flag.StringVar(&httpMethod, "X", "GET", "HTTP method to use")
-	flag.StringVar(&postBody, "d", "", "the body of a POST or PUT request; from file use @filename")
+	flag.StringVar(&postBody, "d", "", "the body of a POST or PUT request; from file use @filename, from stdin @-")
+	flag.BoolVar(&chunkedUpload, "chunked", false, "send the request body with chunked transfer encoding, without a Content-Length; streams -d @- from stdin")
+	flag.BoolVar(&expect100, "expect100", false, "send Expect: 100-continue with the request body and time the wait for the server's 100 Continue")
+	flag.Var(&requestTrailers, "trailer", "send a trailer after the request body; repeatable: -trailer 'X-Checksum: ...'")
+	flag.StringVar(&compressBody, "compress-body", "", "compress the request body on the fly with gzip, deflate, br or zstd and set Content-Encoding")
+	flag.StringVar(&dataSource, "data-source", "", "CSV or JSON Lines file whose rows, one per request, give {{column}} template values")
+	flag.Var(&bodyRandom, "body-random", "send a random body of this size, or a size from a range such as 1KB..64KB; prefix with json: for random JSON")
+	flag.Var(&formData, "F", "add a multipart/form-data field; repeatable: -F name=value -F 'file=@path;type=image/png'")
 	flag.BoolVar(&followRedirects, "L", false, "follow 30x redirects")
 	flag.BoolVar(&onlyHeader, "I", false, "don't read body of request")
 	flag.BoolVar(&insecure, "k", false, "allow insecure SSL connections")
+	flag.StringVar(&userCredentials, "u", "", "server user and password, as user:password; prompted for if the password is omitted")
+	flag.StringVar(&proxyCredentials, "proxy-user", "", "user and password for the proxy of HTTP_PROXY or HTTPS_PROXY, as user:password; prompted for if the password is omitted")
+	flag.StringVar(&sendCookies, "b", "", "send cookies, as 'name=value; other=value' or @file to read a Netscape format cookie file")
+	flag.BoolVar(&cookieAudit, "cookie-audit", false, "check Set-Cookie headers for missing Secure, HttpOnly and SameSite attributes and scope problems")
+	flag.BoolVar(&securityAudit, "security-audit", false, "grade the security response headers: HSTS, CSP, X-Content-Type-Options, X-Frame-Options, Referrer-Policy and Permissions-Policy")
+	flag.BoolVar(&cacheAnalyze, "cache-analyze", false, "explain how long the response may be cached and how it can be revalidated")
+	flag.BoolVar(&revalidateMode, "revalidate", false, "repeat the request with the response's ETag or Last-Modified and compare the 304 revalidation with the full fetch")
+	flag.BoolVar(&cacheCompare, "cache-compare", false, "compare a cache busting request with a normal one to show the benefit of caching")
+	flag.BoolVar(&compressed, "compressed", false, "request a compressed response with gzip, br or zstd and time decoding it")
+	flag.BoolVar(&streamTrace, "stream-trace", false, "record when each piece of the body arrives and print the timeline")
+	flag.BoolVar(&sseMode, "sse", false, "read a text/event-stream response event by event and time the events")
+	flag.IntVar(&sseEvents, "sse-events", 0, "stop -sse after this many events; by default read until the stream ends or is interrupted")
+	flag.BoolVar(&wsMode, "ws", false, "open a WebSocket, implied by ws:// and wss:// URLs")
+	flag.IntVar(&wsPings, "ws-pings", 0, "send this many pings over the WebSocket and time the pongs")
+	flag.BoolVar(&grpcMode, "grpc", false, "run a gRPC health check against the server, over HTTP/2 without TLS for http URLs")
+	flag.StringVar(&grpcService, "grpc-service", "", "service to health check with -grpc; by default the whole server")
+	flag.StringVar(&graphqlQuery, "graphql", "", "POST this GraphQL query as JSON and fail on errors in the response; from file use @filename")
+	flag.StringVar(&graphqlVars, "graphql-vars", "", "JSON object of variables for the -graphql query; from file use @filename")
+	flag.BoolVar(&expandEnvVars, "expand-env", false, "expand ${VAR} in header values, trailers and the -d body from the environment")
+	flag.StringVar(&configFile, "config", "", "read default settings from this TOML file instead of ~/.config/httpstat/config.toml")
+	flag.StringVar(&profile, "profile", "", "use the settings of this profile in the config file")
+	flag.BoolVar(&dryRun, "dry-run", false, "show the request that would be sent, and the proxy, body and TLS settings, without connecting")
+	flag.BoolVar(&asCurl, "as-curl", false, "print the curl command that makes the same request, without connecting")
+	flag.BoolVar(&verbose, "V", false, "print the request line and headers as sent, including those added by the transport")
+	flag.BoolVar(&verbose, "verbose", false, "print the request line and headers as sent; same as -V")
+	flag.StringVar(&traceDumpFile, "trace-dump", "", "write a hex dump of the bytes sent and received on each connection to `file`; HTTPS is decrypted and limited to HTTP/1.1")
+	flag.StringVar(&saveRaw, "save-raw", "", "save the status line, headers and body of the response as received to `file`, numbered when -n > 1")
+	flag.BoolVar(&showBody, "show-body", false, "print text and JSON response bodies, pretty-printing JSON, up to 64KiB")
+	flag.Var(&extractExprs, "extract", "report a value from a JSON response body; name=PATH also sets {{name}} for later requests; repeatable: -extract '$.data.id'")
+	flag.Var(&bodyMatch, "body-match", "match the response body against a regular expression and report the capture groups, eg. 'build=(\\w+)'")
+	flag.BoolVar(&bodyMatchExit, "body-match-exit", false, "exit non-zero when -body-match doesn't match")
+	flag.StringVar(&expectSHA256, "expect-sha256", "", "exit non-zero unless the SHA-256 of the response body, as received, is this hex digest")
+	flag.StringVar(&continueAt, "C", "", "resume the download into the -o or -O file at this offset, or - for the end of the file")
+	flag.StringVar(&continueAt, "continue-at", "", "resume the download into the -o or -O file at this offset, or - for its end; same as -C")
+	flag.StringVar(&byteRange, "range", "", "request a byte range, eg. 0-1023, and report whether the server sent just that")
+	flag.Var(&outputFormat, "format", "print each report through a Go template instead, eg. '{{.Timing.Total}} {{.Status}} {{.Address}}'")
+	flag.StringVar(&writeOutFormat, "write-out", "", "print each report using curl's -w variables instead, eg. '%{http_code} %{time_total}\\n', or @file")
+	flag.BoolVar(&quiet, "q", false, "print only the timing diagram of each request, and the summary of -n runs")
+	flag.BoolVar(&summaryOnly, "summary-only", false, "print only the summary of the -n requests, once they are done")
+	flag.BoolVar(&noColor, "no-color", false, "print without color; also set by NO_COLOR, or when output isn't a terminal")
+	flag.StringVar(&themeName, "theme", "default", "color theme: default, high-contrast or light; colors can be changed in the config file's [colors] table")
+	flag.BoolVar(&asciiOutput, "ascii", false, "print only ASCII and no color; non UTF-8 locales get ASCII in color")
+	flag.BoolVar(&compactWaterfall, "compact", false, "print the timing of each phase on its own line; the default on terminals too narrow for the diagram")
+	flag.StringVar(&timingPrecision, "precision", "ms", "precision of the timings shown, ms or us for microseconds")
+	flag.BoolVar(&timestamps, "timestamps", false, "print when each request was sent, and add it to JSON reports as Time")
+	flag.StringVar(&logFile, "log-file", "", "append the JSON report of each request as a line to this file, whatever is printed")
+	flag.Var(&syslogTarget, "syslog", "log a line for each request to the local syslog daemon, or with -syslog=ADDRESS a remote one at host[:port], tcp://host[:port] or udp://host[:port]")
+	flag.StringVar(&syslogFacility, "syslog-facility", "user", "syslog facility, eg. daemon or local0")
+	flag.StringVar(&syslogSeverity, "syslog-severity", "info", "syslog severity, eg. notice or warning")
+	flag.BoolVar(&recordRuns, "record", false, "record each request in the local history, listed by the history subcommand")
+	flag.StringVar(&saveBaseline, "save-baseline", "", "save the timings of the run as the named baseline, or to a .json file, for -compare-baseline")
+	flag.StringVar(&compareBaseline, "compare-baseline", "", "compare the timings of the run with the named baseline, or a .json file, and exit non-zero on a regression")
+	flag.Var(&baselineTolerance, "tolerance", "how much slower than the baseline a phase may get before it's a regression, eg. 20%")
+	flag.BoolVar(&latencyChartRun, "chart", false, "chart the total time of each of the -n requests once they are done")
+	flag.BoolVar(&markdownOutput, "markdown", false, "print the results as Markdown tables, to paste into issues and wikis")
+	flag.BoolVar(&pageLoad, "page", false, "load the stylesheets, scripts and images of an HTML page and show when each was fetched")
+	flag.IntVar(&pageConcurrency, "page-concurrency", 6, "fetch up to this many resources of the -page at once")
+	flag.BoolVar(&pageSameOrigin, "page-same-origin", false, "only fetch the -page resources on the page's own origin")
+	flag.StringVar(&svgFile, "svg", "", "draw the timing waterfall of the request and its redirects as SVG to this file")
+	flag.StringVar(&htmlReport, "html", "", "write a self-contained HTML report of the requests to this file")
+	flag.StringVar(&cookieJarFile, "cookie-jar", "", "read cookies from and save cookies to this Netscape format file")
+	flag.BoolVar(&useNetrc, "netrc", false, "read -u credentials for the host from ~/.netrc")
+	flag.StringVar(&netrcFile, "netrc-file", "", "read -u credentials for the host from this netrc file")
+	flag.StringVar(&authScheme, "auth", "basic", "authentication scheme for -u credentials: basic, digest, ntlm or negotiate")
+	flag.StringVar(&bearerToken, "token", "", "bearer token to send; TOKEN, @file, env:VAR or keyring:NAME")
+	flag.StringVar(&oauth2TokenURL, "oauth2-token-url", "", "fetch a bearer token from this OAuth2 token endpoint with the client credentials grant")
+	flag.StringVar(&oauth2ClientID, "oauth2-client-id", "", "client id for -oauth2-token-url")
+	flag.StringVar(&oauth2Secret, "oauth2-client-secret", "", "client secret for -oauth2-token-url; SECRET, @file, env:VAR or keyring:NAME")
+	flag.StringVar(&oauth2Scope, "oauth2-scope", "", "space separated scopes to request from -oauth2-token-url")
+	flag.StringVar(&awsSigV4, "aws-sigv4", "", "sign the request with AWS Signature Version 4 for region/service, eg. us-east-1/s3")
 	flag.Var(&httpHeaders, "H", "set HTTP header; repeatable: -H 'Accept: ...' -H 'Range: ...'")
 	flag.BoolVar(&saveOutput, "O", false, "save body as remote filename")
-	flag.StringVar(&outputFile, "o", "", "output file for body")
+	flag.StringVar(&outputFile, "o", "", "output file for body, or - for stdout with the report on stderr")
 	flag.BoolVar(&showVersion, "v", false, "print version number")
 	flag.StringVar(&clientCertFile, "E", "", "client cert file for tls config")
+	flag.StringVar(&clientCertFile, "cert", "", "client cert file for tls config; same as -E")
+	flag.StringVar(&clientKeyFile, "key", "", "client private key file, if not included in the -E cert file")
+	flag.StringVar(&clientCertPass, "cert-pass", "", "password for a PKCS#12 (.p12, .pfx) client cert file")
+	flag.StringVar(&pkcs11Spec, "pkcs11", "", "use the client cert and key on a PKCS#11 token, given as module:slot:id")
+	flag.StringVar(&pkcs11PIN, "pkcs11-pin", "", "PIN for the -pkcs11 token; prompted for if omitted")
+	flag.StringVar(&clientKeyPass, "key-pass", "", "passphrase for an encrypted client private key; prompted for if omitted")
 	flag.BoolVar(&fourOnly, "4", false, "resolve IPv4 addresses only")
 	flag.BoolVar(&sixOnly, "6", false, "resolve IPv6 addresses only")
 	flag.DurationVar(&maxTime, "m", 0, "maximum time allowed for the transfer")
+	flag.DurationVar(&dnsTimeout, "dns-timeout", 0, "maximum time allowed to resolve the host; bounded by -connect-timeout if 0")
+	flag.DurationVar(&connectTimeout, "connect-timeout", 30*time.Second, "maximum time allowed to connect to the server")
+	flag.DurationVar(&tlsTimeout, "tls-timeout", 10*time.Second, "maximum time allowed for the TLS handshake")
+	flag.DurationVar(&responseHeaderTimeout, "response-header-timeout", 0, "maximum time allowed for the response headers once the request is sent; no limit if 0")
+	flag.IntVar(&retries, "retry", 0, "retry a request failing on a -retry-on condition up to this many times")
+	flag.DurationVar(&retryDelay, "retry-delay", time.Second, "wait before the first retry, doubled for each after it, less up to half as jitter")
+	flag.DurationVar(&retryMaxTime, "retry-max-time", 0, "stop retrying a request once this long has passed since it was first tried")
+	flag.Var(&retryOn, "retry-on", "what -retry retries: dns, connect, tls, timeout and reset failures, status codes such as 429 and classes such as 5xx")
 	flag.StringVar(&cacert, "cacert", "", "CA certificate to verify peer against (SSL)")
 	flag.BoolVar(&jsonOutput, "J", false, "use JSON to output results")
 	flag.IntVar(&numRequests, "n", 1, "number of requests")
+	flag.Var(&ramp, "ramp", "raise the load over a period, eg. 1..50/2m concurrent requests or 10..200qps/2m requests a second, reporting the latency at each step")
+	flag.IntVar(&rampSteps, "ramp-steps", 10, "number of load steps of -ramp")
+	flag.DurationVar(&totalTime, "total-time", 0, "stop a run of -n requests once this long has passed, summarizing those completed")
 	flag.DurationVar(&requestDelay, "w", 3*time.Second, "delay between requests")
+	flag.BoolVar(&showCertInfo, "cert-info", false, "show the peer certificate chain")
+	flag.Var(&certWarn, "cert-warn", "warn when the certificate expires within this duration, eg. 30d")
+	flag.BoolVar(&certWarnExit, "cert-warn-exit", false, "exit non-zero when -cert-warn is triggered")
+	flag.Var(&tlsMin, "tls-min", "minimum TLS version to offer: 1.0, 1.1, 1.2 or 1.3")
+	flag.Var(&tlsMax, "tls-max", "maximum TLS version to offer: 1.0, 1.1, 1.2 or 1.3")
+	flag.StringVar(&keyLogFile, "keylog", os.Getenv("SSLKEYLOGFILE"), "append TLS secrets to this file in NSS key log format")
+	flag.Var(&publicKeyPins, "pin", "require the peer's public key to match sha256//BASE64; repeatable")
+	flag.StringVar(&serverName, "sni", "", "TLS server name to send, instead of the URL host or Host header")
+	flag.BoolVar(&useECH, "ech", false, "offer Encrypted Client Hello using the config from the host's DNS HTTPS record")
+	flag.StringVar(&echConfigFile, "ech-config", "", "offer Encrypted Client Hello using the ECHConfigList in this file")
+	flag.BoolVar(&testResumption, "resume", false, "test TLS session resumption with a full then a resumed handshake")
 
 	flag.Usage = usage
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] URL\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] URL\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s bench -workers HOST[:PORT],... [OPTIONS] URL\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s collection [OPTIONS] COLLECTION\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s compare [OPTIONS] URL1 URL2\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s crawl [OPTIONS] SITEMAP\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s diff A.json B.json\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s freshness [OPTIONS] URL\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s from-curl [OPTIONS] 'curl ...'\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s history [OPTIONS] [URL]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s monitor -targets FILE [OPTIONS]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s openapi [OPTIONS] SPEC\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s watch [OPTIONS] URL\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s worker [OPTIONS]\n\n", os.Args[0])
 	fmt.Fprintln(os.Stderr, "OPTIONS:")
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "")
@@ -133,17 +486,46 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "                used for HTTPS requests if HTTPS_PROXY undefined")
 	fmt.Fprintln(os.Stderr, "  HTTPS_PROXY   proxy for HTTPS requests; complete URL or HOST[:PORT]")
 	fmt.Fprintln(os.Stderr, "  NO_PROXY      comma-separated list of hosts to exclude from proxy")
+	fmt.Fprintln(os.Stderr, "  SSLKEYLOGFILE default for -keylog; lets Wireshark decrypt captured traffic")
 }
 
 func printf(format string, a ...interface{}) (n int, err error) {
 	return fmt.Fprintf(color.Output, format, a...)
 }
 
+// colorTerminal reports whether f is a terminal to print in color to,
+// unless that's turned off by NO_COLOR (https://no-color.org) or TERM.
+func colorTerminal(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && term.IsTerminal(int(f.Fd()))
+}
+
 func grayscale(code color.Attribute) func(string, ...interface{}) string {
 	return color.New(code + 232).SprintfFunc()
 }
 
+// subcommands are run, by name, when given instead of a URL.
+var subcommands = map[string]func(args []string){
+	"bench":      runBench,
+	"collection": runCollection,
+	"compare":    runCompare,
+	"crawl":      runCrawl,
+	"diff":       runDiff,
+	"freshness":  runFreshness,
+	"from-curl":  runFromCurl,
+	"history":    runHistory,
+	"monitor":    runMonitor,
+	"openapi":    runOpenAPI,
+	"watch":      runWatch,
+	"worker":     runWorker,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
 
 	if showVersion {
@@ -151,28 +533,381 @@ func main() {
 		os.Exit(0)
 	}
 
+	args := flag.Args()
+	if len(args) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	measure(args[0])
+}
+
+// measure makes the requests to target the flags call for, reports on
+// them and exits.
+func measure(target string) {
+	url := configure(target)
+	if ramp.period > 0 {
+		runRamp(url)
+		closeKeyLog()
+		os.Exit(exitStatus)
+	}
+	startRun()
+	reports := visit(url)
+	if markdownOutput && len(reports) > 0 {
+		printMarkdown(url, reports)
+	}
+
+	if compareBaseline != "" && len(reports) > 0 {
+		b, err := loadBaseline(baselinePath(compareBaseline))
+		if err != nil {
+			log.Fatalf("unable to read baseline: %v", err)
+		}
+		c := compareToBaseline(b, summarize(reports), float64(baselineTolerance))
+		if writeOutFormat == "" && outputFormat.Template == nil && !jsonOutput && !markdownOutput {
+			printBaselineComparison(compareBaseline, b, c, url.Scheme)
+		}
+		if c.regressed() {
+			exitStatus = 1
+		}
+	}
+	if svgFile != "" && len(redirectChain) > 0 {
+		if err := writeWaterfallSVG(svgFile, redirectChain); err != nil {
+			log.Fatalf("unable to write SVG: %v", err)
+		}
+	}
+	if htmlReport != "" && len(reports) > 0 {
+		if err := writeHTMLReport(htmlReport, url, reports); err != nil {
+			log.Fatalf("unable to write HTML report: %v", err)
+		}
+	}
+	if saveBaseline != "" && len(reports) > 0 {
+		if err := writeBaseline(baselinePath(saveBaseline), url, summarize(reports)); err != nil {
+			log.Fatalf("unable to save baseline: %v", err)
+		}
+	}
+
+	if cookieJarFile != "" && !dryRun && !asCurl {
+		if err := cookies.save(cookieJarFile); err != nil {
+			log.Fatalf("unable to save cookie jar: %v", err)
+		}
+	}
+	stopRun()
+	closeKeyLog()
+	os.Exit(exitStatus)
+}
+
+// closeKeyLog closes the -keylog file, if open.
+func closeKeyLog() {
+	if keyLog != nil {
+		keyLog.Close()
+	}
+}
+
+// configure applies the config file, checks the flags and prepares
+// what every request shares, returning the URL of target.
+func configure(target string) *url.URL {
+	filename := configFile
+	if filename == "" {
+		filename = configPath()
+	}
+	if settings, err := loadConfig(filename, profile); err == nil {
+		if err := applyConfig(flag.CommandLine, settings); err != nil {
+			log.Fatalf("invalid config file %s: %v", filename, err)
+		}
+	} else if configFile != "" || profile != "" || !os.IsNotExist(err) {
+		log.Fatalf("unable to read config file: %v", err)
+	}
+
 	if fourOnly && sixOnly {
 		fmt.Fprintf(os.Stderr, "%s: Only one of -4 and -6 may be specified\n", os.Args[0])
 		os.Exit(-1)
 	}
 
-	args := flag.Args()
-	if len(args) != 1 {
-		flag.Usage()
-		os.Exit(2)
+	if tlsMin != 0 && tlsMax != 0 && tlsMin > tlsMax {
+		fmt.Fprintf(os.Stderr, "%s: -tls-min may not be greater than -tls-max\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	if bodyRandom.max > 0 {
+		if postBody != "" || graphqlQuery != "" || len(formData) > 0 {
+			log.Fatal("-body-random generates the body, it can't be used with -d, -F or -graphql")
+		}
+		if httpMethod == "GET" {
+			httpMethod = "POST"
+		}
+	}
+
+	if len(formData) > 0 {
+		if postBody != "" || graphqlQuery != "" {
+			log.Fatal("-F builds a multipart body, it can't be used with -d or -graphql")
+		}
+		if httpMethod == "GET" {
+			httpMethod = "POST"
+		}
+	}
+
+	if graphqlQuery != "" {
+		if postBody != "" {
+			log.Fatal("-graphql builds the request body, it can't be used with -d")
+		}
+		body, err := graphqlBody(graphqlQuery, graphqlVars)
+		if err != nil {
+			log.Fatal(err)
+		}
+		postBody, httpMethod = body, "POST"
+		if !httpHeaders.has("Content-Type") {
+			httpHeaders = append(httpHeaders, "Content-Type: application/json")
+		}
+	}
+
+	if (httpMethod == "POST" || httpMethod == "PUT") && postBody == "" && len(formData) == 0 && bodyRandom.max == 0 {
+		if !stdinPiped() {
+			log.Fatal("must supply post body using -d when POST or PUT is used")
+		}
+		postBody = "@-"
+	}
+
+	if dataSource != "" {
+		var err error
+		if dataRows, err = loadDataSource(dataSource); err != nil {
+			log.Fatalf("unable to read data source: %v", err)
+		}
+		setSequence(1)
+	}
+
+	out := os.Stdout
+	if outputFile == "-" {
+		// stdout is for the body, so report on stderr.
+		color.Output, out = color.Error, os.Stderr
+	}
+	color.NoColor = noColor || asciiOutput || !colorTerminal(out)
+	if w, _, err := term.GetSize(int(out.Fd())); err == nil {
+		terminalWidth = w
+	}
+	if !utf8Locale() {
+		// keep to ASCII, though in color.
+		asciiOutput = true
+	}
+	if err := applyTheme(themeName, themeColors); err != nil {
+		log.Fatal(err)
+	}
+
+	if continueAt != "" {
+		if outputFile == "" && !saveOutput || outputFile == "-" {
+			log.Fatal("-continue-at resumes a download, save it with -o or -O")
+		}
+		if httpHeaders.has("Range") {
+			log.Fatal("-continue-at sets the Range header, it can't be given with -H")
+		}
+	}
+
+	if writeOutFormat != "" {
+		var err error
+		if writeOutFormat, err = parseWriteOut(writeOutFormat); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if byteRange != "" {
+		if err := parseByteRanges(byteRange); err != nil {
+			log.Fatal(err)
+		}
+		if continueAt != "" || httpHeaders.has("Range") {
+			log.Fatal("-range sets the Range header, it can't be used with -continue-at or -H")
+		}
+	}
+
+	if expectSHA256 != "" {
+		var err error
+		if expectSHA256, err = parseSHA256(expectSHA256); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if (httpMethod == "POST" || httpMethod == "PUT") && postBody == "" {
-		log.Fatal("must supply post body using -d when POST or PUT is used")
+	if compressBody != "" {
+		if _, ok, _ := encoder(compressBody, ioutil.Discard); !ok {
+			log.Fatalf("unsupported -compress-body encoding %q, use gzip, deflate, br or zstd", compressBody)
+		}
+	}
+
+	if len(requestTrailers) > 0 && postBody == "" && len(formData) == 0 && bodyRandom.max == 0 {
+		log.Fatal("-trailer is sent after the request body, supply one using -d or -F")
+	}
+
+	if retries > 0 && postBody == "@-" && chunkedUpload {
+		log.Fatal("-retry can't send a body streamed from stdin with -chunked again")
+	}
+
+	if postBody == "@-" && !chunkedUpload {
+		// read it all now as stdin can't be read again for retries,
+		// unless it is to be streamed.
+		var err error
+		if stdinBody, err = ioutil.ReadAll(os.Stdin); err != nil {
+			log.Fatalf("failed to read body from stdin: %v", err)
+		}
 	}
 
 	if onlyHeader {
 		httpMethod = "HEAD"
 	}
 
-	url := parseURL(args[0])
+	url := parseURL(target)
+	switch url.Scheme {
+	case "ws":
+		wsMode, url.Scheme = true, "http"
+	case "wss":
+		wsMode, url.Scheme = true, "https"
+	}
+
+	switch authScheme {
+	case "basic", "digest", "ntlm", "negotiate":
+	default:
+		log.Fatalf("unsupported authentication scheme %q", authScheme)
+	}
+	if timingPrecision != "ms" && timingPrecision != "us" {
+		log.Fatalf("unsupported precision %q, must be ms or us", timingPrecision)
+	}
+
+	if traceDumpFile != "" && !dryRun {
+		if grpcMode && url.Scheme == "https" {
+			log.Fatal("-trace-dump limits HTTPS to HTTP/1.1, which -grpc can't use")
+		}
+		f, err := os.Create(traceDumpFile)
+		if err != nil {
+			log.Fatalf("unable to create trace dump file: %v", err)
+		}
+		traceDump = &traceDumper{w: f}
+	}
+
+	if keyLogFile != "" {
+		var err error
+		if keyLog, err = os.OpenFile(keyLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+			log.Fatalf("unable to open key log file: %v", err)
+		}
+	}
+
+	if syslogTarget.enabled && !dryRun {
+		priority, err := syslogPriority(syslogFacility, syslogSeverity)
+		if err != nil {
+			log.Fatal(err)
+		}
+		syslogWriter, err = dialSyslog(syslogTarget.network, syslogTarget.addr, priority)
+		if err != nil {
+			log.Fatalf("unable to connect to syslog: %v", err)
+		}
+	}
+
+	if expandEnvVars {
+		if err := expandHeaderEnv(httpHeaders); err != nil {
+			log.Fatal(err)
+		}
+		if err := expandHeaderEnv(requestTrailers); err != nil {
+			log.Fatal(err)
+		}
+		if !strings.HasPrefix(postBody, "@") {
+			var err error
+			if postBody, err = expandEnv(postBody); err != nil {
+				log.Fatalf("-d body: %v", err)
+			}
+		}
+	}
+
+	if err := resolveHeaderSecrets(httpHeaders); err != nil {
+		log.Fatal(err)
+	}
+
+	if userCredentials != "" {
+		var err error
+		authUser, authPassword, err = parseUserCredentials(userCredentials)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authHost = url.Host
+	}
+
+	if proxyCredentials != "" {
+		var err error
+		if proxyUser, err = parseProxyCredentials(proxyCredentials); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if userCredentials == "" && (useNetrc || netrcFile != "") {
+		filename := netrcFile
+		if filename == "" {
+			filename = netrcPath()
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			log.Fatalf("unable to read netrc file: %v", err)
+		}
+		var ok bool
+		authUser, authPassword, ok = lookupNetrc(f, url.Hostname())
+		f.Close()
+		if ok {
+			authHost = url.Host
+		}
+	}
+
+	if bearerToken != "" {
+		var err error
+		authToken, err = resolveSecret(bearerToken)
+		if err != nil {
+			log.Fatalf("unable to read bearer token: %v", err)
+		}
+		authHost = url.Host
+	}
+
+	if oauth2TokenURL != "" && !dryRun {
+		secret, err := resolveSecret(oauth2Secret)
+		if err != nil {
+			log.Fatalf("unable to read OAuth2 client secret: %v", err)
+		}
+		start := time.Now()
+		authToken, err = fetchOAuth2Token(oauth2TokenURL, oauth2ClientID, secret, oauth2Scope)
+		if err != nil {
+			log.Fatalf("unable to fetch OAuth2 token: %v", err)
+		}
+		tokenFetchTime = time.Since(start)
+		authHost = url.Host
+	}
+
+	if awsSigV4 != "" {
+		var err error
+		awsRegion, awsService, err = parseSigV4(awsSigV4)
+		if err != nil {
+			log.Fatal(err)
+		}
+		awsCreds, err = loadAWSCredentials()
+		if err != nil {
+			log.Fatalf("unable to load AWS credentials: %v", err)
+		}
+		authHost = url.Host
+	}
+
+	if cookieJarFile != "" {
+		var err error
+		cookies, err = loadCookieJar(cookieJarFile)
+		if err != nil {
+			log.Fatalf("unable to read cookie jar: %v", err)
+		}
+	}
+
+	if strings.HasPrefix(sendCookies, "@") {
+		if cookies == nil {
+			cookies = newCookieJar()
+		}
+		if err := cookies.load(sendCookies[1:]); err != nil {
+			log.Fatalf("unable to read cookies: %v", err)
+		}
+	} else if sendCookies != "" {
+		var err error
+		requestCookies, err = http.ParseCookie(sendCookies)
+		if err != nil {
+			log.Fatalf("invalid -b cookies %q: %v", sendCookies, err)
+		}
+	}
 
-	visit(url)
+	return url
 }
 
 // readCACerts - helper function to load additional CA certificates
@@ -193,8 +928,9 @@ func readCACerts(filename string) (*x509.CertPool, error) {
 }
 
 // readClientCert - helper function to read client certificate
-// from pem formatted file
-func readClientCert(filename string) ([]tls.Certificate, error) {
+// from pem formatted file, and its private key from keyFile if
+// it is not included in the certificate file.
+func readClientCert(filename, keyFile string) ([]tls.Certificate, error) {
 	if filename == "" {
 		return nil, nil
 	}
@@ -203,11 +939,25 @@ func readClientCert(filename string) ([]tls.Certificate, error) {
 		certPem bytes.Buffer
 	)
 
-	// read client certificate file (must include client private key and certificate)
+	// read client certificate file (must include client private key and certificate
+	// unless a separate key file is given)
 	certFileBytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read client certificate file: %v", err)
 	}
+	if isPKCS12(filename, certFileBytes) {
+		certFileBytes, err = pkcs12ToPEM(certFileBytes, clientCertPass)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PKCS#12 client certificate file: %v", err)
+		}
+	}
+	if keyFile != "" {
+		keyFileBytes, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client key file: %v", err)
+		}
+		certFileBytes = append(append(certFileBytes, '\n'), keyFileBytes...)
+	}
 
 	for {
 		block, rest := pem.Decode(certFileBytes)
@@ -217,6 +967,10 @@ func readClientCert(filename string) ([]tls.Certificate, error) {
 		certFileBytes = rest
 
 		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			block, err = decryptPrivateKey(block)
+			if err != nil {
+				return nil, err
+			}
 			pkeyPem = pem.EncodeToMemory(block)
 		}
 		if strings.HasSuffix(block.Type, "CERTIFICATE") {
@@ -235,6 +989,93 @@ func readClientCert(filename string) ([]tls.Certificate, error) {
 	return []tls.Certificate{cert}, nil
 }
 
+// decryptPrivateKey decrypts a passphrase protected private key,
+// prompting for the passphrase if it was not supplied with -key-pass.
+func decryptPrivateKey(block *pem.Block) (*pem.Block, error) {
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, errors.New("encrypted PKCS#8 private keys are not supported; convert the key with 'openssl pkey -traditional'")
+	}
+	if !x509.IsEncryptedPEMBlock(block) {
+		return block, nil
+	}
+	pass := clientKeyPass
+	if pass == "" {
+		var err error
+		if pass, err = readPassword("Enter passphrase for client private key: "); err != nil {
+			return nil, fmt.Errorf("unable to read passphrase for client private key: %v", err)
+		}
+	}
+	der, err := x509.DecryptPEMBlock(block, []byte(pass))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt client private key: %v", err)
+	}
+	return &pem.Block{Type: block.Type, Bytes: der}, nil
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than the
+// terminal or /dev/null.
+func stdinPiped() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// readPassword prompts for a password on the terminal without echoing it.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// parsePKCS11Spec splits a module:slot:id PKCS#11 key specification;
+// the module path may itself contain colons, the id is hex encoded.
+func parsePKCS11Spec(spec string) (module string, slot uint, id []byte, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 3 {
+		return "", 0, nil, fmt.Errorf("invalid PKCS#11 spec %q, want module:slot:id", spec)
+	}
+	n := len(parts)
+	s, err := strconv.ParseUint(parts[n-2], 10, 32)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("invalid PKCS#11 slot %q", parts[n-2])
+	}
+	id, err = hex.DecodeString(parts[n-1])
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("invalid PKCS#11 key id %q, want hex", parts[n-1])
+	}
+	return strings.Join(parts[:n-2], ":"), uint(s), id, nil
+}
+
+// isPKCS12 reports whether the client certificate file is a PKCS#12
+// bundle rather than PEM.
+func isPKCS12(filename string, data []byte) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".p12", ".pfx":
+		return true
+	}
+	return !bytes.Contains(data, []byte("-----BEGIN"))
+}
+
+// pkcs12ToPEM converts the certificates and private key in a PKCS#12
+// bundle to PEM blocks.
+func pkcs12ToPEM(data []byte, password string) ([]byte, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, b := range blocks {
+		if err := pem.Encode(&buf, b); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 func parseURL(uri string) *url.URL {
 	if !strings.Contains(uri, "://") && !strings.HasPrefix(uri, "//") {
 		uri = "//" + uri
@@ -264,24 +1105,49 @@ func headerKeyValue(h string) (string, string) {
 
 func dialContext(network string) func(ctx context.Context, network, addr string) (net.Conn, error) {
 	return func(ctx context.Context, _, addr string) (net.Conn, error) {
-		return (&net.Dialer{
-			Timeout:   30 * time.Second,
+		d := &net.Dialer{
+			Timeout:   connectTimeout,
 			KeepAlive: 30 * time.Second,
 			DualStack: false,
-		}).DialContext(ctx, network, addr)
+		}
+		if dnsTimeout <= 0 {
+			return d.DialContext(ctx, network, addr)
+		}
+		addrs, err := lookupAddrs(ctx, network, addr, dnsTimeout)
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		return dialAddrs(ctx, d, network, addrs)
 	}
 }
 
-// visit visits a url and times the interaction.
-// If the response is a 30x, visit follows the redirect.
-func visit(url *url.URL) {
-	req := newRequest(httpMethod, url, postBody)
+// transports are the transports visit has made, by the scheme and host
+// of the requests they make. Reusing them keeps connections open between
+// the requests of watch and compare, and the client certificate, once
+// read or unlocked, in use.
+var transports = map[string]*http.Transport{}
+
+// transportFor returns the transport for req, to url, making it the
+// first time the scheme and host of url are visited.
+func transportFor(url *url.URL, req *http.Request) *http.Transport {
+	key := url.Scheme + "://" + req.Host
+	if tr, ok := transports[key]; ok {
+		return tr
+	}
+	tr := newTransport(url, req)
+	transports[key] = tr
+	return tr
+}
 
+// newTransport makes a transport for req, to url, with the dialer, proxy
+// and TLS configuration of the command line.
+func newTransport(url *url.URL, req *http.Request) *http.Transport {
 	tr := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
+		Proxy:                 proxyFromEnvironment,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
+		TLSHandshakeTimeout:   tlsTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
@@ -290,6 +1156,8 @@ func visit(url *url.URL) {
 		tr.DialContext = dialContext("tcp4")
 	case sixOnly:
 		tr.DialContext = dialContext("tcp6")
+	default:
+		tr.DialContext = dialContext("tcp")
 	}
 
 	switch url.Scheme {
@@ -299,30 +1167,86 @@ func visit(url *url.URL) {
 			host = req.Host
 		}
 
-		cert, err := readClientCert(clientCertFile)
+		cert, err := readClientCert(clientCertFile, clientKeyFile)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if pkcs11Spec != "" {
+			c, err := loadPKCS11Cert(pkcs11Spec, pkcs11PIN)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cert = []tls.Certificate{c}
+		}
 		rootCAs, err := readCACerts(cacert)
 		if err != nil {
 			log.Printf("warning: failed to read CA certificates: %s\n", err)
 		}
 
+		// crypto/tls refuses anything older than TLS 1.2 unless asked, so
+		// capping the version below that implies offering the old ones.
+		minVersion := tlsMin
+		if minVersion == 0 && tlsMax != 0 && tlsMax < tls.VersionTLS12 {
+			minVersion = tls.VersionTLS10
+		}
+
+		sni := host
+		if serverName != "" {
+			sni = serverName
+		}
+
 		tr.TLSClientConfig = &tls.Config{
-			ServerName:         host,
+			ServerName:         sni,
 			InsecureSkipVerify: insecure,
 			Certificates:       cert,
 			RootCAs:            rootCAs,
+			MinVersion:         uint16(minVersion),
+			MaxVersion:         uint16(tlsMax),
 		}
 
-		// Because we create a custom TLSClientConfig, we have to opt-in to HTTP/2.
+		if len(publicKeyPins) > 0 {
+			tr.TLSClientConfig.VerifyConnection = publicKeyPins.verify
+		}
+
+		if (useECH || echConfigFile != "") && !dryRun {
+			ech, err := loadECHConfig(host, echConfigFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tr.TLSClientConfig.EncryptedClientHelloConfigList = ech
+			tr.TLSClientConfig.MinVersion = tls.VersionTLS13
+		}
+
+		if keyLog != nil {
+			tr.TLSClientConfig.KeyLogWriter = keyLog
+		}
+
+		// Because we create a custom TLSClientConfig, we have to opt-in to HTTP/2.
 		// See https://github.com/golang/go/issues/14275
-		err = http2.ConfigureTransport(tr)
-		if err != nil {
-			log.Fatalf("failed to prepare transport for HTTP/2: %v", err)
+		// WebSockets upgrade HTTP/1.1 connections, so stay on those.
+		// The trace dump wraps the TLS connection, which the transport
+		// can then only use for HTTP/1.1.
+		if !wsMode && traceDump == nil {
+			err = http2.ConfigureTransport(tr)
+			if err != nil {
+				log.Fatalf("failed to prepare transport for HTTP/2: %v", err)
+			}
 		}
 	}
 
+	if traceDump != nil {
+		if url.Scheme == "https" {
+			tr.DialTLSContext = traceDump.dialTLS(tr.DialContext, tr.TLSClientConfig, tr.TLSHandshakeTimeout)
+		} else {
+			tr.DialContext = traceDump.dial(tr.DialContext)
+		}
+	}
+	return tr
+}
+
+// newClient returns the client making requests through tr, with the
+// cookies and -max-time of the run.
+func newClient(tr *http.Transport) *http.Client {
 	client := &http.Client{
 		Transport: tr,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -332,81 +1256,166 @@ func visit(url *url.URL) {
 		},
 		Timeout: maxTime,
 	}
+	if cookies != nil {
+		client.Jar = cookies
+	}
+	return client
+}
+
+// visit visits a url and times the interaction, returning the reports
+// of the requests made to it.
+// If the response is a 30x, visit follows the redirect.
+func visit(url *url.URL) []Report {
+	req := prepareRequest(url)
+
+	tr := transportFor(url, req)
+
+	if dryRun {
+		printDryRun(req, tr)
+		return nil
+	}
+	if asCurl {
+		printCurlCommand(req, tr)
+		return nil
+	}
+
+	var resumption *Resumption
+	if testResumption && url.Scheme == "https" {
+		resumption = measureResumption(runContext, tr, req)
+	}
+
+	client := newClient(tr)
+	if grpcMode && url.Scheme == "http" {
+		client.Transport = h2cTransport(tr.DialContext)
+	}
 
+	var reports []Report
+	var stopped bool
 	for i := 0; i < numRequests; i++ {
 		if i > 0 {
-			time.Sleep(requestDelay)
+			if !pause(requestDelay) {
+				stopped = true
+				break
+			}
+			// a fresh request for a fresh body and template values.
+			setSequence(i + 1)
+			req = prepareRequest(url)
 		}
 
-		var tStart, tDNSStart, tConnectStart, tTLSStart, tConnected, tTTFB time.Time
 		var report Report
+		if timestamps {
+			report.Time = time.Now().Format(timestampFormat)
+			if textReport() {
+				printf("\n%s\n", labelString(report.Time))
+			}
+		}
+		var resp *http.Response
+		var bodyMsg string
+		if !beforeDeadline(func() {
+			req, resp = retryRoundTrip(client, url, req, &report)
+			if authScheme != "basic" {
+				resp = authenticate(client, url, resp, &report)
+			}
 
-		trace := &httptrace.ClientTrace{
-			GetConn:  func(_ string) { tStart = time.Now() },
-			DNSStart: func(_ httptrace.DNSStartInfo) { tDNSStart = time.Now() },
-			DNSDone: func(_ httptrace.DNSDoneInfo) {
-				report.Timing.DNS = msSince(tDNSStart)
-				report.Timing.Lookup = msSince(tStart)
-			},
-			ConnectStart: func(_, _ string) {
-				if tConnectStart.IsZero() {
-					// connecting to IP
-					tConnectStart = time.Now()
-				}
-			},
-			ConnectDone: func(net, addr string, err error) {
-				if err != nil {
-					log.Fatalf("unable to connect to host %v: %v", addr, err)
-				}
-				report.Timing.TCP = msSince(tConnectStart)
-				report.Timing.Connect = msSince(tStart)
+			switch {
+			case grpcMode:
+				report.GRPC = readGRPCHealth(resp, grpcService)
+				resp.Body.Close()
+			case !wsMode:
+				bodyMsg = readResponseBody(req, resp, &report)
+				resp.Body.Close()
+			}
+		}) {
+			stopped = true
+			break
+		}
 
-				report.Address = addr
-				if !jsonOutput {
-					printf("\n%s%s\n", color.GreenString("Connected to "), color.CyanString(addr))
-				}
-			},
-			TLSHandshakeStart: func() { tTLSStart = time.Now() },
-			TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
-				report.Timing.TLS = msSince(tTLSStart)
-			},
-			GotConn: func(_ httptrace.GotConnInfo) {
-				tConnected = time.Now()
-				report.Timing.PreTransfer = msSince(tStart)
-			},
-			GotFirstResponseByte: func() {
-				tTTFB = time.Now()
-				report.Timing.Server = msSince(tConnected)
-				report.Timing.StartTransfer = msSince(tStart)
-			},
-		}
-		req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
-
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatalf("failed to read response: %v", err)
+		// after read body
+		report.finish()
+		report.Trailer = receivedTrailers(resp)
+		if report.document != nil {
+			report.Page = loadPage(client, url, req.Header.Get("User-Agent"), &report, pageConcurrency, pageSameOrigin)
+			report.document = nil
 		}
 
-		bodyMsg := readResponseBody(req, resp)
-		resp.Body.Close()
+		if len(report.GraphQLErrors) > 0 {
+			exitStatus = 1
+		}
+		if report.BodyMatch != nil && !report.BodyMatch.Matched && bodyMatchExit {
+			exitStatus = 1
+		}
+		if expectSHA256 != "" && report.SHA256 != expectSHA256 {
+			exitStatus = 1
+		}
+		if report.GRPC != nil {
+			report.GRPC.Call = report.Timing.Total - report.Timing.PreTransfer
+			if !report.GRPC.serving() {
+				exitStatus = 1
+			}
+		}
 
-		// after read body
-		report.Timing.Transfer = msSince(tTTFB)
-		report.Timing.Total = msSince(tStart)
+		// the WebSocket session isn't part of the request timing.
+		if wsMode {
+			report.WebSocket = websocketSession(req, resp, wsPings)
+			resp.Body.Close()
+		}
 
 		report.Proto = resp.Proto
+		if chunkedUpload && req.ContentLength == -1 {
+			report.Chunked = checkChunked(resp)
+		}
 		report.Status = resp.Status
+		if byteRange != "" {
+			report.Range = checkRange(resp, byteRange)
+		}
 		report.Header = resp.Header
+		report.Resumption = resumption
+		if cookieAudit {
+			report.CookieAudit = auditCookies(url, resp.Header)
+		}
+		report.Cache = detectCache(resp.Header)
+		if revalidateMode && !isRedirect(resp) {
+			report.Revalidation = revalidate(client, url, resp, report.Timing)
+		}
+		if cacheCompare {
+			report.CacheComparison = compareCache(client, url)
+		}
+		if cacheAnalyze {
+			report.CachePolicy = analyzeCachePolicy(resp.StatusCode, resp.Header, time.Now())
+		}
+		report.ServerTiming = parseServerTiming(resp.Header.Values("Server-Timing"))
+		if securityAudit {
+			report.Security = auditSecurityHeaders(url.Scheme, resp.Header)
+		}
+		if i == 0 {
+			report.TokenFetch = int(tokenFetchTime / time.Millisecond)
+			report.tokenFetch = int(tokenFetchTime / time.Microsecond)
+		}
 
 		// print status line and headers
-		if jsonOutput {
+		if summaryOnly || markdownOutput {
+			// only the summary or Markdown is printed, once they are
+			// all done.
+		} else if writeOutFormat != "" {
+			printf("%s", writeOut(writeOutFormat, &report, url))
+		} else if outputFormat.Template != nil {
+			if err := outputFormat.Execute(color.Output, report); err != nil {
+				log.Fatalf("unable to format report: %v", err)
+			}
+		} else if jsonOutput {
 			b, err := json.Marshal(report)
 			if err != nil {
 				log.Fatalf("unable to marshal json report: %v", err)
 			}
-			fmt.Printf("%s\n", b)
+			printf("%s\n", b)
+		} else if quiet {
+			printf("\n")
+			if timestamps {
+				printf("%s\n", labelString(report.Time))
+			}
+			printWaterfall(url.Scheme, report.micros)
 		} else {
-			printf("\n%s%s%s\n", color.GreenString("HTTP"), grayscale(14)("/"), color.CyanString("%d.%d %s", resp.ProtoMajor, resp.ProtoMinor, resp.Status))
+			printf("\n%s%s%s\n", okString("HTTP"), labelString("/"), statusString("%d.%d %s", resp.ProtoMajor, resp.ProtoMinor, resp.Status))
 
 			names := make([]string, 0, len(resp.Header))
 			for k := range resp.Header {
@@ -414,29 +1423,166 @@ func visit(url *url.URL) {
 			}
 			sort.Sort(headers(names))
 			for _, k := range names {
-				printf("%s %s\n", grayscale(14)(k+":"), color.CyanString(strings.Join(resp.Header[k], ",")))
+				printf("%s %s\n", headerString(k+":"), valueString(strings.Join(resp.Header[k], ",")))
+			}
+
+			if showCertInfo && report.TLS != nil {
+				printTLSInfo(report.TLS)
+			}
+			if report.OCSP != nil {
+				printOCSPInfo(report.OCSP)
+			}
+			if report.Resumption != nil {
+				printResumption(report.Resumption)
+			}
+			if report.ECH != nil {
+				printECHInfo(report.ECH)
+			}
+
+			if report.Cache != nil {
+				printCacheInfo(report.Cache)
+			}
+
+			if report.CachePolicy != nil {
+				printCachePolicy(report.CachePolicy)
+			}
+
+			if report.Revalidation != nil {
+				printRevalidation(report.Revalidation)
+			}
+
+			if report.CacheComparison != nil {
+				printCacheComparison(report.CacheComparison)
+			}
+
+			if cookieAudit {
+				printCookieAudit(report.CookieAudit)
+			}
+
+			if report.Security != nil {
+				printSecurityAudit(report.Security)
+			}
+
+			if oauth2TokenURL != "" && i == 0 {
+				printf("\n%s %s\n", labelString("OAuth2 token fetch:"), valueString(formatTiming(report.tokenFetch)))
+			}
+
+			for _, t := range report.authMicros {
+				printf("\n%s %s\n", labelString("Authentication challenge:"), valueString(formatTiming(t.Total)))
+			}
+
+			if len(report.Attempts) > 0 {
+				printAttempts(&report)
+			}
+
+			if len(report.GraphQLErrors) > 0 {
+				printGraphQLErrors(report.GraphQLErrors)
+			}
+
+			for _, w := range report.Warnings {
+				printf("\n%s%s\n", warnString("Warning: "), w)
 			}
 
 			if bodyMsg != "" {
 				printf("\n%s\n", bodyMsg)
 			}
 
-			fmt.Println()
+			if report.Range != nil {
+				printRange(report.Range, resp.Status)
+			}
+
+			if report.Resume != nil {
+				printResume(report.Resume)
+			}
+
+			if len(report.Extracted) > 0 {
+				printExtracted(report.Extracted)
+			}
+
+			if report.BodyMatch != nil {
+				printBodyMatch(report.BodyMatch)
+			}
+
+			if expectSHA256 != "" {
+				printSHA256(report.SHA256, expectSHA256)
+			}
+
+			if report.Trailer != nil {
+				printTrailers(report.Trailer)
+			}
+
+			printf("\n")
+			printWaterfall(url.Scheme, report.micros)
+
+			printSizes(report.Size)
+
+			if report.Page != nil {
+				printPageLoad(url, &report, report.Page, terminalWidth)
+			}
+
+			if report.Upload != nil {
+				printUpload(report.Upload)
+			}
+
+			if report.Continue != nil {
+				printContinue(report.Continue)
+			}
+
+			if report.Chunked != nil {
+				printChunkedUpload(report.Chunked)
+			}
+
+			if report.GRPC != nil {
+				printGRPCHealth(report.GRPC)
+			}
+
+			if report.WebSocket != nil {
+				printWebSocketInfo(report.WebSocket)
+			}
+
+			if report.SSE != nil {
+				printSSEStats(report.SSE)
+			}
+
+			if len(report.Stream) > 0 {
+				printStreamTrace(report.Stream)
+			}
+
+			if report.Compression != nil {
+				printCompression(report.Compression)
+			}
 
-			switch url.Scheme {
-			case "https":
-				printTemplate(httpsTemplate, report.Timing)
-			case "http":
-				printTemplate(httpTemplate, report.Timing)
+			if len(report.ServerTiming) > 0 {
+				printServerTiming(report.ServerTiming, report.Timing.Server)
 			}
 		}
 
+		if logFile != "" {
+			if err := appendJSONLine(logFile, &report); err != nil {
+				log.Fatalf("unable to write to log file: %v", err)
+			}
+		}
+		if recordRuns {
+			if err := recordHistory(historyPath(), url, &report); err != nil {
+				log.Fatalf("unable to record history: %v", err)
+			}
+		}
+		if syslogWriter != nil {
+			if _, err := io.WriteString(syslogWriter, syslogLine(url, &report)); err != nil {
+				log.Fatalf("unable to write to syslog: %v", err)
+			}
+		}
+		reports = append(reports, report)
+		if markdownOutput || svgFile != "" {
+			recordHop(url, report, !followRedirects || !isRedirect(resp) || resp.Header.Get("Location") == "")
+		}
+
 		if followRedirects && isRedirect(resp) {
 			loc, err := resp.Location()
 			if err != nil {
 				if err == http.ErrNoLocation {
 					// 30x but no Location to follow, give up.
-					return
+					return reports
 				}
 				log.Fatalf("unable to follow redirect: %v", err)
 			}
@@ -449,12 +1595,333 @@ func visit(url *url.URL) {
 			visit(loc)
 		}
 	}
+
+	if stopped && textReport() {
+		printf("\n%s\n", warnString("Stopped after %d of %d requests, the -total-time of %s is up", len(reports), numRequests, totalTime))
+	}
+	if (numRequests > 1 || summaryOnly) && len(reports) > 0 && writeOutFormat == "" && outputFormat.Template == nil && !markdownOutput {
+		summary := summarize(reports)
+		if !jsonOutput {
+			printSummary(summary, url.Scheme)
+			if len(reports) > 1 {
+				printSparklines(reports, url.Scheme)
+			}
+			if latencyChartRun && len(reports) > 1 {
+				printLatencyChart(reports)
+			}
+		} else if summaryOnly {
+			b, err := json.Marshal(summary)
+			if err != nil {
+				log.Fatalf("unable to marshal json summary: %v", err)
+			}
+			printf("%s\n", b)
+		}
+	}
+	return reports
+}
+
+// roundTrip sends req using client, recording the address connected
+// to and the duration of each phase of the exchange in report. The
+// response body is left unread, call report.finish once it has been
+// consumed to complete the timing.
+func roundTrip(client *http.Client, req *http.Request, report *Report) *http.Response {
+	var tStart, tDNSStart, tConnectStart, tTLSStart, tConnected, tWroteHeaders, tWroteRequest, tWait100, tGot100 time.Time
+	var uploaded int64
+	var handshakeErr, connectErr error
+	var connectAddr string
+	echo := &requestEcho{method: req.Method, target: req.URL.RequestURI()}
+
+	connectDone := func(addr string, err error) {
+		if err != nil {
+			// another address may yet be connected to.
+			connectAddr, connectErr = addr, err
+			return
+		}
+		report.micros.TCP = usSince(tConnectStart)
+		report.micros.Connect = usSince(tStart)
+
+		report.Address = addr
+		if textReport() {
+			printf("\n%s%s\n", okString("Connected to "), valueString(addr))
+		}
+	}
+	handshakeDone := func(cs tls.ConnectionState, err error) {
+		report.micros.TLS = usSince(tTLSStart)
+		if useECH || echConfigFile != "" {
+			report.ECH = newECHInfo(cs, err)
+		}
+		if err != nil {
+			handshakeErr = err
+			return
+		}
+		if showCertInfo || htmlReport != "" {
+			report.TLS = newTLSInfo(cs)
+		}
+		if ocsp := newOCSPInfo(cs); ocsp.Stapled || showCertInfo {
+			report.OCSP = ocsp
+			if w := ocsp.warning(); w != "" {
+				report.Warnings = append(report.Warnings, w)
+			}
+		}
+		if certWarn > 0 {
+			if w := checkCertExpiry(cs, time.Duration(certWarn)); w != "" {
+				report.Warnings = append(report.Warnings, w)
+				if certWarnExit {
+					exitStatus = 1
+				}
+			}
+		}
+	}
+
+	// the transport calls the hooks that write the request and read its
+	// response from goroutines of its own, so mu guards what they share.
+	// A dial it started for the request goes on in the background if an
+	// idle connection turns up first, as between the requests of -n and
+	// watch; its hooks mustn't touch the report once the request has a
+	// connection.
+	var mu sync.Mutex
+	var gotConn bool
+	dialing := func(f func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !gotConn {
+			f()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(_ string) {
+			tStart = time.Now()
+			report.start = tStart
+		},
+		DNSStart: func(_ httptrace.DNSStartInfo) { dialing(func() { tDNSStart = time.Now() }) },
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			dialing(func() {
+				report.micros.DNS = usSince(tDNSStart)
+				report.micros.Lookup = usSince(tStart)
+			})
+		},
+		ConnectStart: func(_, _ string) {
+			dialing(func() {
+				if tConnectStart.IsZero() {
+					// connecting to IP
+					tConnectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(net, addr string, err error) {
+			dialing(func() { connectDone(addr, err) })
+		},
+		TLSHandshakeStart: func() { dialing(func() { tTLSStart = time.Now() }) },
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			dialing(func() { handshakeDone(cs, err) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			gotConn = true
+			tConnected = time.Now()
+			if report.Address == "" && info.Conn != nil {
+				// a reused connection isn't dialed.
+				report.Address = info.Conn.RemoteAddr().String()
+			}
+			report.micros.PreTransfer = usSince(tStart)
+		},
+		WroteHeaderField: func(key string, value []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, v := range value {
+				report.Size.SentBytes += int64(len(key) + len(v) + 4)
+			}
+			if verbose {
+				echo.add(key, value)
+			}
+		},
+		WroteHeaders: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tWroteHeaders = time.Now()
+			if verbose && textReport() {
+				printSentLines(echo.lines())
+			}
+			echo.fields = nil
+		},
+		Wait100Continue: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tWait100 = time.Now()
+		},
+		Got100Continue: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tGot100 = time.Now()
+			report.Continue = &Continue{Wait: msSince(tWait100), Received: true, wait: usSince(tWait100)}
+		},
+		WroteRequest: func(_ httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			tWroteRequest = time.Now()
+			report.Size.SentBytes += atomic.LoadInt64(&uploaded)
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			report.firstByte = time.Now()
+			report.micros.Server = usSince(tConnected)
+			if sent := atomic.LoadInt64(&uploaded); sent > 0 && !tWroteRequest.IsZero() {
+				// the server can't respond before the body has been
+				// received, so don't count uploading it as processing.
+				report.Upload = newUpload(tWroteRequest.Sub(tWroteHeaders), sent)
+				report.micros.Server = usSince(tWroteRequest)
+			}
+			report.micros.StartTransfer = usSince(tStart)
+		},
+	}
+	req = req.Clone(httptrace.WithClientTrace(runContext, trace))
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingReader{req.Body, &uploaded}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		switch {
+		case describeTimeout(err) != "":
+			failRequest(err, "%s", describeTimeout(err))
+		case handshakeErr != nil:
+			failRequest(err, "TLS handshake failed: %v", describeHandshakeError(handshakeErr))
+		case connectErr != nil && report.Address == "":
+			failRequest(connectErr, "unable to connect to host %v: %v", connectAddr, connectErr)
+		}
+		failRequest(err, "failed to read response: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	sent := atomic.LoadInt64(&uploaded)
+	if !tWait100.IsZero() {
+		if report.Continue == nil {
+			report.Continue = &Continue{}
+		}
+		report.Continue.BodySent = sent > 0
+	}
+	if !tGot100.IsZero() {
+		// the first response byte traced was the 100 Continue's, so
+		// time the final response by its headers instead.
+		report.firstByte = time.Now()
+		report.micros.Server = usSince(tWroteRequest)
+		report.micros.StartTransfer = usSince(tStart)
+		report.Upload = newUpload(tWroteRequest.Sub(tGot100), sent)
+	}
+	if resp.ProtoMajor == 1 {
+		// the request line and the blank line ending the headers; HTTP/2
+		// sends the method and path as header fields, traced with the rest.
+		report.Size.SentBytes += int64(len(req.Method + " " + req.URL.RequestURI() + " HTTP/1.1\r\n\r\n"))
+	}
+	report.Size.HeaderBytes = responseHeaderSize(resp)
+	// after an upgrade the body is the connection, which must stay writable.
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body = &countingReader{resp.Body, &report.Size.BodyBytes}
+	}
+	return resp
+}
+
+// finish completes the timing of an exchange once the response body
+// has been read.
+func (r *Report) finish() {
+	end := time.Now()
+	if !r.transferEnd.IsZero() {
+		end = r.transferEnd
+	}
+	r.micros.Transfer = int(end.Sub(r.firstByte) / time.Microsecond)
+	if secs := end.Sub(r.firstByte).Seconds(); secs > 0 {
+		r.Size.Throughput = float64(r.Size.BodyBytes) / 1e6 / secs
+	}
+	r.micros.Total = usSince(r.start)
+	r.Timing = r.micros.millis()
+	if timingPrecision == "us" {
+		micros := r.micros
+		r.TimingMicros = &micros
+	}
+}
+
+// recoverFailures makes a failed request panic with a *requestError
+// for tryVisit to recover, rather than exit.
+var recoverFailures bool
+
+// requestError is the failure of a request.
+type requestError struct {
+	msg string
+	err error
+}
+
+func (e *requestError) Error() string { return e.msg }
+
+// failRequest ends a request that failed with err, described by format.
+func failRequest(err error, format string, v ...interface{}) {
+	if !recoverFailures {
+		log.Fatalf(format, v...)
+	}
+	panic(&requestError{fmt.Sprintf(format, v...), err})
+}
+
+// hop is a response of the redirect chain.
+type hop struct {
+	url    string
+	report Report
+}
+
+var (
+	// redirectChain holds the first request and the redirects it was
+	// sent on to, for -markdown and -svg.
+	redirectChain     []hop
+	redirectChainDone bool
+)
+
+// recordHop adds r, the response from u, to the redirect chain unless
+// it is complete; last is whether r isn't followed further.
+func recordHop(u *url.URL, r Report, last bool) {
+	if redirectChainDone {
+		return
+	}
+	redirectChain = append(redirectChain, hop{u.String(), r})
+	redirectChainDone = last
+}
+
+// tryVisit visits url, returning the failure of a request rather than
+// exiting, for subcommands that carry on regardless.
+func tryVisit(url *url.URL) (reports []Report, err error) {
+	recoverFailures = true
+	defer func() {
+		recoverFailures = false
+		if r := recover(); r != nil {
+			e, ok := r.(*requestError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	return visit(url), nil
 }
 
 func msSince(t time.Time) int {
 	return int(time.Now().Sub(t) / time.Millisecond)
 }
 
+// printWaterfall prints the timing diagram, in microseconds, of a
+// request by scheme, compact if asked for or the terminal is too narrow
+// for the template.
+func printWaterfall(scheme string, t Timing) {
+	tmpl := httpTemplate
+	if scheme == "https" {
+		tmpl = httpsTemplate
+	}
+	if compactWaterfall || terminalWidth > 0 && terminalWidth < templateWidth(tmpl) {
+		printCompactWaterfall(scheme, t, terminalWidth)
+		return
+	}
+	printTemplate(tmpl, t)
+}
+
 func printTemplate(tmpl string, vars Timing) {
 	rvars := reflect.ValueOf(vars)
 	b := []byte(tmpl)
@@ -469,9 +1936,9 @@ func printTemplate(tmpl string, vars Timing) {
 		if !val.IsValid() {
 			panic("invalid template variable: " + vnam)
 		}
-		v := strconv.Itoa(val.Interface().(int)) + "ms"
+		v := formatTiming(val.Interface().(int))
 		vlen := len(v)
-		v = color.CyanString(v)
+		v = valueString(v)
 		switch dir {
 		case '>':
 			b = append(append(append([]byte{}, b[:end-vlen]...), []byte(v)...), b[end:]...)
@@ -482,36 +1949,116 @@ func printTemplate(tmpl string, vars Timing) {
 		}
 		idx = end
 	}
-	print(string(b))
+	printf("%s", b)
 }
 
 func isRedirect(resp *http.Response) bool {
 	return resp.StatusCode > 299 && resp.StatusCode < 400
 }
 
+// prepareRequest creates the request for url, set up for -ws or -grpc.
+func prepareRequest(url *url.URL) *http.Request {
+	req := newRequest(httpMethod, url, postBody)
+	switch {
+	case wsMode:
+		setWebSocketHeaders(req)
+	case grpcMode:
+		setGRPCHealthRequest(req, grpcService)
+	}
+	return req
+}
+
 func newRequest(method string, url *url.URL, body string) *http.Request {
+	url = expandURL(url)
+	if !strings.HasPrefix(body, "@") {
+		body = expandTemplate(body)
+	}
 	req, err := http.NewRequest(method, url.String(), createBody(body))
 	if err != nil {
 		log.Fatalf("unable to create request: %v", err)
 	}
+	if strings.HasPrefix(body, "@") && body != "@-" {
+		// a file is read again to send the request again, as
+		// authentication does.
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(createBody(body)), nil
+		}
+	}
+	if len(formData) > 0 {
+		if err := setFormBody(req, formData); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if bodyRandom.max > 0 {
+		setRandomBody(req, bodyRandom)
+	}
+	if compressBody != "" {
+		setCompressedBody(req, compressBody)
+	}
+	if chunkedUpload {
+		setChunked(req)
+	}
+	if len(requestTrailers) > 0 {
+		setTrailers(req, requestTrailers)
+	}
+	if expect100 {
+		setExpectContinue(req)
+	}
+	setAuthorization(req)
+	if compressed {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	for _, c := range requestCookies {
+		req.AddCookie(c)
+	}
 	for _, h := range httpHeaders {
 		k, v := headerKeyValue(h)
+		v = expandTemplate(v)
 		if strings.EqualFold(k, "host") {
 			req.Host = v
 			continue
 		}
 		req.Header.Add(k, v)
 	}
+	if continueAt != "" {
+		setResumeRange(req)
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", "bytes="+byteRange)
+	}
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		// ask for gzip as the transport would, but explicitly so the
+		// body isn't transparently decoded and its size is as sent.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if awsCreds != nil && req.URL.Host == authHost {
+		if err := signSigV4(req, awsCreds, awsRegion, awsService, time.Now()); err != nil {
+			log.Fatalf("unable to sign request: %v", err)
+		}
+	}
 	return req
 }
 
 func createBody(body string) io.Reader {
+	if body == "@-" {
+		if chunkedUpload {
+			return os.Stdin
+		}
+		return bytes.NewReader(stdinBody)
+	}
 	if strings.HasPrefix(body, "@") {
 		filename := body[1:]
 		f, err := os.Open(filename)
 		if err != nil {
 			log.Fatalf("failed to open data file %s: %v", filename, err)
 		}
+		if showProgress() {
+			size := int64(-1)
+			if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+				size = fi.Size()
+			}
+			return newProgressReader(f, "Uploading", size)
+		}
 		return f
 	}
 	return strings.NewReader(body)
@@ -543,15 +2090,47 @@ func getFilenameFromHeaders(headers http.Header) string {
 // readResponseBody consumes the body of the response.
 // readResponseBody returns an informational message about the
 // disposition of the response body's contents.
-func readResponseBody(req *http.Request, resp *http.Response) string {
+func readResponseBody(req *http.Request, resp *http.Response, report *Report) (msg string) {
+	if saveRaw != "" {
+		raw, err := createRawResponse(rawResponseFilename(saveRaw, requestSeq, numRequests), resp)
+		if err != nil {
+			log.Fatalf("unable to save raw response: %v", err)
+		}
+		defer raw.Close()
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(resp.Body, raw), resp.Body}
+	}
+
 	if isRedirect(resp) || req.Method == http.MethodHead {
+		if saveRaw != "" {
+			io.Copy(ioutil.Discard, resp.Body)
+		}
 		return ""
 	}
 
+	var sum hash.Hash
+	resp.Body, sum = hashBody(resp.Body)
+	defer func() { report.SHA256 = hex.EncodeToString(sum.Sum(nil)) }()
+
+	if streamTrace {
+		t := &streamTracer{ReadCloser: resp.Body, start: report.firstByte}
+		resp.Body = t
+		defer func() { report.Stream = t.chunks }()
+	}
+
 	w := ioutil.Discard
-	msg := color.CyanString("Body discarded")
+	msg = valueString("Body discarded")
 
-	if saveOutput || outputFile != "" {
+	if resumeFrom > 0 {
+		report.Resume = checkResume(resp, resumeFrom)
+	}
+
+	if outputFile == "-" {
+		w = os.Stdout
+		msg = valueString("Body written to stdout")
+	} else if (saveOutput || outputFile != "") && (report.Resume == nil || !report.Resume.Complete) {
 		filename := outputFile
 
 		if saveOutput {
@@ -566,22 +2145,155 @@ func readResponseBody(req *http.Request, resp *http.Response) string {
 			}
 		}
 
-		f, err := os.Create(filename)
+		var f *os.File
+		var err error
+		if report.Resume != nil && report.Resume.Honored {
+			// append to the partial download, which the response
+			// didn't name.
+			filename = resumeFilename(req.URL)
+			f, err = os.OpenFile(filename, os.O_WRONLY, 0)
+			if err == nil {
+				err = f.Truncate(resumeFrom)
+			}
+			if err == nil {
+				_, err = f.Seek(resumeFrom, io.SeekStart)
+			}
+		} else {
+			f, err = os.Create(filename)
+		}
 		if err != nil {
 			log.Fatalf("unable to create file %s: %v", filename, err)
 		}
 		defer f.Close()
 		w = f
-		msg = color.CyanString("Body read")
+		msg = valueString("Body read")
+
+		if showProgress() {
+			resp.Body = newProgressReader(resp.Body, "Downloading", resp.ContentLength)
+		}
 	}
 
-	if _, err := io.Copy(w, resp.Body); err != nil && w != ioutil.Discard {
-		log.Fatalf("failed to read response body: %v", err)
+	// keep is whether the body is used rather than discarded, so it
+	// must be decoded and read without errors.
+	keep := w != ioutil.Discard
+
+	// GraphQL responses are checked for errors.
+	if graphqlQuery != "" && isJSON(resp.Header.Get("Content-Type")) {
+		keep = true
+		var b bytes.Buffer
+		w = io.MultiWriter(w, &b)
+		defer func() {
+			errs, err := graphqlErrors(b.Bytes())
+			if err != nil {
+				errs = []string{err.Error()}
+			}
+			report.GraphQLErrors = errs
+		}()
+	}
+
+	if len(extractExprs) > 0 {
+		keep = true
+		var b bytes.Buffer
+		w = io.MultiWriter(w, &b)
+		defer func() {
+			values, err := extractExprs.extract(b.Bytes())
+			if err != nil {
+				report.Warnings = append(report.Warnings, err.Error())
+			}
+			for _, x := range values {
+				if x.Name != "" && x.Found {
+					extractedVars[x.Name] = templateValue(x.Value)
+				}
+			}
+			report.Extracted = values
+		}()
+	}
+
+	if bodyMatch.Regexp != nil {
+		keep = true
+		b := &cappedBuffer{max: bodyMatchMax}
+		w = io.MultiWriter(w, b)
+		defer func() { report.BodyMatch = matchBody(bodyMatch.Regexp, b.Bytes()) }()
+	}
+
+	if pageLoad && isHTML(resp.Header.Get("Content-Type")) {
+		keep = true
+		b := &cappedBuffer{max: pageMax}
+		w = io.MultiWriter(w, b)
+		defer func() { report.document = b.Bytes() }()
+	}
+
+	if showBody && textReport() {
+		if contentType := resp.Header.Get("Content-Type"); showable(contentType) {
+			keep = true
+			shown := &cappedBuffer{max: showBodyMax}
+			w = io.MultiWriter(w, shown)
+			defer func() { msg = formatBody(shown, contentType) }()
+		} else if !keep {
+			msg = valueString("Body discarded, not text or JSON")
+		}
+	}
+
+	if encoding := resp.Header.Get("Content-Encoding"); compressed && encoding != "" && !resp.Uncompressed {
+		// read the whole body before decoding it so the transfer and
+		// decoding are timed separately.
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			failRequest(err, "failed to read response body: %v", err)
+		}
+		report.transferEnd = time.Now()
+		report.Compression, err = decodeBody(w, encoding, body)
+		if err != nil {
+			failRequest(err, "%v", err)
+		}
+		return msg
+	}
+
+	if sseMode && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		report.SSE = readSSE(resp.Body, w, report.start, sseEvents)
+		return msg
+	}
+
+	var body io.Reader = resp.Body
+	if encoding := resp.Header.Get("Content-Encoding"); keep && encoding != "" && !resp.Uncompressed {
+		// save the body as it would be used rather than as sent.
+		r, ok, err := decoder(encoding, resp.Body)
+		if err != nil {
+			failRequest(err, "unable to decode %s body: %v", encoding, err)
+		}
+		if ok {
+			defer r.Close()
+			body = r
+		}
+	}
+
+	if _, err := io.Copy(w, body); err != nil && keep {
+		failRequest(err, "failed to read response body: %v", err)
 	}
 
 	return msg
 }
 
+// days is a time.Duration flag which additionally accepts a whole
+// number of days, eg. 30d.
+type days time.Duration
+
+func (d days) String() string { return time.Duration(d).String() }
+
+func (d *days) Set(v string) error {
+	if n := strings.TrimSuffix(v, "d"); n != v {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return fmt.Errorf("invalid number of days %q", v)
+		}
+		*d = days(time.Duration(i) * 24 * time.Hour)
+		return nil
+	}
+	dur, err := time.ParseDuration(v)
+	*d = days(dur)
+	return err
+}
+
 type headers []string
 
 func (h headers) String() string {
@@ -634,3 +2346,13 @@ func (h headers) Less(i, j int) bool {
 	}
 	return x
 }
+
+// has reports whether the header key is set.
+func (h headers) has(key string) bool {
+	for _, v := range h {
+		if k, _ := headerKeyValue(v); strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
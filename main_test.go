@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
 
 func TestParseURL(t *testing.T) {
 	tests := []struct {
@@ -24,15 +28,131 @@ func TestParseURL(t *testing.T) {
 }
 
 func TestReadClientCert(t *testing.T) {
-	_, err := readClientCert("./test/singlecert.pem")
+	_, err := readClientCert("./test/singlecert.pem", "")
 
 	if err != nil {
 		t.Errorf("unable to read single cert and key: %v", err)
 	}
 
-	_, err = readClientCert("./test/multicert.pem")
+	_, err = readClientCert("./test/multicert.pem", "")
 
 	if err != nil {
 		t.Errorf("unable to read multiple certs and key: %v", err)
 	}
+
+	_, err = readClientCert("./test/clientcert.pem", "./test/clientkey.pem")
+
+	if err != nil {
+		t.Errorf("unable to read separate cert and key: %v", err)
+	}
+
+	clientCertPass = "secret"
+	_, err = readClientCert("./test/client.p12", "")
+	clientCertPass = ""
+
+	if err != nil {
+		t.Errorf("unable to read PKCS#12 cert and key: %v", err)
+	}
+
+	clientKeyPass = "secret"
+	_, err = readClientCert("./test/clientcert.pem", "./test/clientkey-encrypted.pem")
+	clientKeyPass = ""
+
+	if err != nil {
+		t.Errorf("unable to read cert and encrypted key: %v", err)
+	}
+
+	_, err = readClientCert("./test/clientcert.pem", "")
+
+	if err == nil {
+		t.Errorf("expected error reading cert without key")
+	}
+}
+
+func TestDaysSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"0d", 0},
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+
+	for _, test := range tests {
+		var d days
+		if err := d.Set(test.in); err != nil {
+			t.Errorf("Given: %s\nunexpected error: %v", test.in, err)
+			continue
+		}
+		if time.Duration(d) != test.want {
+			t.Errorf("Given: %s\nwant: %v\ngot: %v", test.in, test.want, time.Duration(d))
+		}
+	}
+
+	var d days
+	if err := d.Set("xd"); err == nil {
+		t.Errorf("expected error parsing xd")
+	}
+}
+
+func TestParsePKCS11Spec(t *testing.T) {
+	tests := []struct {
+		in     string
+		module string
+		slot   uint
+		id     string
+	}{
+		{"/usr/lib/softhsm/libsofthsm2.so:0:01", "/usr/lib/softhsm/libsofthsm2.so", 0, "\x01"},
+		{`C:\Windows\opensc-pkcs11.dll:2:a0b1`, `C:\Windows\opensc-pkcs11.dll`, 2, "\xa0\xb1"},
+	}
+
+	for _, test := range tests {
+		module, slot, id, err := parsePKCS11Spec(test.in)
+		if err != nil || module != test.module || slot != test.slot || string(id) != test.id {
+			t.Errorf("Given: %s\nwant: %s %d %x\ngot: %s %d %x (%v)", test.in, test.module, test.slot, test.id, module, slot, id, err)
+		}
+	}
+
+	for _, in := range []string{"module.so", "module.so:x:01", "module.so:0:zz"} {
+		if _, _, _, err := parsePKCS11Spec(in); err == nil {
+			t.Errorf("Given: %s\nexpected error", in)
+		}
+	}
+}
+
+func TestCreateBodyFromStdin(t *testing.T) {
+	defer func(b []byte) { stdinBody = b }(stdinBody)
+	stdinBody = []byte("piped")
+
+	// each request gets the whole body, so it can be sent again.
+	for i := 0; i < 2; i++ {
+		b, err := ioutil.ReadAll(createBody("@-"))
+		if err != nil || string(b) != "piped" {
+			t.Errorf("read %q, %v, want piped", b, err)
+		}
+	}
+}
+
+func TestColorTerminal(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if colorTerminal(f) {
+		t.Errorf("a file is a color terminal")
+	}
+}
+
+func TestTimestampFormat(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 7, 9, 123456789, time.FixedZone("", -5*3600))
+	s := now.Format(timestampFormat)
+	if s != "2024-03-05T14:07:09.123-05:00" {
+		t.Errorf("got %q", s)
+	}
+	if _, err := time.Parse(time.RFC3339, s); err != nil {
+		t.Errorf("%q isn't RFC 3339: %v", s, err)
+	}
 }
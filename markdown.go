@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// markdownEscape escapes s for a cell of a Markdown table.
+var markdownEscape = strings.NewReplacer(`|`, `\|`, "\n", " ").Replace
+
+// markdownTable formats a Markdown table; align gives the alignment
+// line of each column, such as --- or ---:.
+func markdownTable(header, align []string, rows [][]string) string {
+	var b strings.Builder
+	line := func(cells []string) {
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	line(header)
+	line(align)
+	for _, r := range rows {
+		line(r)
+	}
+	return b.String()
+}
+
+// markdownPhases returns the phases of the waterfall for scheme, as
+// summaryPhases but for the total.
+func markdownPhases(scheme string) (phases []string, timed []func(Timing) int) {
+	for _, p := range summaryPhases[:len(summaryPhases)-1] {
+		if p.name == "TLS Handshake" && scheme != "https" {
+			continue
+		}
+		phases, timed = append(phases, p.name), append(timed, p.timed)
+	}
+	return phases, timed
+}
+
+// printMarkdown prints the report of the requests to u as Markdown, to
+// paste into issues and wikis.
+func printMarkdown(u *url.URL, reports []Report) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s %s\n\n", httpMethod, markdownEscape(u.String()))
+	phases, timed := markdownPhases(u.Scheme)
+
+	if len(reports) == 1 {
+		r := reports[0]
+		fmt.Fprintf(&b, "**%s** over %s from %s\n\n", markdownEscape(r.Status), r.Proto, markdownEscape(r.Address))
+		var rows [][]string
+		for i, p := range phases {
+			rows = append(rows, []string{p, formatTiming(timed[i](r.micros))})
+		}
+		rows = append(rows, []string{"**Total**", "**" + formatTiming(r.micros.Total) + "**"})
+		b.WriteString(markdownTable([]string{"Phase", "Time"}, []string{"---", "---:"}, rows))
+	} else {
+		header := append(append([]string{"#", "Status"}, phases...), "Total")
+		align := []string{"---:", "---"}
+		for range phases {
+			align = append(align, "---:")
+		}
+		align = append(align, "---:")
+		var rows [][]string
+		for n, r := range reports {
+			row := []string{fmt.Sprint(n + 1), markdownEscape(r.Status)}
+			for _, t := range timed {
+				row = append(row, formatTiming(t(r.micros)))
+			}
+			rows = append(rows, append(row, "**"+formatTiming(r.micros.Total)+"**"))
+		}
+		b.WriteString(markdownTable(header, align, rows))
+
+		ms := "%.1fms"
+		if timingPrecision == "us" {
+			ms = "%.3fms"
+		}
+		s := summarize(reports)
+		rows = nil
+		for _, p := range summaryPhases {
+			if p.name == "TLS Handshake" && u.Scheme != "https" {
+				continue
+			}
+			st := p.stats(s)
+			rows = append(rows, []string{p.name, fmt.Sprintf(ms, st.Min), fmt.Sprintf(ms, st.Avg),
+				fmt.Sprintf(ms, st.P50), fmt.Sprintf(ms, st.P95), fmt.Sprintf(ms, st.Max)})
+		}
+		fmt.Fprintf(&b, "\n#### Summary of %d requests\n\n", len(reports))
+		b.WriteString(markdownTable([]string{"Phase", "min", "avg", "p50", "p95", "max"},
+			[]string{"---", "---:", "---:", "---:", "---:", "---:"}, rows))
+	}
+
+	if len(redirectChain) > 1 {
+		var rows [][]string
+		for n, h := range redirectChain {
+			rows = append(rows, []string{fmt.Sprint(n + 1), markdownEscape(h.url),
+				markdownEscape(h.report.Status), formatTiming(h.report.micros.Total)})
+		}
+		b.WriteString("\n#### Redirects\n\n")
+		b.WriteString(markdownTable([]string{"#", "URL", "Status", "Total"},
+			[]string{"---:", "---", "---", "---:"}, rows))
+	}
+	printf("%s", b.String())
+}
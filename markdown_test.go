@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestMarkdownTable(t *testing.T) {
+	got := markdownTable([]string{"Phase", "Time"}, []string{"---", "---:"},
+		[][]string{{"DNS Lookup", "5ms"}, {markdownEscape("a|b"), "1ms"}})
+	want := "| Phase | Time |\n| --- | ---: |\n| DNS Lookup | 5ms |\n| a\\|b | 1ms |\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrintMarkdown(t *testing.T) {
+	output, chain, done := color.Output, redirectChain, redirectChainDone
+	defer func() { color.Output, redirectChain, redirectChainDone = output, chain, done }()
+	var buf bytes.Buffer
+	color.Output, redirectChain, redirectChainDone = &buf, nil, false
+
+	first, _ := url.Parse("http://example.com/old")
+	last, _ := url.Parse("https://example.com/new")
+	r := Report{Status: "301 Moved Permanently", Proto: "HTTP/1.1", Address: "192.0.2.1:80",
+		micros: Timing{DNS: 2000, TCP: 3000, Server: 4000, Transfer: 1000, Total: 10000}}
+	recordHop(first, r, false)
+	recordHop(last, Report{Status: "200 OK", micros: Timing{Total: 20000}}, true)
+	recordHop(first, r, false)
+
+	printMarkdown(first, []Report{r})
+	out := buf.String()
+	for _, s := range []string{
+		"### GET http://example.com/old\n",
+		"**301 Moved Permanently** over HTTP/1.1 from 192.0.2.1:80\n",
+		"| Server Processing | 4ms |\n",
+		"| **Total** | **10ms** |\n",
+		"| 2 | https://example.com/new | 200 OK | 20ms |\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output doesn't contain %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "TLS Handshake") {
+		t.Errorf("TLS handshake shown for http:\n%s", out)
+	}
+	if strings.Count(out, "example.com/old |") != 1 {
+		t.Errorf("redirect chain not recorded once:\n%s", out)
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// monitorConfig is the targets file of the monitor subcommand.
+type monitorConfig struct {
+	Interval time.Duration    `yaml:"interval"`
+	Targets  []*monitorTarget `yaml:"targets"`
+	Sinks    struct {
+		JSONL        string `yaml:"jsonl"`
+		Prometheus   string `yaml:"prometheus"`
+		Statsd       string `yaml:"statsd"`
+		StatsdPrefix string `yaml:"statsd_prefix"`
+	} `yaml:"sinks"`
+}
+
+// monitorTarget is a URL the monitor probes, and the rolling statistics
+// of the probes.
+type monitorTarget struct {
+	Name         string            `yaml:"name"`
+	URL          string            `yaml:"url"`
+	Method       string            `yaml:"method"`
+	Headers      map[string]string `yaml:"headers"`
+	Body         string            `yaml:"body"`
+	Interval     time.Duration     `yaml:"interval"`
+	Timeout      time.Duration     `yaml:"timeout"`
+	ExpectStatus int               `yaml:"expect_status"`
+	Insecure     bool              `yaml:"insecure"`
+
+	mu       sync.Mutex
+	probes   int
+	failures int
+	upTime   float64         // seconds, of the probes that were up
+	window   []MonitorResult // the last probes, oldest first
+	size     int             // of the window
+}
+
+// MonitorResult is the result of a probe of a monitor target, with the
+// rolling statistics of the window of probes it ends.
+type MonitorResult struct {
+	Time   string
+	Target string
+	URL    string
+	Up     bool
+	Status string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+	Timing Timing
+
+	// Availability is the percentage of the window's probes that were up.
+	Availability float64
+	// P50 and P95 are of the total time of the window's probes that were
+	// up, in milliseconds.
+	P50 float64
+	P95 float64
+
+	micros Timing // the timing in microseconds
+}
+
+// loadMonitorConfig reads the targets file, defaulting the interval of
+// the targets to interval and their window to window probes.
+func loadMonitorConfig(filename string, interval time.Duration, window int) (*monitorConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c monitorConfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if c.Interval == 0 {
+		c.Interval = interval
+	}
+	if len(c.Targets) == 0 {
+		return nil, fmt.Errorf("%s: no targets", filename)
+	}
+	names := map[string]bool{}
+	for i, t := range c.Targets {
+		if t.URL == "" {
+			return nil, fmt.Errorf("%s: target %d has no url", filename, i+1)
+		}
+		t.URL = parseURL(t.URL).String()
+		if t.Name == "" {
+			t.Name = t.URL
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("%s: more than one target named %q", filename, t.Name)
+		}
+		names[t.Name] = true
+		if t.Method == "" {
+			t.Method = http.MethodGet
+		}
+		if t.Interval <= 0 {
+			t.Interval = c.Interval
+		}
+		if t.Interval <= 0 {
+			return nil, fmt.Errorf("%s: target %q has no interval", filename, t.Name)
+		}
+		if t.Timeout <= 0 {
+			// a probe mustn't overrun the next.
+			t.Timeout = t.Interval
+		}
+		t.size = window
+	}
+	return &c, nil
+}
+
+// up reports whether a probe of t that got status, or failed with err,
+// found the target up: it answered with the status expected, or without
+// an error status if none is.
+func (t *monitorTarget) up(status, err string) bool {
+	if err != "" {
+		return false
+	}
+	code := statusNumber(status)
+	if t.ExpectStatus != 0 {
+		return code == t.ExpectStatus
+	}
+	return code > 0 && code < 400
+}
+
+// add records the probe r of t, setting its rolling statistics.
+func (t *monitorTarget) add(r *MonitorResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probes++
+	if r.Up {
+		t.upTime += float64(r.micros.Total) / 1e6
+	} else {
+		t.failures++
+	}
+	t.window = append(t.window, *r)
+	if len(t.window) > t.size {
+		t.window = t.window[1:]
+	}
+	var up []float64
+	for _, p := range t.window {
+		if p.Up {
+			up = append(up, float64(p.micros.Total)/1000)
+		}
+	}
+	s := newStats(up)
+	r.Availability = 100 * float64(len(up)) / float64(len(t.window))
+	r.P50, r.P95 = s.P50, s.P95
+	t.window[len(t.window)-1] = *r
+}
+
+// snapshot returns the number of probes and failures of t, the total
+// time of the probes that were up, and its latest probe.
+func (t *monitorTarget) snapshot() (probes, failures int, upTime float64, last *MonitorResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.window) > 0 {
+		r := t.window[len(t.window)-1]
+		last = &r
+	}
+	return t.probes, t.failures, t.upTime, last
+}
+
+// recent returns the latest n probes of t, oldest first.
+func (t *monitorTarget) recent(n int) []MonitorResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]MonitorResult(nil), t.window[max(len(t.window)-n, 0):]...)
+}
+
+// client returns the client to probe t with, which connects afresh for
+// every probe so each times the lookup, connection and handshake too.
+func (t *monitorTarget) client() *http.Client {
+	client := crawlClient(1)
+	client.Timeout = t.Timeout
+	tr := client.Transport.(*http.Transport)
+	tr.TLSClientConfig.InsecureSkipVerify = t.Insecure
+	tr.DisableKeepAlives = true
+	return client
+}
+
+// probe makes a request to t and records the result.
+func (t *monitorTarget) probe(client *http.Client) MonitorResult {
+	r := MonitorResult{Time: time.Now().Format(timestampFormat), Target: t.Name, URL: t.URL}
+	var res Resource
+	req, err := http.NewRequest(t.Method, t.URL, strings.NewReader(t.Body))
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		for k, v := range t.Headers {
+			if strings.EqualFold(k, "host") {
+				req.Host = v
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+		timeResource(client, &res, req, time.Now())
+	}
+	r.Status, r.Error, r.Timing, r.micros = res.Status, res.Error, res.Timing, res.micros
+	r.Up = t.up(r.Status, r.Error)
+	t.add(&r)
+	return r
+}
+
+// monitorSink is where the monitor writes the result of each probe.
+type monitorSink interface {
+	write(r MonitorResult) error
+}
+
+// jsonlSink appends each result to a file as a line of JSON.
+type jsonlSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *jsonlSink) write(r MonitorResult) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+// statsdName matches what can't be part of a statsd metric name.
+var statsdName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// statsdSink sends the timing of each probe to a statsd server, as
+// PREFIX.TARGET.PHASE timers, an up gauge and probe and failure counters.
+type statsdSink struct {
+	conn   net.Conn
+	prefix string
+}
+
+func (s *statsdSink) write(r MonitorResult) error {
+	name := s.prefix + "." + strings.Trim(statsdName.ReplaceAllString(r.Target, "_"), "_")
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s.probes:1|c\n", name)
+	if !r.Up {
+		fmt.Fprintf(&b, "%s.failures:1|c\n%s.up:0|g\n", name, name)
+	} else {
+		fmt.Fprintf(&b, "%s.up:1|g\n", name)
+	}
+	if r.Error == "" {
+		for _, p := range monitorPhases(r.micros) {
+			fmt.Fprintf(&b, "%s.%s:%s|ms\n", name, p.name, trimFloat(float64(p.us)/1000))
+		}
+	}
+	_, err := s.conn.Write([]byte(b.String()))
+	return err
+}
+
+// monitorPhase is a phase of a probe, timed in microseconds.
+type monitorPhase struct {
+	name string
+	us   int
+}
+
+func monitorPhases(t Timing) []monitorPhase {
+	return []monitorPhase{{"dns", t.DNS}, {"tcp", t.TCP}, {"tls", t.TLS}, {"server", t.Server}, {"transfer", t.Transfer}, {"total", t.Total}}
+}
+
+// promLabel escapes a Prometheus label value.
+var promLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics writes the state of the targets in the Prometheus text
+// exposition format.
+func writeMetrics(w io.Writer, targets []*monitorTarget) {
+	type sample struct {
+		labels string
+		value  float64
+		suffix string // of the metric name, for the sum and count of a summary
+	}
+	metrics := []struct {
+		name, kind, help string
+		samples          []sample
+	}{
+		{"httpstat_up", "gauge", "Whether the last probe found the target up.", nil},
+		{"httpstat_probes_total", "counter", "Probes made of the target.", nil},
+		{"httpstat_probe_failures_total", "counter", "Probes that found the target down.", nil},
+		{"httpstat_probe_duration_seconds", "gauge", "Time each phase of the last probe took.", nil},
+		{"httpstat_availability_ratio", "gauge", "Fraction of the probes of the rolling window that were up.", nil},
+		{"httpstat_latency_seconds", "summary", "Total time of the probes that were up, the quantiles of those of the rolling window.", nil},
+	}
+	for _, t := range targets {
+		probes, failures, upTime, last := t.snapshot()
+		if last == nil {
+			continue
+		}
+		target := fmt.Sprintf(`target="%s"`, promLabel.Replace(t.Name))
+		up := 0.0
+		if last.Up {
+			up = 1
+		}
+		metrics[0].samples = append(metrics[0].samples, sample{target, up, ""})
+		metrics[1].samples = append(metrics[1].samples, sample{target, float64(probes), ""})
+		metrics[2].samples = append(metrics[2].samples, sample{target, float64(failures), ""})
+		if last.Error == "" {
+			for _, p := range monitorPhases(last.micros) {
+				metrics[3].samples = append(metrics[3].samples, sample{target + `,phase="` + p.name + `"`, float64(p.us) / 1e6, ""})
+			}
+		}
+		metrics[4].samples = append(metrics[4].samples, sample{target, last.Availability / 100, ""})
+		metrics[5].samples = append(metrics[5].samples,
+			sample{target + `,quantile="0.5"`, last.P50 / 1000, ""}, sample{target + `,quantile="0.95"`, last.P95 / 1000, ""},
+			sample{target, upTime, "_sum"}, sample{target, float64(probes - failures), "_count"})
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, s := range m.samples {
+			fmt.Fprintf(w, "%s%s{%s} %g\n", m.name, s.suffix, s.labels, s.value)
+		}
+	}
+}
+
+func printMonitorResult(r MonitorResult) {
+	at := labelString(r.Time)
+	name := valueString(r.Target)
+	stats := labelString("(%.1f%% up, p50 %.1fms, p95 %.1fms)", r.Availability, r.P50, r.P95)
+	switch {
+	case r.Error != "":
+		printf("%s %s %s %s %s\n", at, name, errorString("DOWN"), errorString(r.Error), stats)
+	case !r.Up:
+		printf("%s %s %s %s %s\n", at, name, errorString("DOWN"), statusString(r.Status), stats)
+	default:
+		printf("%s %s %s %s %s\n", at, name, statusString(r.Status), valueString(formatTiming(r.micros.Total)), stats)
+	}
+}
+
+// runMonitor is the monitor subcommand, which probes each target of a
+// file on schedule until stopped, writing the results to the sinks.
+func runMonitor(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s monitor -targets FILE [OPTIONS]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Probe each target of a YAML file every -interval until stopped, keeping rolling statistics of")
+		fmt.Fprintln(os.Stderr, "the last -window probes and writing each result to JSONL, Prometheus or statsd sinks.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	targets := fs.String("targets", "", "YAML file of the targets, and the sinks to write to")
+	interval := fs.Duration("interval", time.Minute, "probe each target this often, unless the file sets it")
+	window := fs.Int("window", 100, "number of probes of each target the rolling statistics are of")
+	jsonlFile := fs.String("jsonl", "", "append each result to this file as a line of JSON, or - for stdout")
+	promAddr := fs.String("prometheus", "", "serve the metrics of the targets for Prometheus at /metrics on this address, eg. :9115")
+	statsdAddr := fs.String("statsd", "", "send the timing of each probe to the statsd server at host:port")
+	statsdPrefix := fs.String("statsd-prefix", "", "prefix of the statsd metric names; httpstat unless the file sets it")
+	alertWebhook := fs.String("alert-webhook", "", "POST a JSON alert to this URL when a probe meets -alert-when")
+	alertWhen := fs.String("alert-when", "down", "condition to alert on, eg. 'total>1s || status>=500': comparisons of "+
+		strings.Join(alertMetricNames, ", ")+" joined by && and ||, or down")
+	alertCooldown := fs.Duration("alert-cooldown", 5*time.Minute, "alert on a target at most this often")
+	quiet := fs.Bool("q", false, "don't print each result")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *targets == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	c, err := loadMonitorConfig(*targets, *interval, max(*window, 1))
+	if err != nil {
+		log.Fatalf("unable to read targets: %v", err)
+	}
+	// the flags override the sinks of the file.
+	for _, s := range []struct{ flag, file *string }{
+		{jsonlFile, &c.Sinks.JSONL}, {promAddr, &c.Sinks.Prometheus}, {statsdAddr, &c.Sinks.Statsd}, {statsdPrefix, &c.Sinks.StatsdPrefix},
+	} {
+		if *s.flag != "" {
+			*s.file = *s.flag
+		}
+	}
+	if c.Sinks.StatsdPrefix == "" {
+		c.Sinks.StatsdPrefix = "httpstat"
+	}
+
+	var sinks []monitorSink
+	switch c.Sinks.JSONL {
+	case "":
+	case "-":
+		sinks = append(sinks, &jsonlSink{w: os.Stdout})
+		*quiet = true
+	default:
+		f, err := os.OpenFile(c.Sinks.JSONL, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatalf("unable to open JSONL sink: %v", err)
+		}
+		defer f.Close()
+		sinks = append(sinks, &jsonlSink{w: f})
+	}
+	if c.Sinks.Statsd != "" {
+		conn, err := net.Dial("udp", c.Sinks.Statsd)
+		if err != nil {
+			log.Fatalf("unable to reach statsd: %v", err)
+		}
+		sinks = append(sinks, &statsdSink{conn, c.Sinks.StatsdPrefix})
+	}
+	var alerts *alerter
+	if *alertWebhook != "" {
+		when, err := parseAlertCondition(*alertWhen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		alerts = &alerter{webhook: *alertWebhook, when: when, cooldown: *alertCooldown,
+			client: &http.Client{Timeout: 10 * time.Second}, targets: map[string]*monitorTarget{}, last: map[string]time.Time{}}
+		for _, t := range c.Targets {
+			alerts.targets[t.Name] = t
+		}
+		sinks = append(sinks, alerts)
+	}
+	if c.Sinks.Prometheus != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			writeMetrics(w, c.Targets)
+		})
+		l, err := net.Listen("tcp", c.Sinks.Prometheus)
+		if err != nil {
+			log.Fatalf("unable to serve Prometheus metrics: %v", err)
+		}
+		go http.Serve(l, mux)
+	}
+
+	names := make([]string, len(c.Targets))
+	for i, t := range c.Targets {
+		names[i] = t.Name
+	}
+	sort.Strings(names)
+	if !*quiet {
+		printf("%s %s\n", labelString("Monitoring %d targets:", len(c.Targets)), valueString(strings.Join(names, ", ")))
+	}
+
+	var mu sync.Mutex
+	for _, t := range c.Targets {
+		go func(t *monitorTarget) {
+			client := t.client()
+			tick := time.NewTicker(t.Interval)
+			for {
+				r := t.probe(client)
+				mu.Lock()
+				for _, s := range sinks {
+					if err := s.write(r); err != nil {
+						log.Printf("warning: unable to write result: %v", err)
+					}
+				}
+				if !*quiet {
+					printMonitorResult(r)
+				}
+				mu.Unlock()
+				<-tick.C
+			}
+		}(t)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	if alerts != nil {
+		alerts.wg.Wait()
+	}
+}
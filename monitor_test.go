@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadMonitorConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(s string) string {
+		f := filepath.Join(dir, "targets.yaml")
+		if err := os.WriteFile(f, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	c, err := loadMonitorConfig(write(`
+interval: 30s
+targets:
+  - name: api
+    url: https://api.example.com/health
+    headers: {Authorization: Bearer x}
+    expect_status: 204
+  - url: http://example.com/
+    interval: 5s
+    timeout: 2s
+sinks:
+  jsonl: results.jsonl
+  statsd: localhost:8125
+`), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, home := c.Targets[0], c.Targets[1]
+	if api.Method != "GET" || api.Interval != 30*time.Second || api.Timeout != 30*time.Second || api.ExpectStatus != 204 || api.Headers["Authorization"] != "Bearer x" {
+		t.Errorf("api target %+v", api)
+	}
+	if home.Name != "http://example.com/" || home.Interval != 5*time.Second || home.Timeout != 2*time.Second || home.size != 10 {
+		t.Errorf("unnamed target %+v", home)
+	}
+	if c.Sinks.JSONL != "results.jsonl" || c.Sinks.Statsd != "localhost:8125" {
+		t.Errorf("sinks %+v", c.Sinks)
+	}
+
+	for _, s := range []string{
+		"targets: []",
+		"targets:\n  - name: nowhere",
+		"targets:\n  - url: http://a/\n  - url: http://a/",
+		"targets:\n  - url: http://a/\n    interval: soon",
+	} {
+		if _, err := loadMonitorConfig(write(s), time.Minute, 10); err == nil {
+			t.Errorf("%q: loaded, want an error", s)
+		}
+	}
+}
+
+func TestMonitorTarget(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Probe") != "1" {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	target := &monitorTarget{Name: "api", URL: ts.URL, Method: "GET", Headers: map[string]string{"X-Probe": "1"}, size: 4}
+	for i, want := range []struct {
+		status       int
+		up           bool
+		availability float64
+	}{
+		{200, true, 100},
+		{503, false, 50},
+		{200, true, 200.0 / 3},
+		{200, true, 75},
+		{200, true, 75}, // the first probe is out of the window
+		{200, true, 100},
+	} {
+		status = want.status
+		r := target.probe(ts.Client())
+		if r.Up != want.up || r.Availability != want.availability || r.Up && r.P95 <= 0 {
+			t.Errorf("probe %d: %+v, want up %v and %.1f%% available", i+1, r, want.up, want.availability)
+		}
+	}
+	if probes, failures, upTime, last := target.snapshot(); probes != 6 || failures != 1 || upTime <= 0 || last.Availability != 100 {
+		t.Errorf("snapshot = %d, %d, %g, %+v", probes, failures, upTime, last)
+	}
+
+	target.ExpectStatus = 418
+	if target.up("200 OK", "") || !target.up("418 I'm a teapot", "") || target.up("", "connection refused") {
+		t.Error("up doesn't keep to the expected status")
+	}
+	target.ExpectStatus = 0
+	if !target.up("399 Custom", "") || target.up("1000 Weird", "") || target.up("OK", "") || target.up("", "") {
+		t.Error("up takes malformed statuses for up")
+	}
+}
+
+func TestMonitorTargetConnects(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	target := &monitorTarget{Name: "api", URL: ts.URL, Method: "GET", Insecure: true, Timeout: 5 * time.Second, size: 4}
+	client := target.client()
+	for i := 1; i <= 3; i++ {
+		if r := target.probe(client); !r.Up || r.micros.TCP <= 0 || r.micros.TLS <= 0 {
+			t.Errorf("probe %d didn't time a connection: %+v", i, r.micros)
+		}
+	}
+}
+
+func TestMonitorSinks(t *testing.T) {
+	target := &monitorTarget{Name: `edge "fra"`, size: 10}
+	r := MonitorResult{Target: target.Name, Up: true, Status: "200 OK", micros: Timing{DNS: 1500, Server: 3000, Total: 4500}}
+	target.add(&r)
+
+	var b bytes.Buffer
+	if err := (&jsonlSink{w: &b}).write(r); err != nil || !strings.HasPrefix(b.String(), `{"Time":"","Target":"edge \"fra\"","URL":"","Up":true`) || !strings.HasSuffix(b.String(), "}\n") {
+		t.Errorf("JSONL line %q, %v", b.String(), err)
+	}
+
+	b.Reset()
+	writeMetrics(&b, []*monitorTarget{target})
+	for _, want := range []string{
+		"# TYPE httpstat_up gauge\nhttpstat_up{target=\"edge \\\"fra\\\"\"} 1\n",
+		`httpstat_probe_duration_seconds{target="edge \"fra\"",phase="dns"} 0.0015`,
+		"# TYPE httpstat_latency_seconds summary\n",
+		`httpstat_latency_seconds{target="edge \"fra\"",quantile="0.95"} 0.0045`,
+		`httpstat_latency_seconds_sum{target="edge \"fra\""} 0.0045`,
+		`httpstat_latency_seconds_count{target="edge \"fra\""} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, b.String())
+		}
+	}
+
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&statsdSink{conn, "httpstat"}).write(r); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := l.ReadFrom(buf)
+	if got := string(buf[:n]); err != nil || !strings.Contains(got, "httpstat.edge_fra.probes:1|c\nhttpstat.edge_fra.up:1|g\nhttpstat.edge_fra.dns:1.5|ms\n") {
+		t.Errorf("statsd got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formPart is one -F field of a multipart/form-data body.
+type formPart struct {
+	name        string
+	value       string // the value, or the file named by -F name=@file or name=<file
+	file        bool   // upload the file value as an attachment
+	contentFile bool   // use the contents of the file value as the value
+	contentType string
+	filename    string
+}
+
+// formFields are the -F fields, given curl style as name=value,
+// name=@file;type=TYPE;filename=NAME to upload a file or name=<file to
+// send a file's contents as the value.
+type formFields []formPart
+
+func (f formFields) String() string {
+	var o []string
+	for _, p := range f {
+		o = append(o, p.name)
+	}
+	return strings.Join(o, ",")
+}
+
+func (f *formFields) Set(v string) error {
+	p, err := parseFormField(v)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+func parseFormField(s string) (formPart, error) {
+	name, v, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return formPart{}, fmt.Errorf("form field %q must be of the form name=value", s)
+	}
+	p := formPart{name: name, value: v}
+	if !strings.HasPrefix(v, "@") && !strings.HasPrefix(v, "<") {
+		return p, nil
+	}
+
+	opts := strings.Split(v[1:], ";")
+	p.value, p.file, p.contentFile = opts[0], v[0] == '@', v[0] == '<'
+	if p.value == "" {
+		return formPart{}, fmt.Errorf("form field %q has no file name", s)
+	}
+	if p.file {
+		p.filename = filepath.Base(p.value)
+	}
+	for _, o := range opts[1:] {
+		k, val, _ := strings.Cut(o, "=")
+		switch strings.TrimSpace(k) {
+		case "type":
+			p.contentType = val
+		case "filename":
+			p.filename = val
+		default:
+			return formPart{}, fmt.Errorf("form field %q has unknown option %q", s, o)
+		}
+	}
+	return p, nil
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// open returns the multipart/form-data body of the fields using the
+// given boundary, and its length or -1 if that isn't known. Files are
+// streamed from disk rather than read into memory.
+func (f formFields) open(boundary string) (io.ReadCloser, int64, error) {
+	body := &formBody{}
+	var n int64
+	sized := true
+
+	// the multipart writer only writes the boundaries and part headers,
+	// the contents go between them.
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, 0, err
+	}
+	flush := func() {
+		b := append([]byte(nil), buf.Bytes()...)
+		body.parts = append(body.parts, bytes.NewReader(b))
+		n += int64(len(b))
+		buf.Reset()
+	}
+
+	for _, p := range f {
+		h := make(textproto.MIMEHeader)
+		disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.name))
+
+		if !p.file {
+			value := p.value
+			if p.contentFile {
+				b, err := ioutil.ReadFile(p.value)
+				if err != nil {
+					body.Close()
+					return nil, 0, fmt.Errorf("unable to read form field %s: %v", p.name, err)
+				}
+				value = string(b)
+			}
+			h.Set("Content-Disposition", disposition)
+			if p.contentType != "" {
+				h.Set("Content-Type", p.contentType)
+			}
+			mw.CreatePart(h)
+			buf.WriteString(value)
+			continue
+		}
+
+		file, err := os.Open(p.value)
+		if err != nil {
+			body.Close()
+			return nil, 0, fmt.Errorf("unable to open form file: %v", err)
+		}
+		body.files = append(body.files, file)
+		if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+			n += fi.Size()
+		} else {
+			sized = false
+		}
+
+		contentType := p.contentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(p.value))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h.Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, disposition, quoteEscaper.Replace(p.filename)))
+		h.Set("Content-Type", contentType)
+		mw.CreatePart(h)
+		flush()
+		body.parts = append(body.parts, file)
+	}
+	mw.Close()
+	flush()
+	if !sized {
+		n = -1
+	}
+	body.Reader = io.MultiReader(body.parts...)
+	return body, n, nil
+}
+
+// formBody reads the parts of a multipart body in turn, closing the
+// files among them when done.
+type formBody struct {
+	io.Reader
+	parts []io.Reader
+	files []*os.File
+}
+
+func (b *formBody) Close() error {
+	for _, f := range b.files {
+		f.Close()
+	}
+	return nil
+}
+
+// setFormBody makes the fields the multipart/form-data body of req.
+func setFormBody(req *http.Request, fields formFields) error {
+	boundary := multipart.NewWriter(nil).Boundary()
+	body, n, err := fields.open(boundary)
+	if err != nil {
+		return err
+	}
+	req.Body, req.ContentLength = body, n
+	if showProgress() {
+		req.Body = newProgressReader(body, "Uploading", n)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, _, err := fields.open(boundary)
+		return body, err
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFormField(t *testing.T) {
+	tests := []struct {
+		in   string
+		want formPart
+	}{
+		{"a=1", formPart{name: "a", value: "1"}},
+		{"a=x;y", formPart{name: "a", value: "x;y"}},
+		{"f=@dir/pic.png", formPart{name: "f", value: "dir/pic.png", file: true, filename: "pic.png"}},
+		{"f=@pic.png;type=image/png;filename=p.png", formPart{name: "f", value: "pic.png", file: true, contentType: "image/png", filename: "p.png"}},
+		{"v=<notes.txt", formPart{name: "v", value: "notes.txt", contentFile: true}},
+	}
+	for _, tt := range tests {
+		got, err := parseFormField(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseFormField(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"novalue", "=x", "f=@", "f=@x;size=1"} {
+		if _, err := parseFormField(in); err == nil {
+			t.Errorf("parseFormField(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestFormFieldsOpen(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(file, []byte(`{"a":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var fields formFields
+	for _, v := range []string{"name=value", "upload=@" + file} {
+		if err := fields.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	body, n, err := fields.open("boundary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, _ := ioutil.ReadAll(body)
+	if int64(len(b)) != n {
+		t.Errorf("length is %d, but read %d bytes", n, len(b))
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(b), "boundary").ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := form.Value["name"]; len(v) != 1 || v[0] != "value" {
+		t.Errorf("name = %q, want value", v)
+	}
+	fh := form.File["upload"]
+	if len(fh) != 1 || fh[0].Filename != "data.json" || fh[0].Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("upload = %+v", fh)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netrcPath returns the default netrc file, $NETRC or ~/.netrc.
+func netrcPath() string {
+	if f := os.Getenv("NETRC"); f != "" {
+		return f
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// lookupNetrc returns the login and password for host from the netrc
+// file in r, falling back to the default entry if there is one.
+func lookupNetrc(r io.Reader, host string) (login, password string, ok bool) {
+	// split the file into tokens, dropping macro definitions which run
+	// until the next blank line.
+	var tokens []string
+	sc := bufio.NewScanner(r)
+	inMacro := false
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if inMacro {
+			inMacro = len(fields) > 0
+			continue
+		}
+		for i, f := range fields {
+			if f == "macdef" {
+				inMacro = true
+				fields = fields[:i]
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	type entry struct {
+		machine         string
+		login, password string
+	}
+	var entries []*entry
+	for i := 0; i < len(tokens); i++ {
+		value := ""
+		if i+1 < len(tokens) {
+			value = tokens[i+1]
+		}
+		switch tokens[i] {
+		case "machine":
+			entries = append(entries, &entry{machine: value})
+			i++
+		case "default":
+			entries = append(entries, &entry{})
+		case "login", "password", "account":
+			if len(entries) > 0 {
+				e := entries[len(entries)-1]
+				switch tokens[i] {
+				case "login":
+					e.login = value
+				case "password":
+					e.password = value
+				}
+			}
+			i++
+		}
+	}
+
+	var def *entry
+	for _, e := range entries {
+		if e.machine == "" {
+			if def == nil {
+				def = e
+			}
+			continue
+		}
+		if strings.EqualFold(e.machine, host) {
+			return e.login, e.password, true
+		}
+	}
+	if def != nil {
+		return def.login, def.password, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testNetrc = `machine example.com
+  login alice
+  password s3cret
+
+macdef init
+machine evil.example.com login mallory password nope
+
+machine api.example.com login bob password hunter2 account ops
+default login anonymous password guest@
+`
+
+func TestLookupNetrc(t *testing.T) {
+	tests := []struct {
+		host            string
+		login, password string
+		ok              bool
+	}{
+		{"example.com", "alice", "s3cret", true},
+		{"API.example.com", "bob", "hunter2", true},
+		{"evil.example.com", "anonymous", "guest@", true},
+		{"other.org", "anonymous", "guest@", true},
+	}
+
+	for _, test := range tests {
+		login, password, ok := lookupNetrc(strings.NewReader(testNetrc), test.host)
+		if login != test.login || password != test.password || ok != test.ok {
+			t.Errorf("%s: want %q %q %v, got %q %q %v", test.host,
+				test.login, test.password, test.ok, login, password, ok)
+		}
+	}
+
+	if _, _, ok := lookupNetrc(strings.NewReader("machine a login b password c\n"), "d"); ok {
+		t.Error("want no match without a default entry")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM message flags, see [MS-NLMP] section 2.2.2.5.
+const (
+	ntlmNegotiateUnicode         = 0x00000001
+	ntlmRequestTarget            = 0x00000004
+	ntlmNegotiateNTLM            = 0x00000200
+	ntlmNegotiateAlwaysSign      = 0x00008000
+	ntlmNegotiateExtendedSession = 0x00080000
+	ntlmNegotiateTargetInfo      = 0x00800000
+	ntlmNegotiate128             = 0x20000000
+	ntlmNegotiate56              = 0x80000000
+
+	ntlmDefaultFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSession | ntlmNegotiateTargetInfo |
+		ntlmNegotiate128 | ntlmNegotiate56
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE which opens an
+// NTLM handshake.
+func ntlmNegotiateMessage() []byte {
+	b := make([]byte, 32)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 1)
+	binary.LittleEndian.PutUint32(b[12:], ntlmDefaultFlags)
+	// empty domain and workstation fields.
+	binary.LittleEndian.PutUint32(b[20:], 32)
+	binary.LittleEndian.PutUint32(b[28:], 32)
+	return b
+}
+
+// ntlmChallenge is the content of a CHALLENGE_MESSAGE we need to answer it.
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+func parseNTLMChallenge(b []byte) (*ntlmChallenge, error) {
+	if len(b) < 48 || !bytes.Equal(b[:8], ntlmSignature) || binary.LittleEndian.Uint32(b[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge message")
+	}
+	c := &ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(b[20:]),
+		serverChallenge: b[24:32],
+	}
+	l, off := int(binary.LittleEndian.Uint16(b[40:])), int(binary.LittleEndian.Uint32(b[44:]))
+	if off+l > len(b) {
+		return nil, errors.New("invalid NTLM target info")
+	}
+	c.targetInfo = b[off : off+l]
+	return c, nil
+}
+
+// timestamp returns the MsvAvTimestamp from the challenge's target info.
+func (c *ntlmChallenge) timestamp() ([]byte, bool) {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id, l := binary.LittleEndian.Uint16(info), int(binary.LittleEndian.Uint16(info[2:]))
+		if id == ntlmAvEOL || len(info) < 4+l {
+			break
+		}
+		if id == ntlmAvTimestamp && l == 8 {
+			return info[4:12], true
+		}
+		info = info[4+l:]
+	}
+	return nil, false
+}
+
+// ntlmAuthenticateMessage answers the challenge with an NTLMv2
+// AUTHENTICATE_MESSAGE. user may be given as DOMAIN\user.
+func ntlmAuthenticateMessage(c *ntlmChallenge, user, password string, clientChallenge []byte, now time.Time) []byte {
+	var domain string
+	if i := strings.IndexByte(user, '\\'); i != -1 {
+		domain, user = user[:i], user[i+1:]
+	}
+
+	h := md4.New()
+	h.Write(utf16le(password))
+	ntowf := hmacMD5(h.Sum(nil), utf16le(strings.ToUpper(user)+domain))
+
+	ts, serverTimestamp := c.timestamp()
+	if !serverTimestamp {
+		ts = make([]byte, 8)
+		// FILETIME, 100ns intervals since 1601.
+		binary.LittleEndian.PutUint64(ts, uint64(now.Unix()*1e7+int64(now.Nanosecond()/100)+116444736000000000))
+	}
+
+	var temp bytes.Buffer
+	temp.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.Write(ts)
+	temp.Write(clientChallenge)
+	temp.Write([]byte{0, 0, 0, 0})
+	temp.Write(c.targetInfo)
+	temp.Write([]byte{0, 0, 0, 0})
+
+	ntProof := hmacMD5(ntowf, append(append([]byte{}, c.serverChallenge...), temp.Bytes()...))
+	ntResponse := append(ntProof, temp.Bytes()...)
+
+	// when the server supplies a timestamp the LMv2 response must be zeroed.
+	lmResponse := make([]byte, 24)
+	if !serverTimestamp {
+		lmResponse = append(hmacMD5(ntowf, append(append([]byte{}, c.serverChallenge...), clientChallenge...)), clientChallenge...)
+	}
+
+	payload := [][]byte{lmResponse, ntResponse, utf16le(domain), utf16le(user), nil, nil}
+	const headerLen = 64
+	b := make([]byte, headerLen)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 3)
+	off := headerLen
+	for i, p := range payload {
+		field := b[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(p)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(p)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(off))
+		off += len(p)
+	}
+	binary.LittleEndian.PutUint32(b[60:], c.flags&ntlmDefaultFlags)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func hmacMD5(key, data []byte) []byte {
+	m := hmac.New(md5.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, r := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
+
+// ntlmToken extracts the base64 token following the named scheme in the
+// WWW-Authenticate headers, reporting whether the scheme was offered.
+func ntlmToken(values []string, scheme string) ([]byte, bool) {
+	for _, v := range values {
+		f := strings.Fields(v)
+		if len(f) == 0 || !strings.EqualFold(f[0], scheme) {
+			continue
+		}
+		if len(f) == 1 {
+			return nil, true
+		}
+		tok, err := base64.StdEncoding.DecodeString(f[1])
+		if err != nil {
+			return nil, true
+		}
+		return tok, true
+	}
+	return nil, false
+}
+
+func newClientChallenge() []byte {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return b
+}
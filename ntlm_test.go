@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestNTLMAuthenticateMessage(t *testing.T) {
+	// NTLMv2 example from [MS-NLMP] section 4.2.4.
+	targetInfo, _ := hex.DecodeString("02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000")
+	challenge := &ntlmChallenge{
+		flags:           ntlmDefaultFlags,
+		serverChallenge: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		targetInfo:      targetInfo,
+	}
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	epoch := time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	msg := ntlmAuthenticateMessage(challenge, `Domain\User`, "Password", clientChallenge, epoch)
+
+	field := func(i int) []byte {
+		l := binary.LittleEndian.Uint16(msg[12+8*i:])
+		off := binary.LittleEndian.Uint32(msg[16+8*i:])
+		return msg[off : off+uint32(l)]
+	}
+	if want := "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"; hex.EncodeToString(field(0)) != want {
+		t.Errorf("LMv2 response: want %s, got %x", want, field(0))
+	}
+	if want := "68cd0ab851e51c96aabc927bebef6a1c"; hex.EncodeToString(field(1)[:16]) != want {
+		t.Errorf("NTProofStr: want %s, got %x", want, field(1)[:16])
+	}
+	if got := field(3); !bytes.Equal(got, utf16le("User")) {
+		t.Errorf("user name: got %x", got)
+	}
+}
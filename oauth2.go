@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tokenFetchTime is how long acquiring the -oauth2-token-url access
+// token took, reported alongside the first request.
+var tokenFetchTime time.Duration
+
+// fetchOAuth2Token obtains an access token from tokenURL with the OAuth 2.0
+// client credentials grant, RFC 6749 section 4.4, authenticating the
+// client with HTTP Basic auth.
+func fetchOAuth2Token(tokenURL, clientID, clientSecret, scope string) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	// a transport of its own, so the first request doesn't reuse the
+	// token's connection if both are to the same host.
+	tr := newTransport(req.URL, req)
+	defer tr.CloseIdleConnections()
+	if tr.TLSClientConfig != nil {
+		// the -sni and -pin are the target's.
+		tr.TLSClientConfig.ServerName = req.URL.Hostname()
+		tr.TLSClientConfig.VerifyConnection = nil
+	}
+	client := &http.Client{Transport: tr, Timeout: maxTime}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("%s: unexpected token response: %v", resp.Status, err)
+	}
+	if token.Error != "" {
+		if token.ErrorDescription != "" {
+			return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+		}
+		return "", fmt.Errorf("%s", token.Error)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("%s: no access token in response", resp.Status)
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", fmt.Errorf("unsupported token type %q", token.TokenType)
+	}
+	return token.AccessToken, nil
+}
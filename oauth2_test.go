@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchOAuth2Token(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if len(r.TLS.PeerCertificates) == 0 || id != "client" || secret != "s3cret" || r.FormValue("scope") != "read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "tok", "token_type": "Bearer"}`))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(ca0, cert, key string, p pins) {
+		cacert, clientCertFile, clientKeyFile, publicKeyPins = ca0, cert, key, p
+	}(cacert, clientCertFile, clientKeyFile, publicKeyPins)
+	cacert, clientCertFile, clientKeyFile = ca, "./test/clientcert.pem", "./test/clientkey.pem"
+	// the target's pin, which the token endpoint doesn't match.
+	publicKeyPins = nil
+	if err := publicKeyPins.Set("sha256//AAAA"); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := fetchOAuth2Token(ts.URL, "client", "s3cret", "read")
+	if err != nil || tok != "tok" {
+		t.Errorf("fetchOAuth2Token = %q, %v, want tok", tok, err)
+	}
+	if _, err := fetchOAuth2Token(ts.URL, "client", "wrong", "read"); err == nil || err.Error() != "invalid_client" {
+		t.Errorf("with the wrong secret: %v, want invalid_client", err)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the part of an OpenAPI 3 or Swagger 2 document needed
+// to call its GET operations. JSON documents are read as YAML.
+type openAPISpec struct {
+	Servers []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	Paths      map[string]openAPIPath `yaml:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `yaml:"parameters"`
+	} `yaml:"components"`
+
+	// Swagger 2
+	Host       string                      `yaml:"host"`
+	BasePath   string                      `yaml:"basePath"`
+	Schemes    []string                    `yaml:"schemes"`
+	Parameters map[string]openAPIParameter `yaml:"parameters"`
+}
+
+type openAPIPath struct {
+	Parameters []openAPIParameter `yaml:"parameters"`
+	Get        *struct {
+		OperationID string                 `yaml:"operationId"`
+		Parameters  []openAPIParameter     `yaml:"parameters"`
+		Responses   map[string]interface{} `yaml:"responses"`
+	} `yaml:"get"`
+}
+
+type openAPIParameter struct {
+	Ref      string      `yaml:"$ref"`
+	Name     string      `yaml:"name"`
+	In       string      `yaml:"in"`
+	Required bool        `yaml:"required"`
+	Example  interface{} `yaml:"example"`
+	Examples map[string]struct {
+		Value interface{} `yaml:"value"`
+	} `yaml:"examples"`
+	Schema *openAPISchema `yaml:"schema"`
+
+	// Swagger 2 puts the schema of simple parameters inline.
+	openAPISchema `yaml:",inline"`
+}
+
+type openAPISchema struct {
+	Type    string        `yaml:"type"`
+	Format  string        `yaml:"format"`
+	Default interface{}   `yaml:"default"`
+	Enum    []interface{} `yaml:"enum"`
+	Example interface{}   `yaml:"x-example"`
+}
+
+// openAPIOperation is a GET operation to call.
+type openAPIOperation struct {
+	ID       string
+	Path     string
+	URL      string
+	Expected []string // the documented response codes, such as 200 or 2XX
+	Error    string   // why the operation can't be called
+}
+
+// loadOpenAPISpec reads an OpenAPI document, in YAML or JSON, from a
+// file or URL.
+func loadOpenAPISpec(name string) (*openAPISpec, error) {
+	var b []byte
+	var err error
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		b, err = fetch(crawlClient(1), name)
+	} else {
+		b, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var spec openAPISpec
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(spec.Paths) == 0 {
+		return nil, fmt.Errorf("%s: no paths", name)
+	}
+	return &spec, nil
+}
+
+// baseURL returns the URL of the first server of the spec, resolved
+// against the URL the spec was read from.
+func (s *openAPISpec) baseURL(specURL string) string {
+	if len(s.Servers) > 0 {
+		u := s.Servers[0].URL
+		for name, v := range s.Servers[0].Variables {
+			u = strings.ReplaceAll(u, "{"+name+"}", v.Default)
+		}
+		if ref, err := url.Parse(specURL); err == nil && ref.IsAbs() {
+			if r, err := ref.Parse(u); err == nil {
+				return r.String()
+			}
+		}
+		return u
+	}
+	if s.Host != "" {
+		scheme := "https"
+		if len(s.Schemes) > 0 {
+			scheme = s.Schemes[0]
+		}
+		return scheme + "://" + s.Host + s.BasePath
+	}
+	return ""
+}
+
+// resolve returns the parameter p refers to, if it is a reference.
+func (s *openAPISpec) resolve(p openAPIParameter) (openAPIParameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	for _, prefix := range []string{"#/components/parameters/", "#/parameters/"} {
+		if name := strings.TrimPrefix(p.Ref, prefix); name != p.Ref {
+			if q, ok := s.Components.Parameters[name]; ok {
+				return q, nil
+			}
+			if q, ok := s.Parameters[name]; ok {
+				return q, nil
+			}
+		}
+	}
+	return p, fmt.Errorf("unresolved parameter %s", p.Ref)
+}
+
+// exampleValue returns a value to give p: its example, else one made
+// up from its schema. ok is false if an optional parameter has no
+// example, so is best left out.
+func (p openAPIParameter) exampleValue() (v string, ok bool) {
+	if p.Example != nil {
+		return fmt.Sprint(p.Example), true
+	}
+	names := make([]string, 0, len(p.Examples))
+	for name := range p.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		return fmt.Sprint(p.Examples[names[0]].Value), true
+	}
+
+	schema := p.openAPISchema
+	if p.Schema != nil {
+		schema = *p.Schema
+	}
+	switch {
+	case schema.Example != nil:
+		return fmt.Sprint(schema.Example), true
+	case schema.Default != nil:
+		return fmt.Sprint(schema.Default), true
+	case len(schema.Enum) > 0:
+		return fmt.Sprint(schema.Enum[0]), true
+	case !p.Required && p.In != "path":
+		return "", false
+	}
+	switch schema.Type {
+	case "integer", "number":
+		return "1", true
+	case "boolean":
+		return "true", true
+	}
+	switch schema.Format {
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000", true
+	case "date":
+		return "2024-01-01", true
+	case "date-time":
+		return "2024-01-01T00:00:00Z", true
+	}
+	return "example", true
+}
+
+// operations returns the GET operations of the spec in path order,
+// with their URLs under base.
+func (s *openAPISpec) operations(base string) []openAPIOperation {
+	paths := make([]string, 0, len(s.Paths))
+	for p := range s.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var ops []openAPIOperation
+	for _, path := range paths {
+		item := s.Paths[path]
+		if item.Get == nil {
+			continue
+		}
+		op := openAPIOperation{ID: item.Get.OperationID, Path: path}
+		for code := range item.Get.Responses {
+			op.Expected = append(op.Expected, strings.ToUpper(code))
+		}
+		sort.Strings(op.Expected)
+
+		// operation parameters override those of the path.
+		params := map[string]openAPIParameter{}
+		var order []string
+		for _, p := range append(append([]openAPIParameter(nil), item.Parameters...), item.Get.Parameters...) {
+			p, err := s.resolve(p)
+			if err != nil {
+				op.Error = err.Error()
+				break
+			}
+			key := p.In + " " + p.Name
+			if _, ok := params[key]; !ok {
+				order = append(order, key)
+			}
+			params[key] = p
+		}
+
+		target := path
+		query := url.Values{}
+		for _, key := range order {
+			p := params[key]
+			v, ok := p.exampleValue()
+			if !ok {
+				continue
+			}
+			switch p.In {
+			case "path":
+				target = strings.ReplaceAll(target, "{"+p.Name+"}", url.PathEscape(v))
+			case "query":
+				query.Set(p.Name, v)
+			}
+		}
+		op.URL = strings.TrimSuffix(base, "/") + target
+		if len(query) > 0 {
+			op.URL += "?" + query.Encode()
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// expected reports whether status is one of the documented responses.
+func (op *openAPIOperation) expected(status string) bool {
+	code := statusCode(status)
+	for _, e := range op.Expected {
+		if e == code || e == "DEFAULT" || len(e) == 3 && strings.HasSuffix(e, "XX") && e[0] == code[0] {
+			return true
+		}
+	}
+	return len(op.Expected) == 0
+}
+
+// httpHeader returns the -H headers of a subcommand as an http.Header.
+func httpHeader(hdrs headers) (http.Header, error) {
+	header := http.Header{}
+	for _, h := range hdrs {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", h)
+		}
+		header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return header, nil
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	http.RoundTripper
+	header http.Header
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// runOpenAPI is the openapi subcommand, which times a request to each
+// GET operation of an API.
+func runOpenAPI(args []string) {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s openapi [OPTIONS] SPEC\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Time a request to each GET operation of an OpenAPI or Swagger spec, with example parameters,")
+		fmt.Fprintln(os.Stderr, "and report the responses with a status the spec doesn't document.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		fs.PrintDefaults()
+	}
+	base := fs.String("base-url", "", "URL the API is served from; the spec's first server unless given")
+	var hdrs headers
+	fs.Var(&hdrs, "H", "set HTTP header, such as for authentication; repeatable")
+	fs.BoolVar(&insecure, "k", false, "allow insecure SSL connections")
+	fs.DurationVar(&maxTime, "m", 0, "maximum time each request may take")
+	delay := fs.Duration("w", 0, "wait this long between requests")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	color.NoColor = !colorTerminal(os.Stdout)
+
+	spec, err := loadOpenAPISpec(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("unable to read OpenAPI spec: %v", err)
+	}
+	if *base == "" {
+		*base = spec.baseURL(fs.Arg(0))
+	}
+	if *base == "" {
+		log.Fatal("the spec names no server, give one with -base-url")
+	}
+	if _, err := url.Parse(*base); err != nil {
+		log.Fatalf("invalid base URL: %v", err)
+	}
+	header, err := httpHeader(hdrs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := crawlClient(1)
+	client.Transport = headerTransport{client.Transport, header}
+	ops := spec.operations(*base)
+	if len(ops) == 0 {
+		printf("%s\n", warnString("No GET operations in the spec."))
+		return
+	}
+
+	var totals []float64
+	unexpected := 0
+	for i := range ops {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+		op := &ops[i]
+		name := op.ID
+		if name == "" {
+			name = op.Path
+		}
+		if op.Error != "" {
+			unexpected++
+			printf("%s %s %s\n", errorString("%-3s", "ERR"), valueString("%-30s", name), errorString(op.Error))
+			continue
+		}
+		r := Resource{URL: op.URL, Type: "operation"}
+		fetchResource(client, &r, nil, time.Now())
+		switch {
+		case r.Error != "":
+			unexpected++
+			printf("%s %s %s\n", errorString("%-3s", "ERR"), valueString("%-30s", name), errorString(r.Error))
+		case !op.expected(r.Status):
+			unexpected++
+			printf("%s %s %s %s\n", errorString("%-3s", statusCode(r.Status)), valueString("%-30s", name),
+				valueString("%8s", formatTiming(r.micros.Total)),
+				errorString("not documented, expected %s", strings.Join(op.Expected, ", ")))
+		default:
+			totals = append(totals, float64(r.micros.Total)/1000)
+			printf("%s %s %s %s\n", statusString("%-3s", statusCode(r.Status)), valueString("%-30s", name),
+				valueString("%8s", formatTiming(r.micros.Total)), labelString(op.URL))
+		}
+	}
+
+	printf("\n%s", labelString("Called %d operations", len(ops)))
+	if unexpected > 0 {
+		exitStatus = 1
+		printf("%s %s\n", labelString(","), errorString("%d failed or unexpected", unexpected))
+	} else {
+		printf("%s\n", labelString(", all as documented"))
+	}
+	if len(totals) > 0 {
+		printStats("Total:", newStats(totals))
+	}
+	os.Exit(exitStatus)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testOpenAPISpec = `openapi: 3.0.3
+info: {title: Pets, version: "1.0"}
+servers:
+  - url: https://{region}.example.com/v1
+    variables:
+      region: {default: eu}
+components:
+  parameters:
+    Limit:
+      name: limit
+      in: query
+      schema: {type: integer, default: 20}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - $ref: '#/components/parameters/Limit'
+        - {name: tag, in: query, schema: {type: string}}
+        - {name: since, in: query, required: true, schema: {type: string, format: date}}
+      responses:
+        200: {description: ok}
+        default: {description: error}
+    post:
+      responses:
+        201: {description: created}
+  /pets/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, schema: {type: string}, example: a b}
+    get:
+      operationId: showPet
+      parameters:
+        - name: petId
+          in: path
+          required: true
+          examples:
+            cat: {value: 7}
+      responses:
+        2xx: {description: ok}
+  /broken:
+    get:
+      parameters:
+        - $ref: '#/components/parameters/Missing'
+      responses: {}
+`
+
+func TestOpenAPIOperations(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(name, []byte(testOpenAPISpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadOpenAPISpec(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := spec.baseURL(name)
+	if base != "https://eu.example.com/v1" {
+		t.Errorf("got base URL %s", base)
+	}
+
+	want := []openAPIOperation{
+		{Path: "/broken", URL: base + "/broken", Error: "unresolved parameter #/components/parameters/Missing"},
+		{ID: "listPets", Path: "/pets", URL: base + "/pets?limit=20&since=2024-01-01", Expected: []string{"200", "DEFAULT"}},
+		{ID: "showPet", Path: "/pets/{petId}", URL: base + "/pets/7", Expected: []string{"2XX"}},
+	}
+	if got := spec.operations(base); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSwaggerOperations(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "swagger.json")
+	doc := `{"swagger": "2.0", "host": "api.example.com", "basePath": "/v2", "schemes": ["http"],
+"paths": {"/users/{id}": {"get": {"parameters": [
+  {"name": "id", "in": "path", "required": true, "type": "integer"},
+  {"name": "fields", "in": "query", "type": "string", "enum": ["name", "email"]}
+], "responses": {"200": {"description": "ok"}}}}}}`
+	if err := os.WriteFile(name, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadOpenAPISpec(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := spec.operations(spec.baseURL(name))
+	if len(ops) != 1 || ops[0].URL != "http://api.example.com/v2/users/1?fields=name" {
+		t.Errorf("got %+v", ops)
+	}
+}
+
+func TestOpenAPIExpected(t *testing.T) {
+	op := openAPIOperation{Expected: []string{"200", "4XX"}}
+	for status, want := range map[string]bool{
+		"200 OK":                    true,
+		"404 Not Found":             true,
+		"201 Created":               false,
+		"500 Internal Server Error": false,
+	} {
+		if got := op.expected(status); got != want {
+			t.Errorf("expected(%q) = %v, want %v", status, got, want)
+		}
+	}
+	if op := (openAPIOperation{Expected: []string{"DEFAULT"}}); !op.expected("503 Service Unavailable") {
+		t.Error("default response not expected")
+	}
+}
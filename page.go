@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// pageMax is how much of an HTML document is parsed for subresources
+// with -page.
+const pageMax = 4 << 20
+
+// PageLoad is the loading of the subresources of an HTML document with
+// -page.
+type PageLoad struct {
+	Resources []Resource
+	// Load is the milliseconds from sending the request for the
+	// document to the end of the last resource.
+	Load  int
+	Bytes int64 // of the document and every resource
+
+	load int // in microseconds
+}
+
+// Resource is a stylesheet, script or image of a page.
+type Resource struct {
+	URL    string
+	Type   string
+	Status string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+	Size   int64  // the bytes of the body
+	Start  int    // milliseconds from sending the request for the document
+	Timing Timing
+
+	start  int    // microseconds from sending the request for the document
+	micros Timing // the timing in microseconds
+}
+
+// isHTML reports whether contentType is an HTML document.
+func isHTML(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// pageResources returns the stylesheets, scripts and images an HTML
+// document at base refers to, in document order and each only once.
+// With sameOrigin, those on other origins are left out.
+func pageResources(doc []byte, base *url.URL, sameOrigin bool) []Resource {
+	var resources []Resource
+	seen := map[string]bool{}
+	add := func(ref, typ string) {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		if sameOrigin && (u.Scheme != base.Scheme || u.Host != base.Host) {
+			return
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			resources = append(resources, Resource{URL: s, Type: typ})
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return resources
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		attrs := map[string]string{}
+		for hasAttr {
+			var k, v []byte
+			k, v, hasAttr = z.TagAttr()
+			attrs[string(k)] = string(v)
+		}
+		switch string(name) {
+		case "base":
+			if u, err := base.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+				base = u
+			}
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if rel == "stylesheet" && attrs["href"] != "" {
+					add(attrs["href"], "stylesheet")
+				}
+			}
+		case "script":
+			if attrs["src"] != "" {
+				add(attrs["src"], "script")
+			}
+		case "img":
+			if attrs["src"] != "" {
+				add(attrs["src"], "image")
+			}
+		}
+	}
+}
+
+// loadPage fetches the subresources of the HTML document of report,
+// concurrency at a time, as a browser would load the page.
+func loadPage(client *http.Client, u *url.URL, userAgent string, report *Report, concurrency int, sameOrigin bool) *PageLoad {
+	p := &PageLoad{Resources: pageResources(report.document, u, sameOrigin)}
+
+	// the transport names the page's host in the TLS handshake, which
+	// resources on other hosts need their own name in.
+	other := client
+	if tr, ok := client.Transport.(*http.Transport); ok && tr.TLSClientConfig != nil && tr.TLSClientConfig.ServerName != "" {
+		tr = tr.Clone()
+		tr.TLSClientConfig.ServerName = ""
+		tr.TLSNextProto, tr.ForceAttemptHTTP2 = nil, true
+		c := *client
+		c.Transport = tr
+		other = &c
+	}
+
+	header := http.Header{"Referer": {u.String()}}
+	if userAgent != "" {
+		header.Set("User-Agent", userAgent)
+	}
+	sem := make(chan bool, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range p.Resources {
+		wg.Add(1)
+		sem <- true
+		c := client
+		if ru, err := url.Parse(p.Resources[i].URL); err == nil && ru.Host != u.Host {
+			c = other
+		}
+		go func(r *Resource) {
+			defer func() { <-sem; wg.Done() }()
+			fetchResource(c, r, header, report.start)
+		}(&p.Resources[i])
+	}
+	wg.Wait()
+
+	end := report.micros.Total
+	p.Bytes = report.Size.BodyBytes
+	for _, r := range p.Resources {
+		end = max(end, r.start+r.micros.Total)
+		p.Bytes += r.Size
+	}
+	p.load, p.Load = end, end/1000
+	return p
+}
+
+// fetchResource times getting r with the given headers, from origin.
+func fetchResource(client *http.Client, r *Resource, header http.Header, origin time.Time) {
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	timeResource(client, r, req, origin)
+}
+
+// timeResource times making req for r, from origin.
+func timeResource(client *http.Client, r *Resource, req *http.Request, origin time.Time) {
+	var tDNSStart, tConnectStart, tTLSStart, tConnected, tFirstByte time.Time
+
+	// a dial the transport started for req goes on in the background if
+	// another request's connection turns up first; its hooks mustn't
+	// touch r once req has a connection.
+	var mu sync.Mutex
+	var gotConn bool
+	dialing := func(f func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !gotConn {
+			f()
+		}
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dialing(func() { tDNSStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { dialing(func() { r.micros.DNS = usSince(tDNSStart) }) },
+		ConnectStart: func(_, _ string) {
+			dialing(func() {
+				if tConnectStart.IsZero() {
+					tConnectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			dialing(func() {
+				if err == nil {
+					r.micros.TCP = usSince(tConnectStart)
+				}
+			})
+		},
+		TLSHandshakeStart: func() { dialing(func() { tTLSStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			dialing(func() { r.micros.TLS = usSince(tTLSStart) })
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			gotConn = true
+			tConnected = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			tFirstByte = time.Now()
+			r.micros.Server = usSince(tConnected)
+		},
+	}
+
+	start := time.Now()
+	r.start = int(start.Sub(origin) / time.Microsecond)
+	defer func() {
+		r.micros.Total = usSince(start)
+		r.micros.Lookup = r.micros.DNS
+		r.micros.Connect = r.micros.Lookup + r.micros.TCP
+		r.micros.PreTransfer = r.micros.Connect + r.micros.TLS
+		r.micros.StartTransfer = r.micros.PreTransfer + r.micros.Server
+		r.Start, r.Timing = r.start/1000, r.micros.millis()
+	}()
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := client.Do(req)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	r.Status = resp.Status
+	r.Size, err = io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if !tFirstByte.IsZero() {
+		r.micros.Transfer = usSince(tFirstByte)
+	}
+}
+
+// printPageLoad draws the document and its resources on a common time
+// axis, as the network panel of a browser does.
+func printPageLoad(u *url.URL, report *Report, p *PageLoad, width int) {
+	label := labelString
+	if width <= 0 {
+		width = 100
+	}
+	const nameWidth = 28
+	barWidth := max(width-nameWidth-26, 10)
+	end := max(p.load, 1)
+	col := func(us int) int { return min(us*barWidth/end, barWidth-1) }
+
+	printf("\n%s %s\n", label("Page load:"), label("%s connecting, %s waiting, %s receiving",
+		glyph("░", "-"), glyph("▒", "="), glyph("█", "#")))
+	row := func(name, status string, failed bool, size int64, start int, t Timing) {
+		if len(name) > nameWidth {
+			name = glyph("…", "~") + name[len(name)-nameWidth+1:]
+		}
+		setup := t.DNS + t.TCP + t.TLS
+		a := col(start)
+		b := max(col(start+setup), a)
+		c := max(col(start+setup+t.Server), b)
+		d := min(max((start+t.Total)*barWidth/end, c+1), barWidth)
+		bar := strings.Repeat(" ", a) + strings.Repeat(glyph("░", "-"), b-a) +
+			strings.Repeat(glyph("▒", "="), c-b) + strings.Repeat(glyph("█", "#"), d-c)
+		s := statusString("%-3s", status)
+		if failed {
+			s = errorString("%-3s", status)
+		}
+		printf("   %-*s %s %s %s %s\n", nameWidth, name, s, valueString("%8s", formatBytes(size)),
+			label(glyph("│", "|"))+bar+strings.Repeat(" ", max(barWidth-d, 0))+label(glyph("│", "|")),
+			valueString("%s", formatTiming(t.Total)))
+	}
+
+	row(pageName(u, u.String()), statusCode(report.Status), false, report.Size.BodyBytes, 0, report.micros)
+	for _, r := range p.Resources {
+		status := statusCode(r.Status)
+		if r.Error != "" {
+			status = "ERR"
+		}
+		row(pageName(u, r.URL), status, r.Error != "" || status >= "400", r.Size, r.start, r.micros)
+	}
+	printf("   %s %s\n", label("%d resources, %s in", len(p.Resources)+1, formatBytes(p.Bytes)),
+		valueString("%s", formatTiming(p.load)))
+	for _, r := range p.Resources {
+		if r.Error != "" {
+			printf("   %s %s\n", errorString(r.URL+":"), r.Error)
+		}
+	}
+}
+
+// pageName shortens a resource URL to its path when it is on the
+// page's host.
+func pageName(page *url.URL, resource string) string {
+	u, err := url.Parse(resource)
+	if err != nil || u.Host != page.Host || u.Scheme != page.Scheme {
+		return resource
+	}
+	return u.RequestURI()
+}
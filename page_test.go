@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPageResources(t *testing.T) {
+	doc := []byte(`<!DOCTYPE html>
+<html><head>
+<link rel="stylesheet" href="/css/site.css">
+<link rel="icon" href="/favicon.ico">
+<link rel="Alternate Stylesheet" href="dark.css">
+<script src="https://cdn.example.net/lib.js"></script>
+<script>inline()</script>
+</head><body>
+<img src="img/a.png#top"><img src="img/a.png"/>
+<img src="data:image/gif;base64,R0lGOD">
+</body></html>`)
+	base, _ := url.Parse("https://example.com/blog/post")
+
+	var got []string
+	for _, r := range pageResources(doc, base, false) {
+		got = append(got, r.Type+" "+r.URL)
+	}
+	want := []string{
+		"stylesheet https://example.com/css/site.css",
+		"stylesheet https://example.com/blog/dark.css",
+		"script https://cdn.example.net/lib.js",
+		"image https://example.com/blog/img/a.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if r := pageResources(doc, base, true); len(r) != 3 {
+		t.Errorf("got %d same origin resources, want 3", len(r))
+	}
+
+	doc = []byte(`<base href="https://static.example.com/v2/"><img src="x.png">`)
+	if r := pageResources(doc, base, false); len(r) != 1 || r[0].URL != "https://static.example.com/v2/x.png" {
+		t.Errorf("<base> not applied: %+v", r)
+	}
+}
+
+func TestPageName(t *testing.T) {
+	page, _ := url.Parse("https://example.com/")
+	if got := pageName(page, "https://example.com/a.css?v=2"); got != "/a.css?v=2" {
+		t.Errorf("got %q", got)
+	}
+	if got := pageName(page, "https://cdn.example.net/a.js"); got != "https://cdn.example.net/a.js" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"text/html; charset=utf-8": true,
+		"application/xhtml+xml":    true,
+		"text/plain":               false,
+		"":                         false,
+	} {
+		if got := isHTML(ct); got != want {
+			t.Errorf("isHTML(%q) = %v", ct, got)
+		}
+	}
+}
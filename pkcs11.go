@@ -0,0 +1,192 @@
+//go:build pkcs11
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// loadPKCS11Cert loads the client certificate identified by spec,
+// which has the form module:slot:id, from a PKCS#11 token. The private
+// key never leaves the token, signatures are made on it through a
+// crypto.Signer.
+func loadPKCS11Cert(spec, pin string) (tls.Certificate, error) {
+	module, slot, id, err := parsePKCS11Spec(spec)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to initialise PKCS#11 module: %v", err)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to open PKCS#11 session on slot %d: %v", slot, err)
+	}
+
+	if pin == "" {
+		if pin, err = readPassword("Enter PIN for PKCS#11 token: "); err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to read PKCS#11 PIN: %v", err)
+		}
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+		return tls.Certificate{}, fmt.Errorf("PKCS#11 login failed: %v", err)
+	}
+
+	certObj, err := findObject(ctx, session, pkcs11.CKO_CERTIFICATE, id)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	attrs, err := ctx.GetAttributeValue(session, certObj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to read certificate from PKCS#11 token: %v", err)
+	}
+	cert, err := x509.ParseCertificate(attrs[0].Value)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to parse certificate from PKCS#11 token: %v", err)
+	}
+
+	keyObj, err := findObject(ctx, session, pkcs11.CKO_PRIVATE_KEY, id)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey: &pkcs11Signer{
+			ctx:     ctx,
+			session: session,
+			key:     keyObj,
+			pub:     cert.PublicKey,
+		},
+		Leaf: cert,
+	}, nil
+}
+
+func findObject(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, class uint, id []byte) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, err
+	}
+	defer ctx.FindObjectsFinal(session)
+	objs, _, err := ctx.FindObjects(session, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) == 0 {
+		kind := "certificate"
+		if class == pkcs11.CKO_PRIVATE_KEY {
+			kind = "private key"
+		}
+		return 0, fmt.Errorf("no %s with id %x found on PKCS#11 token", kind, id)
+	}
+	return objs[0], nil
+}
+
+// pkcs11Signer is a crypto.Signer backed by a private key held on a
+// PKCS#11 token.
+type pkcs11Signer struct {
+	mu      sync.Mutex // PKCS#11 sessions are not safe for concurrent use
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	pub     crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey { return s.pub }
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mech *pkcs11.Mechanism
+	data := digest
+
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			params, err := pssParams(pss)
+			if err != nil {
+				return nil, err
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params)
+			break
+		}
+		prefix, ok := digestInfoPrefix[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+		}
+		mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		data = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	default:
+		return nil, errors.New("unsupported PKCS#11 key type")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{mech}, s.key); err != nil {
+		return nil, err
+	}
+	sig, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.pub.(*ecdsa.PublicKey); ok {
+		// PKCS#11 returns r || s, crypto/tls expects ASN.1.
+		n := len(sig) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(sig[:n]),
+			new(big.Int).SetBytes(sig[n:]),
+		})
+	}
+	return sig, nil
+}
+
+func pssParams(opts *rsa.PSSOptions) ([]byte, error) {
+	var hash, mgf uint
+	switch opts.Hash {
+	case crypto.SHA256:
+		hash, mgf = pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256
+	case crypto.SHA384:
+		hash, mgf = pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384
+	case crypto.SHA512:
+		hash, mgf = pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512
+	default:
+		return nil, fmt.Errorf("unsupported PSS hash function %v", opts.Hash)
+	}
+	salt := opts.SaltLength
+	if salt == rsa.PSSSaltLengthEqualsHash || salt == rsa.PSSSaltLengthAuto {
+		salt = opts.Hash.Size()
+	}
+	return pkcs11.NewPSSParams(hash, mgf, uint(salt)), nil
+}
+
+// digestInfoPrefix holds the DER encoded DigestInfo prefixes which
+// PKCS #1 v1.5 signatures wrap around the digest.
+var digestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
@@ -0,0 +1,17 @@
+//go:build !pkcs11
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// loadPKCS11Cert is unavailable unless httpstat is built with
+// -tags pkcs11, which requires cgo.
+func loadPKCS11Cert(spec, pin string) (tls.Certificate, error) {
+	if _, _, _, err := parsePKCS11Spec(spec); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{}, errors.New("PKCS#11 support not available; rebuild httpstat with -tags pkcs11")
+}
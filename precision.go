@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timingPrecision is how finely timings are shown, ms or us.
+var timingPrecision = "ms"
+
+// usSince returns the microseconds elapsed since t.
+func usSince(t time.Time) int {
+	return int(time.Now().Sub(t) / time.Microsecond)
+}
+
+// millis converts a timing recorded in microseconds to milliseconds.
+func (t Timing) millis() Timing {
+	return Timing{
+		DNS:           t.DNS / 1000,
+		TCP:           t.TCP / 1000,
+		TLS:           t.TLS / 1000,
+		Server:        t.Server / 1000,
+		Transfer:      t.Transfer / 1000,
+		Lookup:        t.Lookup / 1000,
+		Connect:       t.Connect / 1000,
+		PreTransfer:   t.PreTransfer / 1000,
+		StartTransfer: t.StartTransfer / 1000,
+		Total:         t.Total / 1000,
+	}
+}
+
+// formatTiming formats a duration of us microseconds in milliseconds,
+// to the microsecond with -precision us.
+func formatTiming(us int) string {
+	if timingPrecision == "us" {
+		return fmt.Sprintf("%.3fms", float64(us)/1000)
+	}
+	return strconv.Itoa(us/1000) + "ms"
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestFormatTiming(t *testing.T) {
+	defer func(p string) { timingPrecision = p }(timingPrecision)
+	for _, tt := range []struct {
+		precision string
+		us        int
+		want      string
+	}{
+		{"ms", 412, "0ms"},
+		{"ms", 12999, "12ms"},
+		{"us", 412, "0.412ms"},
+		{"us", 12999, "12.999ms"},
+	} {
+		timingPrecision = tt.precision
+		if got := formatTiming(tt.us); got != tt.want {
+			t.Errorf("formatTiming(%d) with -precision %s = %q, want %q", tt.us, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestTimingMillis(t *testing.T) {
+	got := Timing{DNS: 999, TCP: 1000, Total: 25400}.millis()
+	if want := (Timing{DNS: 0, TCP: 1, Total: 25}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
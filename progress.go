@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressInterval = 100 * time.Millisecond
+	progressWidth    = 30
+)
+
+// progressReader draws a progress bar on stderr as r is read, clearing
+// it once r is exhausted.
+type progressReader struct {
+	io.ReadCloser
+	label string
+	total int64 // -1 if unknown
+	n     int64
+	start time.Time
+	drawn time.Time
+	shown bool
+	done  bool
+}
+
+// showProgress reports whether progress bars should be drawn, only when
+// stderr is a terminal and the output is not JSON.
+func showProgress() bool {
+	return textReport() && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+func newProgressReader(r io.ReadCloser, label string, total int64) *progressReader {
+	now := time.Now()
+	return &progressReader{ReadCloser: r, label: label, total: total, start: now, drawn: now}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	p.n += int64(n)
+	if err != nil {
+		p.clear()
+	} else if now := time.Now(); now.Sub(p.drawn) >= progressInterval {
+		p.drawn, p.shown = now, true
+		p.draw(now)
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	p.clear()
+	return p.ReadCloser.Close()
+}
+
+func (p *progressReader) draw(now time.Time) {
+	elapsed := now.Sub(p.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(p.n) / elapsed
+	}
+	line := fmt.Sprintf("%s %s %s/s", p.label, formatBytes(p.n), formatBytes(int64(rate)))
+	if p.total > 0 {
+		frac := min(float64(p.n)/float64(p.total), 1)
+		filled := int(frac * progressWidth)
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+		eta := "-"
+		if rate > 0 {
+			eta = time.Duration(float64(p.total-p.n) / rate * float64(time.Second)).Round(time.Second).String()
+		}
+		line = fmt.Sprintf("%s [%s] %3.0f%% %s/%s %s/s ETA %s", p.label, bar, 100*frac,
+			formatBytes(p.n), formatBytes(p.total), formatBytes(int64(rate)), eta)
+	}
+	fmt.Fprintf(os.Stderr, "\r%s\x1b[K", line)
+}
+
+func (p *progressReader) clear() {
+	if p.shown && !p.done {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+	}
+	p.done = true
+}
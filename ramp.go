@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kneeFactor is how many times the p95 latency at the lightest load
+// that of a step must be for the latency curve to have turned up there.
+const kneeFactor = 2
+
+// rampProfile is a flag.Value for the load of -ramp, rising from from to
+// to over period: concurrent requests, or requests a second with qps.
+type rampProfile struct {
+	from, to float64
+	qps      bool
+	period   time.Duration
+}
+
+func (p rampProfile) String() string {
+	if p.period == 0 {
+		return ""
+	}
+	var unit string
+	if p.qps {
+		unit = "qps"
+	}
+	return fmt.Sprintf("%s..%s%s/%s", trimFloat(p.from), trimFloat(p.to), unit, p.period)
+}
+
+func (p *rampProfile) Set(v string) error {
+	invalid := fmt.Errorf("invalid ramp %q, use FROM..TO/PERIOD, eg. 1..50/2m for concurrent requests or 10..200qps/2m for requests a second", v)
+	load, period, ok := strings.Cut(v, "/")
+	from, to, ok2 := strings.Cut(load, "..")
+	if !ok || !ok2 {
+		return invalid
+	}
+	var r rampProfile
+	r.qps = strings.HasSuffix(to, "qps")
+	from, to = strings.TrimSuffix(from, "qps"), strings.TrimSuffix(to, "qps")
+	var err1, err2, err3 error
+	r.from, err1 = strconv.ParseFloat(from, 64)
+	r.to, err2 = strconv.ParseFloat(to, 64)
+	r.period, err3 = time.ParseDuration(period)
+	if err1 != nil || err2 != nil || err3 != nil || r.from <= 0 || r.period <= 0 {
+		return invalid
+	}
+	if r.to < r.from {
+		return fmt.Errorf("invalid ramp %q, the load must rise", v)
+	}
+	if !r.qps && (r.from != math.Trunc(r.from) || r.to != math.Trunc(r.to)) {
+		return fmt.Errorf("invalid ramp %q, concurrent requests are whole numbers", v)
+	}
+	*p = r
+	return nil
+}
+
+// levels returns the load of each of up to steps steps, evenly spaced
+// from p.from to p.to.
+func (p rampProfile) levels(steps int) []float64 {
+	if !p.qps {
+		// no more steps than there are numbers of requests.
+		steps = min(steps, int(p.to-p.from)+1)
+	}
+	if steps < 2 || p.from == p.to {
+		return []float64{p.to}
+	}
+	v := make([]float64, steps)
+	for i := range v {
+		v[i] = p.from + (p.to-p.from)*float64(i)/float64(steps-1)
+		if !p.qps {
+			v[i] = math.Round(v[i])
+		}
+	}
+	return v
+}
+
+// RampStep is the latency at one load step of -ramp.
+type RampStep struct {
+	Load       float64 // concurrent requests, or requests a second
+	Requests   int
+	Errors     int     // failed requests and 5xx responses
+	Throughput float64 // requests completed a second
+	Latency    Stats   // of the requests without errors, in milliseconds
+}
+
+// newRampStep summarizes the requests made at load over elapsed.
+func newRampStep(load float64, results []Resource, elapsed time.Duration) RampStep {
+	s := RampStep{Load: load, Requests: len(results)}
+	var total []float64
+	for _, r := range results {
+		if r.Error != "" || statusNumber(r.Status) >= 500 {
+			s.Errors++
+			continue
+		}
+		total = append(total, float64(r.micros.Total)/1000)
+	}
+	s.Latency = newStats(total)
+	if elapsed > 0 {
+		s.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+	return s
+}
+
+// rampStep makes requests at load for d and summarizes them.
+func rampStep(client *http.Client, newRequest func() *http.Request, load float64, qps bool, d time.Duration) RampStep {
+	results, elapsed := generateLoad(client, newRequest, load, qps, d)
+	return newRampStep(load, results, elapsed)
+}
+
+// generateLoad makes requests at load for d, as that many requests at a
+// time or, with qps, starting that many a second whether the earlier ones
+// have finished or not. Requests started within d are waited for, and
+// it returns them with how long that took.
+func generateLoad(client *http.Client, newRequest func() *http.Request, load float64, qps bool, d time.Duration) ([]Resource, time.Duration) {
+	var mu sync.Mutex
+	var results []Resource
+	do := func() {
+		var r Resource
+		timeResource(client, &r, newRequest(), time.Now())
+		mu.Lock()
+		results = append(results, r)
+		mu.Unlock()
+	}
+
+	start := time.Now()
+	end := start.Add(d)
+	var wg sync.WaitGroup
+	if qps {
+		t := time.NewTicker(time.Duration(float64(time.Second) / load))
+		for now := start; now.Before(end); now = <-t.C {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				do()
+			}()
+		}
+		t.Stop()
+	} else {
+		for w := 0; w < int(load); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(end) {
+					do()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	return results, time.Since(start)
+}
+
+// rampKnee returns the step at which the latency curve turns up: the
+// first whose p95 is kneeFactor times that at the lightest load, or more
+// than 1% of whose requests fail. It returns -1 if there is none.
+func rampKnee(steps []RampStep) int {
+	if len(steps) == 0 {
+		return -1
+	}
+	base := steps[0].Latency.P95
+	for i, s := range steps {
+		if s.Errors*100 > s.Requests || i > 0 && base > 0 && s.Latency.P95 >= kneeFactor*base {
+			return i
+		}
+	}
+	return -1
+}
+
+// rampUnit names the load of p.
+func rampUnit(p rampProfile) string {
+	if p.qps {
+		return "requests a second"
+	}
+	return "concurrent requests"
+}
+
+func printRampStep(s RampStep, knee bool) {
+	row := fmt.Sprintf("%8s %9d %7d %9s %9s %9s %9s", trimFloat(s.Load), s.Requests, s.Errors,
+		fmt.Sprintf("%.1f/s", s.Throughput), fmt.Sprintf("%.1fms", s.Latency.P50),
+		fmt.Sprintf("%.1fms", s.Latency.P95), fmt.Sprintf("%.1fms", s.Latency.Max))
+	switch {
+	case knee:
+		printf("   %s %s\n", warnString("%s", row), warnString(glyph("← knee", "<- knee")))
+	case s.Errors > 0:
+		printf("   %s\n", errorString("%s", row))
+	default:
+		printf("   %s\n", valueString("%s", row))
+	}
+}
+
+// runRamp times requests to u as the load rises by the -ramp profile,
+// reporting the latency at each step and where it turns up.
+func runRamp(u *url.URL) {
+	levels := ramp.levels(rampSteps)
+	d := ramp.period / time.Duration(len(levels))
+	req := prepareRequest(u)
+	tr := transportFor(u, req)
+	tr.MaxIdleConnsPerHost = int(math.Ceil(ramp.to))
+	client := newClient(tr)
+
+	// requests are prepared one at a time, as their templates and
+	// bodies are shared; the first is the one the transport was made for.
+	var mu sync.Mutex
+	newRequest := func() *http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		if req != nil {
+			r := req
+			req = nil
+			return r
+		}
+		return prepareRequest(u)
+	}
+
+	if textReport() {
+		printf("%s\n\n", labelString("Ramping from %s to %s %s over %s, %d steps of %s:", trimFloat(ramp.from),
+			trimFloat(ramp.to), rampUnit(ramp), ramp.period, len(levels), d))
+		printf("   %s\n", labelString("%8s %9s %7s %9s %9s %9s %9s", "Load", "Requests", "Errors", "Rate", "p50", "p95", "Max"))
+	}
+	var steps []RampStep
+	for _, load := range levels {
+		steps = append(steps, rampStep(client, newRequest, load, ramp.qps, d))
+		if textReport() {
+			printRampStep(steps[len(steps)-1], rampKnee(steps) == len(steps)-1)
+		}
+	}
+
+	if jsonOutput {
+		b, err := json.Marshal(steps)
+		if err != nil {
+			log.Fatalf("unable to marshal json ramp: %v", err)
+		}
+		printf("%s\n", b)
+		return
+	}
+	if !textReport() {
+		return
+	}
+	if i := rampKnee(steps); i >= 0 {
+		s := steps[i]
+		detail := fmt.Sprintf("p95 %.1fms, %.1fx the %.1fms at %s", s.Latency.P95,
+			s.Latency.P95/math.Max(steps[0].Latency.P95, 0.001), steps[0].Latency.P95, trimFloat(steps[0].Load))
+		if s.Errors*100 > s.Requests {
+			detail = fmt.Sprintf("%d of %d requests failed", s.Errors, s.Requests)
+		}
+		printf("\n%s %s %s\n", labelString("Knee:"), warnString("at %s %s", trimFloat(s.Load), rampUnit(ramp)), labelString("(%s)", detail))
+	} else {
+		printf("\n%s %s\n", labelString("Knee:"), okString("none up to %s %s", trimFloat(ramp.to), rampUnit(ramp)))
+	}
+}
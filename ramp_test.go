@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestRampProfile(t *testing.T) {
+	for v, want := range map[string]rampProfile{
+		"1..50/2m":         {1, 50, false, 2 * time.Minute},
+		"10..200qps/30s":   {10, 200, true, 30 * time.Second},
+		"0.5qps..5qps/10s": {0.5, 5, true, 10 * time.Second},
+	} {
+		var p rampProfile
+		if err := p.Set(v); err != nil || p != want {
+			t.Errorf("Set(%q) = %+v, %v, want %+v", v, p, err, want)
+		}
+	}
+	for _, v := range []string{"1..50", "50/2m", "50..1/2m", "0..5/1m", "1.5..5/1m", "1..5/soon", ""} {
+		var p rampProfile
+		if err := p.Set(v); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestRampLevels(t *testing.T) {
+	for _, tt := range []struct {
+		p     rampProfile
+		steps int
+		want  []float64
+	}{
+		{rampProfile{from: 1, to: 50}, 8, []float64{1, 8, 15, 22, 29, 36, 43, 50}},
+		{rampProfile{from: 1, to: 3}, 10, []float64{1, 2, 3}},
+		{rampProfile{from: 10, to: 20, qps: true}, 3, []float64{10, 15, 20}},
+		{rampProfile{from: 5, to: 5}, 10, []float64{5}},
+	} {
+		if got := tt.p.levels(tt.steps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s in %d steps = %v, want %v", tt.p, tt.steps, got, tt.want)
+		}
+	}
+}
+
+func TestRampKnee(t *testing.T) {
+	step := func(p95 float64, errors int) RampStep {
+		return RampStep{Requests: 100, Errors: errors, Latency: Stats{P95: p95}}
+	}
+	for _, tt := range []struct {
+		steps []RampStep
+		want  int
+	}{
+		{[]RampStep{step(10, 0), step(12, 0), step(19, 0)}, -1},
+		{[]RampStep{step(10, 0), step(12, 0), step(25, 0), step(80, 0)}, 2},
+		{[]RampStep{step(10, 0), step(11, 5), step(30, 0)}, 1},
+		{nil, -1},
+	} {
+		if got := rampKnee(tt.steps); got != tt.want {
+			t.Errorf("rampKnee(%+v) = %d, want %d", tt.steps, got, tt.want)
+		}
+	}
+}
+
+func TestRampStep(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		return req
+	}
+
+	s := rampStep(ts.Client(), newRequest, 1, false, 50*time.Millisecond)
+	if s.Requests == 0 || s.Errors != s.Requests/4 || s.Latency.Count != s.Requests-s.Errors || s.Throughput <= 0 {
+		t.Errorf("one at a time: %+v", s)
+	}
+	s = rampStep(ts.Client(), newRequest, 100, true, 100*time.Millisecond)
+	if s.Requests < 5 || s.Requests > 11 {
+		t.Errorf("100 a second for 100ms made %d requests", s.Requests)
+	}
+}
+
+func TestNewRampStep(t *testing.T) {
+	results := []Resource{
+		{Status: "200 OK", micros: Timing{Total: 10000}},
+		{Status: "503 Service Unavailable"},
+		{Status: "OK", micros: Timing{Total: 30000}}, // malformed, but not a server error
+		{Error: "connection refused"},
+	}
+	s := newRampStep(2, results, time.Second)
+	if s.Requests != 4 || s.Errors != 2 || s.Latency.Count != 2 || s.Throughput != 4 {
+		t.Errorf("step %+v", s)
+	}
+}
+
+func TestRunRamp(t *testing.T) {
+	var withCookie, requests int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if _, err := r.Cookie("seen"); err == nil {
+			atomic.AddInt32(&withCookie, 1)
+		}
+		http.SetCookie(w, &http.Cookie{Name: "seen", Value: "1"})
+	}))
+	defer ts.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(ca, pemCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, ca0, r0, n0, j0, c0, trs := color.Output, cacert, ramp, rampSteps, jsonOutput, cookies, transports
+	defer func() {
+		color.Output, cacert, ramp, rampSteps, jsonOutput, cookies, transports = output, ca0, r0, n0, j0, c0, trs
+	}()
+	var buf bytes.Buffer
+	color.Output, cacert, jsonOutput, cookies, transports = &buf, ca, true, newCookieJar(), map[string]*http.Transport{}
+	ramp, rampSteps = rampProfile{from: 1, to: 2, period: 100 * time.Millisecond}, 2
+
+	u, _ := url.Parse(ts.URL)
+	runRamp(u)
+	var steps []RampStep
+	if err := json.Unmarshal(buf.Bytes(), &steps); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	for _, s := range steps {
+		if s.Requests == 0 || s.Errors != 0 {
+			t.Errorf("step %+v, trusting the -cacert", s)
+		}
+	}
+	if withCookie == 0 || withCookie >= requests {
+		t.Errorf("%d of %d requests sent the cookie set by the first", withCookie, requests)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// randomBody is the -body-random payload: random bytes, or a random
+// JSON document, of a size picked from min to max for each request.
+type randomBody struct {
+	json     bool
+	min, max int64
+}
+
+func (b randomBody) String() string {
+	if b.max == 0 {
+		return ""
+	}
+	s := formatBytes(b.min)
+	if b.max != b.min {
+		s += ".." + formatBytes(b.max)
+	}
+	if b.json {
+		s = "json:" + s
+	}
+	return s
+}
+
+// Set parses SIZE or MIN..MAX, prefixed with json: for a JSON body.
+func (b *randomBody) Set(v string) error {
+	spec := strings.TrimPrefix(v, "json:")
+	r := randomBody{json: spec != v}
+	lo, hi, isRange := strings.Cut(spec, "..")
+	var err error
+	if r.min, err = parseSize(lo); err != nil {
+		return err
+	}
+	r.max = r.min
+	if isRange {
+		if r.max, err = parseSize(hi); err != nil {
+			return err
+		}
+	}
+	if r.max < r.min || r.max == 0 {
+		return fmt.Errorf("invalid size range %q", v)
+	}
+	*b = r
+	return nil
+}
+
+// parseSize parses a number of bytes with an optional B, KB, MB or GB
+// suffix in powers of 1000, or KiB, MiB or GiB in powers of 1024.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		n      int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"B", 1},
+	}
+	t := strings.TrimSpace(s)
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(t), strings.ToUpper(u.suffix)) {
+			t, mult = strings.TrimSpace(t[:len(t)-len(u.suffix)]), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// setRandomBody gives req a freshly generated random body. The same
+// body is generated again for retries.
+func setRandomBody(req *http.Request, b randomBody) {
+	seed := time.Now().UnixNano()
+	rnd := rand.New(rand.NewSource(seed))
+	size := b.min + rnd.Int63n(b.max-b.min+1)
+
+	open := func() io.ReadCloser {
+		rnd := rand.New(rand.NewSource(seed))
+		if b.json {
+			return ioutil.NopCloser(bytes.NewReader(randomJSON(rnd, size)))
+		}
+		return ioutil.NopCloser(io.LimitReader(rnd, size))
+	}
+	req.Body, req.ContentLength = open(), size
+	req.GetBody = func() (io.ReadCloser, error) { return open(), nil }
+
+	if !httpHeaders.has("Content-Type") {
+		contentType := "application/octet-stream"
+		if b.json {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+}
+
+// randomJSON generates a JSON document of size bytes, if that's large
+// enough for one, holding an array of random records.
+func randomJSON(rnd *rand.Rand, size int64) []byte {
+	const alnum = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	word := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alnum[rnd.Intn(len(alnum))]
+		}
+		return string(b)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"records":[`)
+	const tail = `],"pad":""}`
+	for i := 0; ; i++ {
+		rec := fmt.Sprintf(`{"id":%d,"name":%q,"value":%.3f,"active":%t}`,
+			rnd.Intn(1e6), word(4+rnd.Intn(12)), rnd.Float64()*1000, rnd.Intn(2) == 1)
+		if i > 0 {
+			rec = "," + rec
+		}
+		if int64(buf.Len()+len(rec)+len(tail)) > size {
+			break
+		}
+		buf.WriteString(rec)
+	}
+	buf.WriteString(`],"pad":"`)
+	if pad := size - int64(buf.Len()) - 2; pad > 0 {
+		buf.WriteString(word(int(pad)))
+	}
+	buf.WriteString(`"}`)
+	return buf.Bytes()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"512": 512, "1KB": 1000, "1kb": 1000, "64K": 64000, "1.5MB": 1500000,
+		"1KiB": 1024, "2MiB": 2 << 20, "10B": 10,
+	} {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "KB", "-1", "1TB"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestRandomBodySet(t *testing.T) {
+	var b randomBody
+	if err := b.Set("json:1KB..64KB"); err != nil || !b.json || b.min != 1000 || b.max != 64000 {
+		t.Errorf("got %+v, %v", b, err)
+	}
+	for _, v := range []string{"64KB..1KB", "0", "x"} {
+		if err := b.Set(v); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestSetRandomBody(t *testing.T) {
+	for _, b := range []randomBody{{min: 100, max: 200}, {json: true, min: 500, max: 5000}} {
+		req, _ := http.NewRequest("POST", "http://example.com/", nil)
+		setRandomBody(req, b)
+		first, _ := ioutil.ReadAll(req.Body)
+		if int64(len(first)) != req.ContentLength || req.ContentLength < b.min || req.ContentLength > b.max {
+			t.Errorf("%v: read %d bytes with Content-Length %d", b, len(first), req.ContentLength)
+		}
+		if b.json && !json.Valid(first) {
+			t.Errorf("%v: invalid JSON %s", b, first)
+		}
+		retry, _ := req.GetBody()
+		if again, _ := ioutil.ReadAll(retry); string(again) != string(first) {
+			t.Errorf("%v: retried body differs", b)
+		}
+	}
+}
+
+func TestRandomJSONSize(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, size := range []int64{30, 100, 1000, 12345} {
+		b := randomJSON(rnd, size)
+		if int64(len(b)) != size || !json.Valid(b) {
+			t.Errorf("randomJSON(%d) gave %d bytes: %s", size, len(b), b)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// resumeFrom is the offset the current -continue-at download was asked
+// to resume from, or 0 for the whole body.
+var resumeFrom int64
+
+// Resume reports resuming a download with -continue-at.
+type Resume struct {
+	Offset  int64 // the bytes already downloaded, which were skipped
+	Honored bool  // whether the server sent the body from Offset
+	// Complete is set if there was nothing left to download.
+	Complete bool `json:",omitempty"`
+}
+
+// resumeFilename returns the file a download of u would be saved as
+// before the response names it, for -O.
+func resumeFilename(u *url.URL) string {
+	if outputFile != "" {
+		return outputFile
+	}
+	return path.Base(u.RequestURI())
+}
+
+// resumeOffset returns where to resume downloading into filename: the
+// size of the file for -continue-at -, otherwise the offset given.
+func resumeOffset(spec, filename string) (int64, error) {
+	if spec == "-" {
+		fi, err := os.Stat(filename)
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	n, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid -continue-at offset %q, want - or a number of bytes", spec)
+	}
+	return n, nil
+}
+
+// setResumeRange asks for the part of the download not yet saved.
+func setResumeRange(req *http.Request) {
+	var err error
+	resumeFrom, err = resumeOffset(continueAt, resumeFilename(req.URL))
+	if err != nil {
+		log.Fatalf("unable to resume download: %v", err)
+	}
+	if resumeFrom > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeFrom))
+	}
+}
+
+// checkResume reports whether resp continues the download from offset.
+func checkResume(resp *http.Response, offset int64) *Resume {
+	r := &Resume{Offset: offset}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		r.Honored = strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset))
+	case http.StatusRequestedRangeNotSatisfiable:
+		r.Complete = true
+	}
+	return r
+}
+
+func printResume(r *Resume) {
+	label := labelString
+	switch {
+	case r.Complete:
+		printf("\n%s %s\n", label("Resume:"), valueString("already complete at %s", formatBytes(r.Offset)))
+	case r.Honored:
+		printf("\n%s %s\n", label("Resume:"), okString("resumed at %s", formatBytes(r.Offset)))
+	default:
+		printf("\n%s %s\n", label("Resume:"), warnString("range ignored, downloaded from the start"))
+	}
+}
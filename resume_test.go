@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeOffset(t *testing.T) {
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "partial")
+	if err := ioutil.WriteFile(partial, make([]byte, 1234), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		spec, filename string
+		want           int64
+	}{
+		{"-", partial, 1234},
+		{"-", filepath.Join(dir, "missing"), 0},
+		{"100", partial, 100},
+	} {
+		got, err := resumeOffset(tt.spec, tt.filename)
+		if err != nil || got != tt.want {
+			t.Errorf("resumeOffset(%q, %q) = %d, %v, want %d", tt.spec, tt.filename, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"", "-1", "1k"} {
+		if _, err := resumeOffset(spec, partial); err == nil {
+			t.Errorf("resumeOffset(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestCheckResume(t *testing.T) {
+	for _, tt := range []struct {
+		status       int
+		contentRange string
+		want         Resume
+	}{
+		{206, "bytes 100-199/200", Resume{Offset: 100, Honored: true}},
+		{206, "bytes 0-199/200", Resume{Offset: 100}},
+		{200, "", Resume{Offset: 100}},
+		{416, "bytes */100", Resume{Offset: 100, Complete: true}},
+	} {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.contentRange != "" {
+			resp.Header.Set("Content-Range", tt.contentRange)
+		}
+		if got := checkResume(resp, 100); *got != tt.want {
+			t.Errorf("%d %q: got %+v, want %+v", tt.status, tt.contentRange, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Attempt is a try of the request that failed and was retried with
+// -retry.
+type Attempt struct {
+	Error  string `json:",omitempty"`
+	Status string `json:",omitempty"`
+	// RetryOn is the -retry-on condition the attempt failed on.
+	RetryOn string
+	Timing  Timing
+	Wait    int // milliseconds waited before the next attempt
+
+	micros Timing // the timing in microseconds
+}
+
+// retryFailures are the kinds of failed request -retry-on can name.
+var retryFailures = []string{"dns", "connect", "tls", "timeout", "reset"}
+
+// retryConditions is a flag.Value listing the failures to retry with
+// -retry: those of retryFailures, and response statuses as a code such
+// as 429, or a class such as 5xx.
+type retryConditions []string
+
+func (c retryConditions) String() string {
+	return strings.Join(c, ",")
+}
+
+func (c *retryConditions) Set(v string) error {
+	var conditions retryConditions
+	for _, s := range strings.Split(v, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !isRetryStatus(s) && !contains(retryFailures, s) {
+			return fmt.Errorf("unknown retry condition %q, use %s, a status code or a class such as 5xx", s, strings.Join(retryFailures, ", "))
+		}
+		conditions = append(conditions, s)
+	}
+	*c = conditions
+	return nil
+}
+
+// isRetryStatus reports whether s names a status code or class.
+func isRetryStatus(s string) bool {
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	digit := func(b byte) bool { return '0' <= b && b <= '9' }
+	return s[1:] == "xx" || digit(s[1]) && digit(s[2])
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// status returns the condition a response with status code is retried
+// on, if any.
+func (c retryConditions) status(code int) (string, bool) {
+	s := strconv.Itoa(code)
+	for _, cond := range c {
+		if cond == s || cond == s[:1]+"xx" {
+			return cond, true
+		}
+	}
+	return "", false
+}
+
+// failure returns the condition a failed request is retried on, if any.
+func (c retryConditions) failure(err error) (string, bool) {
+	kind := failureKind(err)
+	return kind, contains(c, kind)
+}
+
+// failureKind classifies the failure of a request as one of
+// retryFailures, or as "other" if it's none of them, such as a server
+// answering HTTPS with HTTP or a proxy refusing to CONNECT.
+func failureKind(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	var alert tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		return "timeout"
+	case errors.As(err, &recordErr) || errors.As(err, &alert) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr):
+		return "tls"
+	case errors.As(err, &opErr) && opErr.Op == "remote error" || strings.Contains(err.Error(), "tls: "):
+		// an alert the server sent, or a handshake it broke off.
+		return "tls"
+	case dialFailed(err):
+		return "connect"
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		// the connection was reset or closed during the exchange.
+		return "reset"
+	}
+	return "other"
+}
+
+// dialFailed reports whether err is of a connection that couldn't be
+// made, to the server or to a proxy.
+func dialFailed(err error) bool {
+	for {
+		var opErr *net.OpError
+		if !errors.As(err, &opErr) {
+			return false
+		}
+		if opErr.Op == "dial" {
+			return true
+		}
+		err = opErr.Err
+	}
+}
+
+// maxBackoff caps the wait between attempts.
+const maxBackoff = 10 * time.Minute
+
+// backoff returns the wait before retry n, the first being 1: base
+// doubled for each retry before it, of which a random half is taken off
+// so clients failing together don't all retry together.
+func backoff(base time.Duration, n int, random func(int64) int64) time.Duration {
+	d := base
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	half := int64(d / 2)
+	return d - time.Duration(random(half+1))
+}
+
+// tryRoundTrip is roundTrip returning the failure of the request rather
+// than exiting on it.
+func tryRoundTrip(client *http.Client, req *http.Request, report *Report) (resp *http.Response, err *requestError) {
+	defer func(prev bool) {
+		recoverFailures = prev
+		if r := recover(); r != nil {
+			e, ok := r.(*requestError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}(recoverFailures)
+	recoverFailures = true
+	return roundTrip(client, req, report), nil
+}
+
+// retryRoundTrip makes req, and with -retry makes it again as newly
+// prepared for url after each failure it is retried for, waiting longer
+// each time. It returns the request and response of the last attempt;
+// those before are recorded in report.Attempts.
+func retryRoundTrip(client *http.Client, url *url.URL, req *http.Request, report *Report) (*http.Request, *http.Response) {
+	if retries <= 0 {
+		return req, roundTrip(client, req, report)
+	}
+	start := time.Now()
+	for n := 1; ; n++ {
+		resp, err := tryRoundTrip(client, req, report)
+		var cond string
+		var retry bool
+		if err != nil {
+			cond, retry = retryOn.failure(err.err)
+		} else {
+			cond, retry = retryOn.status(resp.StatusCode)
+		}
+		if !retry {
+			if err != nil {
+				failRequest(err.err, "%s", err.msg)
+			}
+			return req, resp
+		}
+		wait := backoff(retryDelay, n, rand.Int63n)
+		if n > retries || retryMaxTime > 0 && time.Since(start)+wait > retryMaxTime {
+			if err != nil {
+				failRequest(err.err, "%s", err.msg)
+			}
+			return req, resp
+		}
+
+		a := Attempt{RetryOn: cond, Wait: int(wait / time.Millisecond)}
+		if err != nil {
+			a.Error = err.msg
+		} else {
+			a.Status = resp.Status
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if report.firstByte.IsZero() {
+			// the request failed before a response, which finish times.
+			report.micros.Total = usSince(report.start)
+			report.Timing = report.micros.millis()
+		} else {
+			report.finish()
+		}
+		a.Timing, a.micros = report.Timing, report.micros
+		*report = Report{Time: report.Time, Attempts: append(report.Attempts, a)}
+
+		if textReport() {
+			failure := a.Error
+			if failure == "" {
+				failure = a.Status
+			}
+			printf("\n%s %s %s\n", warnString("Attempt %d failed:", n), valueString(failure),
+				labelString("(%s), retrying in %s", a.RetryOn, wait.Round(time.Millisecond)))
+			printf("   %s\n", attemptPhases(url.Scheme, a.micros))
+		}
+		if !pause(wait) {
+			// beforeDeadline stops the run on this.
+			failRequest(runContext.Err(), "the -total-time of %s is up", totalTime)
+		}
+		req = prepareRequest(url)
+	}
+}
+
+// attemptPhases describes the phases of an attempt, in microseconds.
+func attemptPhases(scheme string, t Timing) string {
+	phases := []struct {
+		name string
+		us   int
+	}{{"DNS", t.DNS}, {"TCP", t.TCP}, {"TLS", t.TLS}, {"server", t.Server}, {"transfer", t.Transfer}}
+	var s []string
+	for _, p := range phases {
+		if p.name == "TLS" && scheme != "https" {
+			continue
+		}
+		s = append(s, labelString(p.name)+" "+valueString(formatTiming(p.us)))
+	}
+	return strings.Join(s, labelString(", ")) + labelString(", total ") + valueString(formatTiming(t.Total))
+}
+
+// printAttempts shows how much the failed attempts before report added
+// to the time it took to get it.
+func printAttempts(report *Report) {
+	var tried, waited int
+	for _, a := range report.Attempts {
+		tried += a.micros.Total
+		waited += a.Wait * 1000
+	}
+	all := tried + waited + report.micros.Total
+	printf("\n%s %s %s\n", labelString("Retries:"), valueString("%d failed attempts", len(report.Attempts)),
+		labelString("took %s and %s waiting, %s in all, %.1fx the final attempt", formatTiming(tried), formatTiming(waited),
+			formatTiming(all), float64(all)/float64(max(report.micros.Total, 1))))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	none := func(int64) int64 { return 0 }
+	all := func(n int64) int64 { return n - 1 }
+	for _, tt := range []struct {
+		n        int
+		max, min time.Duration
+	}{
+		{1, time.Second, time.Second / 2},
+		{2, 2 * time.Second, time.Second},
+		{4, 8 * time.Second, 4 * time.Second},
+		{40, maxBackoff, maxBackoff / 2},
+	} {
+		if got := backoff(time.Second, tt.n, none); got != tt.max {
+			t.Errorf("backoff(1s, %d) without jitter = %s, want %s", tt.n, got, tt.max)
+		}
+		if got := backoff(time.Second, tt.n, all); got != tt.min {
+			t.Errorf("backoff(1s, %d) with all the jitter = %s, want %s", tt.n, got, tt.min)
+		}
+	}
+}
+
+func TestRetryConditions(t *testing.T) {
+	var c retryConditions
+	if err := c.Set("connect, DNS,5xx,429"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "connect,dns,5xx,429"; c.String() != want {
+		t.Errorf("conditions = %s, want %s", c, want)
+	}
+	for code, want := range map[int]string{503: "5xx", 500: "5xx", 429: "429", 404: "", 200: ""} {
+		if cond, ok := c.status(code); cond != want || ok != (want != "") {
+			t.Errorf("status(%d) = %q, %v, want %q", code, cond, ok, want)
+		}
+	}
+	for _, v := range []string{"5XY", "600", "12", "connection", ""} {
+		if err := c.Set(v); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestFailureKind(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}}, "dns"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, "connect"},
+		{&url.Error{Op: "Get", Err: context.DeadlineExceeded}, "timeout"},
+		{&url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, "tls"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, "reset"},
+		{&url.Error{Op: "Get", Err: io.EOF}, "reset"},
+		{&url.Error{Op: "Get", Err: io.ErrUnexpectedEOF}, "reset"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "write", Err: &os.SyscallError{Syscall: "write", Err: syscall.EPIPE}}}, "reset"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}}, "tls"},
+		{&url.Error{Op: "Get", Err: errors.New("tls: server selected unsupported protocol version 301")}, "tls"},
+		{&url.Error{Op: "Get", Err: errors.New("http: server gave HTTP response to HTTPS client")}, "other"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("Forbidden")}}, "other"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}}, "connect"},
+		{&url.Error{Op: "Get", Err: errors.New("net/http: HTTP/1.x transport connection broken: malformed HTTP response")}, "other"},
+	} {
+		if got := failureKind(tt.err); got != tt.want {
+			t.Errorf("failureKind(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryOnDefault(t *testing.T) {
+	var c retryConditions
+	c.Set("dns,connect,timeout,reset")
+	for err, want := range map[error]bool{
+		&net.OpError{Op: "read", Err: syscall.ECONNRESET}:                          true,
+		&net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}:  false,
+		errors.New("http: server gave HTTP response to HTTPS client"):              false,
+		&net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("Forbidden")}: false,
+	} {
+		if _, retry := c.failure(&url.Error{Op: "Get", Err: err}); retry != want {
+			t.Errorf("retry of %v = %v, want %v", err, retry, want)
+		}
+	}
+}
+
+func TestRetryRoundTrip(t *testing.T) {
+	defer func(n int, d time.Duration, on retryConditions, j bool) {
+		retries, retryDelay, retryOn, jsonOutput = n, d, on, j
+	}(retries, retryDelay, retryOn, jsonOutput)
+	retries, retryDelay, jsonOutput = 3, time.Millisecond, true
+	retryOn.Set("connect,5xx")
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	var report Report
+	_, resp := retryRoundTrip(http.DefaultClient, u, prepareRequest(u), &report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(report.Attempts) != 2 {
+		t.Fatalf("got %s after %d attempts, want 200 OK after 2", resp.Status, len(report.Attempts))
+	}
+	if a := report.Attempts[0]; a.Status != "503 Service Unavailable" || a.RetryOn != "5xx" || a.Wait < 0 || a.Wait > 1 {
+		t.Errorf("first attempt = %+v", a)
+	}
+
+	// once out of retries, the last response is the result.
+	calls, retries = 0, 1
+	report = Report{}
+	_, resp = retryRoundTrip(http.DefaultClient, u, prepareRequest(u), &report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(report.Attempts) != 1 {
+		t.Errorf("got %s after %d attempts, want 503 after 1", resp.Status, len(report.Attempts))
+	}
+
+	// connection failures are retried, then fail the request.
+	defer func(r bool) { recoverFailures = r }(recoverFailures)
+	recoverFailures = true
+	ts.Close()
+	report = Report{}
+	defer func() {
+		if _, ok := recover().(*requestError); !ok || len(report.Attempts) != 1 || report.Attempts[0].RetryOn != "connect" {
+			t.Errorf("connection failure: attempts %+v", report.Attempts)
+		}
+	}()
+	retryRoundTrip(http.DefaultClient, u, prepareRequest(u), &report)
+	t.Error("the request didn't fail")
+}
+
+func TestRetryTotalTime(t *testing.T) {
+	defer func(n int, d time.Duration, on retryConditions, j bool, tt time.Duration) {
+		retries, retryDelay, retryOn, jsonOutput, totalTime = n, d, on, j, tt
+		runContext, stopRun = context.Background(), func() {}
+	}(retries, retryDelay, retryOn, jsonOutput, totalTime)
+	retries, retryDelay, jsonOutput, totalTime = 3, time.Minute, true, 100*time.Millisecond
+	retryOn.Set("5xx")
+	startRun()
+	defer stopRun()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	start := time.Now()
+	var report Report
+	if beforeDeadline(func() { retryRoundTrip(http.DefaultClient, u, prepareRequest(u), &report) }) {
+		t.Error("the retries finished after the total time was up")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("the backoff took %s, past the total time", d)
+	}
+}
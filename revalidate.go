@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Revalidation reports the outcome of repeating a request with the
+// validators of its response as conditional headers.
+type Revalidation struct {
+	Validator   string `json:",omitempty"`
+	Status      string `json:",omitempty"`
+	NotModified bool
+	Full        int    // total time of the full fetch, in milliseconds
+	Revalidated int    // total time of the conditional request
+	Error       string `json:",omitempty"`
+}
+
+// revalidate repeats the request for url conditionally on the ETag or
+// Last-Modified of resp, which took full to fetch.
+func revalidate(client *http.Client, url *url.URL, resp *http.Response, full Timing) *Revalidation {
+	r := &Revalidation{Full: full.Total}
+
+	req := newRequest(httpMethod, url, postBody)
+	switch {
+	case resp.Header.Get("ETag") != "":
+		req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+		r.Validator = "If-None-Match: " + resp.Header.Get("ETag")
+	case resp.Header.Get("Last-Modified") != "":
+		req.Header.Set("If-Modified-Since", resp.Header.Get("Last-Modified"))
+		r.Validator = "If-Modified-Since: " + resp.Header.Get("Last-Modified")
+	default:
+		r.Error = "no ETag or Last-Modified to revalidate with"
+		return r
+	}
+
+	var report Report
+	cresp := roundTrip(client, req, &report)
+	io.Copy(ioutil.Discard, cresp.Body)
+	cresp.Body.Close()
+	report.finish()
+
+	r.Status = cresp.Status
+	r.NotModified = cresp.StatusCode == http.StatusNotModified
+	r.Revalidated = report.Timing.Total
+	return r
+}
+
+func printRevalidation(r *Revalidation) {
+	label := labelString
+	if r.Error != "" {
+		printf("\n%s %s\n", label("Revalidation:"), warnString(r.Error))
+		return
+	}
+	result := okString(r.Status)
+	if !r.NotModified {
+		result = errorString(r.Status)
+	}
+	printf("\n%s %s %s\n", label("Revalidation:"), result, label("("+r.Validator+")"))
+	printf("   %s %s\n", label("full fetch:  "), valueString("%dms", r.Full))
+	printf("   %s %s %s\n", label("revalidation:"), valueString("%dms", r.Revalidated),
+		label("(%+dms)", r.Revalidated-r.Full))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// rawResponse writes a response to a file in HTTP/1.1 wire format, as
+// its body is read, for -save-raw.
+type rawResponse struct {
+	f       *os.File
+	body    io.Writer
+	chunked io.WriteCloser
+	resp    *http.Response
+}
+
+// createRawResponse creates filename and writes the status line and
+// headers of resp to it; the body is then written to the rawResponse.
+func createRawResponse(filename string, resp *http.Response) (*rawResponse, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &rawResponse{f: f, body: f, resp: resp}
+	fmt.Fprintf(f, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	if err := resp.Header.Write(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(resp.TransferEncoding) > 0 {
+		fmt.Fprintf(f, "Transfer-Encoding: %s\r\n", strings.Join(resp.TransferEncoding, ", "))
+		if resp.TransferEncoding[len(resp.TransferEncoding)-1] == "chunked" {
+			r.chunked = httputil.NewChunkedWriter(f)
+			r.body = r.chunked
+		}
+	}
+	_, err = io.WriteString(f, "\r\n")
+	return r, err
+}
+
+func (r *rawResponse) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// Close ends the body, with the trailers if it was chunked.
+func (r *rawResponse) Close() error {
+	if r.chunked != nil {
+		r.chunked.Close()
+		r.resp.Trailer.Write(r.f)
+		io.WriteString(r.f, "\r\n")
+	}
+	return r.f.Close()
+}
+
+// rawResponseFilename returns the file for the seq'th response, which
+// is numbered if there are to be n > 1 of them: out.http, out-2.http.
+func rawResponseFilename(filename string, seq, n int) string {
+	if n <= 1 {
+		return filename
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + strconv.Itoa(seq) + ext
+}
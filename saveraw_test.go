@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestRawResponse(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "out.http")
+	resp := &http.Response{
+		Status:           "200 OK",
+		ProtoMajor:       1,
+		ProtoMinor:       1,
+		Header:           http.Header{"Content-Type": {"text/plain"}},
+		TransferEncoding: []string{"chunked"},
+		Trailer:          http.Header{},
+	}
+	raw, err := createRawResponse(filename, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Write([]byte("hello"))
+	resp.Trailer.Set("X-Sum", "abc")
+	raw.Close()
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n0\r\nX-Sum: abc\r\n\r\n"
+	if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRawResponseFilename(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		seq, n   int
+		filename string
+	}{
+		{"out.http", 1, 1, "out.http"},
+		{"out.http", 1, 3, "out-1.http"},
+		{"dir/out", 2, 3, "dir/out-2"},
+	} {
+		if got := rawResponseFilename(tt.name, tt.seq, tt.n); got != tt.filename {
+			t.Errorf("rawResponseFilename(%q, %d, %d) = %q, want %q", tt.name, tt.seq, tt.n, got, tt.filename)
+		}
+	}
+}
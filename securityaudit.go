@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// minHSTSMaxAge is the shortest HSTS max-age considered adequate, 180 days.
+const minHSTSMaxAge = 180 * 24 * 60 * 60
+
+// SecurityAudit grades the security related response headers.
+type SecurityAudit struct {
+	Grade    string
+	Findings []SecurityFinding
+}
+
+// SecurityFinding is the result of checking one header: pass, warn or fail.
+type SecurityFinding struct {
+	Header string
+	Result string
+	Detail string `json:",omitempty"`
+}
+
+func auditSecurityHeaders(scheme string, h http.Header) *SecurityAudit {
+	var findings []SecurityFinding
+	check := func(header, result, detail string) {
+		findings = append(findings, SecurityFinding{Header: header, Result: result, Detail: detail})
+	}
+
+	hsts := h.Get("Strict-Transport-Security")
+	switch {
+	case scheme != "https":
+		check("Strict-Transport-Security", "fail", "served over plain HTTP")
+	case hsts == "":
+		check("Strict-Transport-Security", "fail", "missing")
+	default:
+		maxAge := -1
+		for _, d := range strings.Split(hsts, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(k, "max-age") {
+				maxAge, _ = strconv.Atoi(strings.Trim(v, `"`))
+			}
+		}
+		if maxAge < minHSTSMaxAge {
+			check("Strict-Transport-Security", "warn", fmt.Sprintf("max-age %d is less than 180 days", maxAge))
+		} else {
+			check("Strict-Transport-Security", "pass", "")
+		}
+	}
+
+	csp := h.Get("Content-Security-Policy")
+	switch {
+	case csp == "":
+		check("Content-Security-Policy", "fail", "missing")
+	case strings.Contains(csp, "'unsafe-inline'") || strings.Contains(csp, "'unsafe-eval'"):
+		check("Content-Security-Policy", "warn", "allows 'unsafe-inline' or 'unsafe-eval'")
+	default:
+		check("Content-Security-Policy", "pass", "")
+	}
+
+	if v := h.Get("X-Content-Type-Options"); strings.EqualFold(strings.TrimSpace(v), "nosniff") {
+		check("X-Content-Type-Options", "pass", "")
+	} else if v == "" {
+		check("X-Content-Type-Options", "fail", "missing")
+	} else {
+		check("X-Content-Type-Options", "fail", fmt.Sprintf("%q is not nosniff", v))
+	}
+
+	switch v := strings.ToUpper(strings.TrimSpace(h.Get("X-Frame-Options"))); {
+	case v == "DENY" || v == "SAMEORIGIN":
+		check("X-Frame-Options", "pass", "")
+	case strings.Contains(csp, "frame-ancestors"):
+		check("X-Frame-Options", "pass", "covered by CSP frame-ancestors")
+	case v == "":
+		check("X-Frame-Options", "fail", "missing")
+	default:
+		check("X-Frame-Options", "warn", fmt.Sprintf("%q is not DENY or SAMEORIGIN", v))
+	}
+
+	switch v := strings.ToLower(strings.TrimSpace(h.Get("Referrer-Policy"))); v {
+	case "":
+		check("Referrer-Policy", "fail", "missing")
+	case "unsafe-url", "no-referrer-when-downgrade":
+		check("Referrer-Policy", "warn", v+" leaks full URLs to other origins")
+	default:
+		check("Referrer-Policy", "pass", "")
+	}
+
+	if h.Get("Permissions-Policy") == "" {
+		check("Permissions-Policy", "fail", "missing")
+	} else {
+		check("Permissions-Policy", "pass", "")
+	}
+
+	var score float64
+	for _, f := range findings {
+		switch f.Result {
+		case "pass":
+			score++
+		case "warn":
+			score += 0.5
+		}
+	}
+	grade := "F"
+	switch n := float64(len(findings)); {
+	case score == n:
+		grade = "A"
+	case score >= n-1:
+		grade = "B"
+	case score >= n-2:
+		grade = "C"
+	case score >= n-3:
+		grade = "D"
+	}
+	return &SecurityAudit{Grade: grade, Findings: findings}
+}
+
+func printSecurityAudit(a *SecurityAudit) {
+	printf("\n%s %s\n", labelString("Security headers: grade"), valueString(a.Grade))
+	results := map[string]func(string, ...interface{}) string{
+		"pass": color.GreenString,
+		"warn": color.YellowString,
+		"fail": color.RedString,
+	}
+	for _, f := range a.Findings {
+		line := results[f.Result](f.Result)
+		if f.Detail != "" {
+			line += " " + labelString("("+f.Detail+")")
+		}
+		printf("  %s %s\n", labelString(f.Header+":"), line)
+	}
+}
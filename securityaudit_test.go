@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuditSecurityHeaders(t *testing.T) {
+	strict := http.Header{
+		"Strict-Transport-Security": {"max-age=63072000; includeSubDomains"},
+		"Content-Security-Policy":   {"default-src 'self'; frame-ancestors 'none'"},
+		"X-Content-Type-Options":    {"nosniff"},
+		"Referrer-Policy":           {"strict-origin-when-cross-origin"},
+		"Permissions-Policy":        {"geolocation=()"},
+	}
+	weak := http.Header{
+		"Strict-Transport-Security": {"max-age=300"},
+		"Content-Security-Policy":   {"script-src 'self' 'unsafe-inline'"},
+		"X-Content-Type-Options":    {"nosniff"},
+		"X-Frame-Options":           {"DENY"},
+	}
+
+	tests := []struct {
+		name   string
+		scheme string
+		header http.Header
+		grade  string
+		fails  int
+	}{
+		{"strict", "https", strict, "A", 0},
+		{"strict over http", "http", strict, "B", 1},
+		{"weak", "https", weak, "D", 2},
+		{"none", "https", http.Header{}, "F", 6},
+	}
+
+	for _, test := range tests {
+		a := auditSecurityHeaders(test.scheme, test.header)
+		var fails int
+		for _, f := range a.Findings {
+			if f.Result == "fail" {
+				fails++
+			}
+		}
+		if a.Grade != test.grade || fails != test.fails {
+			t.Errorf("%s: want grade %s with %d failures, got %s with %d: %+v",
+				test.name, test.grade, test.fails, a.Grade, fails, a.Findings)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ServerTimingMetric is one metric of a Server-Timing response header,
+// see https://www.w3.org/TR/server-timing/.
+type ServerTimingMetric struct {
+	Name        string
+	Duration    float64 `json:",omitempty"` // milliseconds
+	Description string  `json:",omitempty"`
+}
+
+// parseServerTiming parses the metrics of the Server-Timing headers.
+func parseServerTiming(values []string) []ServerTimingMetric {
+	var metrics []ServerTimingMetric
+	for _, v := range values {
+		for _, m := range splitQuoted(v, ',') {
+			params := splitQuoted(m, ';')
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			metric := ServerTimingMetric{Name: name}
+			for _, p := range params[1:] {
+				k, val, _ := strings.Cut(p, "=")
+				val = strings.TrimSpace(val)
+				if uq, err := strconv.Unquote(val); err == nil && strings.HasPrefix(val, `"`) {
+					val = uq
+				}
+				switch strings.ToLower(strings.TrimSpace(k)) {
+				case "dur":
+					metric.Duration, _ = strconv.ParseFloat(val, 64)
+				case "desc":
+					metric.Description = val
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+// splitQuoted splits s at sep, except where sep is inside a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// printServerTiming lists the server reported metrics below the
+// waterfall, against the measured server processing time.
+func printServerTiming(metrics []ServerTimingMetric, server int) {
+	width := 0
+	for _, m := range metrics {
+		width = max(width, len(m.Name))
+	}
+	printf("\n%s %s%s\n", labelString("Server-Timing (server processing"), valueString("%dms", server), labelString("):"))
+	for _, m := range metrics {
+		dur := ""
+		if m.Duration != 0 {
+			dur = strconv.FormatFloat(m.Duration, 'f', -1, 64) + "ms"
+		}
+		printf("  %-*s %s  %s\n", width, m.Name, valueString("%8s", dur), labelString(m.Description))
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseServerTiming(t *testing.T) {
+	got := parseServerTiming([]string{
+		`db;dur=53.2, cache;desc="Cache Read";dur=23.2, app`,
+		`edge; desc="a, \"quoted\"; desc"`,
+	})
+	want := []ServerTimingMetric{
+		{Name: "db", Duration: 53.2},
+		{Name: "cache", Duration: 23.2, Description: "Cache Read"},
+		{Name: "app"},
+		{Name: "edge", Description: `a, "quoted"; desc`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// showBodyMax is the most of a body -show-body prints.
+const showBodyMax = 64 << 10
+
+// cappedBuffer keeps the first max bytes written to it, counting the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	max   int
+	total int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// showable reports whether a body of contentType can be printed.
+func showable(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return isJSON(contentType) || strings.HasPrefix(mt, "text/") || mt == "application/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+// formatBody returns the captured body for printing, pretty-printed and
+// colorized if it is JSON, with a note of how much was left out.
+func formatBody(b *cappedBuffer, contentType string) string {
+	s := strings.TrimRight(b.String(), "\r\n")
+	if isJSON(contentType) {
+		var out bytes.Buffer
+		if err := json.Indent(&out, b.Bytes(), "", "  "); err == nil {
+			s = colorizeJSON(out.Bytes())
+		}
+	}
+	if rest := b.total - int64(b.Len()); rest > 0 {
+		s += "\n" + labelString("... %s more not shown", formatBytes(rest))
+	}
+	return s
+}
+
+// colorizeJSON colors the keys, strings, numbers and literals of a
+// valid JSON document.
+func colorizeJSON(b []byte) string {
+	key := color.New(color.FgBlue, color.Bold).SprintFunc()
+	str := color.New(color.FgGreen).SprintFunc()
+	num := color.New(color.FgCyan).SprintFunc()
+	lit := color.New(color.FgYellow).SprintFunc()
+
+	var out strings.Builder
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(b) && b[j] != '"'; j++ {
+				if b[j] == '\\' {
+					j++
+				}
+			}
+			j++
+			if j > len(b) {
+				j = len(b)
+			}
+			k := j
+			for k < len(b) && (b[k] == ' ' || b[k] == '\n') {
+				k++
+			}
+			if k < len(b) && b[k] == ':' {
+				out.WriteString(key(string(b[i:j])))
+			} else {
+				out.WriteString(str(string(b[i:j])))
+			}
+			i = j
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i
+			for j < len(b) && strings.IndexByte("+-.eE0123456789", b[j]) >= 0 {
+				j++
+			}
+			out.WriteString(num(string(b[i:j])))
+			i = j
+		case c == 't' || c == 'f' || c == 'n':
+			j := i
+			for j < len(b) && b[j] >= 'a' && b[j] <= 'z' {
+				j++
+			}
+			out.WriteString(lit(string(b[i:j])))
+			i = j
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
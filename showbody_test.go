@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{max: 5}
+	b.Write([]byte("abc"))
+	if n, _ := b.Write([]byte("defgh")); n != 5 {
+		t.Errorf("Write returned %d, want 5", n)
+	}
+	if b.String() != "abcde" || b.total != 8 {
+		t.Errorf("got %q of %d bytes, want \"abcde\" of 8", b.String(), b.total)
+	}
+}
+
+func TestFormatBody(t *testing.T) {
+	defer func(v bool) { color.NoColor = v }(color.NoColor)
+	color.NoColor = true
+
+	b := &cappedBuffer{max: 100}
+	b.Write([]byte(`{"a":[1,true],"b":"x"}`))
+	want := "{\n  \"a\": [\n    1,\n    true\n  ],\n  \"b\": \"x\"\n}"
+	if got := formatBody(b, "application/json"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	b = &cappedBuffer{max: 4}
+	b.Write([]byte("hello world\n"))
+	if got := formatBody(b, "text/plain"); got != "hell\n... 8B more not shown" {
+		t.Errorf("truncated body: got %q", got)
+	}
+}
+
+func TestColorizeJSON(t *testing.T) {
+	defer func(v bool) { color.NoColor = v }(color.NoColor)
+	color.NoColor = false
+
+	got := colorizeJSON([]byte(`{"k": "v\"", "n": -1.5e3, "z": null}`))
+	for _, want := range []string{
+		color.New(color.FgBlue, color.Bold).Sprint(`"k"`),
+		color.New(color.FgGreen).Sprint(`"v\""`),
+		color.New(color.FgCyan).Sprint(`-1.5e3`),
+		color.New(color.FgYellow).Sprint(`null`),
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q missing %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are the keys used to sign requests with -aws-sigv4.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// region and service requests are signed for, and the credentials to
+// sign them with, set from -aws-sigv4.
+var (
+	awsRegion  string
+	awsService string
+	awsCreds   *awsCredentials
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+
+	// sigV4Unsigned is signed in place of the hash of a body streamed
+	// with -chunked, which can't be read ahead of sending it.
+	sigV4Unsigned = "UNSIGNED-PAYLOAD"
+)
+
+// parseSigV4 splits the region/service argument to -aws-sigv4.
+func parseSigV4(s string) (region, service string, err error) {
+	i := strings.Index(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return "", "", fmt.Errorf("invalid -aws-sigv4 %q, expected region/service", s)
+	}
+	return s[:i], s[i+1:], nil
+}
+
+// loadAWSCredentials looks for credentials in the AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY environment variables, then in the shared
+// credentials file for the AWS_PROFILE profile.
+func loadAWSCredentials() (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			accessKeyID:     id,
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.New("no AWS credentials in the environment")
+		}
+		filename = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials in the environment or %s", filename)
+	}
+	defer f.Close()
+
+	var creds awsCredentials
+	var section string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "aws_access_key_id":
+			creds.accessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			creds.secretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			creds.sessionToken = strings.TrimSpace(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, fmt.Errorf("no credentials for profile %q in %s", profile, filename)
+	}
+	return &creds, nil
+}
+
+// signSigV4 adds an AWS Signature Version 4 Authorization header to req,
+// signing the host, all headers already set and the payload.
+func signSigV4(req *http.Request, creds *awsCredentials, region, service string, t time.Time) error {
+	payload, err := payloadHash(req)
+	if err != nil {
+		return fmt.Errorf("unable to hash request body: %v", err)
+	}
+
+	amzDate := t.UTC().Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	req.Header.Del("Authorization")
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.Join(strings.Fields(headers[k]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if service != "s3" {
+		// every service but S3 expects the path to be encoded twice.
+		path = awsEscape(path, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, s := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// payloadHash returns the hex SHA-256 of the request body, buffering
+// the body if it can't be read twice, or UNSIGNED-PAYLOAD if it is
+// streamed.
+func payloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return sha256Hex(nil), nil
+	}
+	if len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked" {
+		return sigV4Unsigned, nil
+	}
+	if req.GetBody == nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.ContentLength = int64(len(b))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalQuery returns the query string sorted by name then value,
+// with both encoded as SigV4 requires.
+func canonicalQuery(req *http.Request) string {
+	var params [][2]string
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			params = append(params, [2]string{awsEscape(k, true), awsEscape(v, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	q := make([]string, len(params))
+	for i, p := range params {
+		q[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(q, "&")
+}
+
+// awsEscape percent-encodes everything but the unreserved characters,
+// and '/' unless encodeSlash is set.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// vectors from the AWS Signature Version 4 test suite.
+	creds := &awsCredentials{
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	date := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signSigV4(req, creds, "us-east-1", "service", date); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		auth := req.Header.Get("Authorization")
+		if !strings.HasSuffix(auth, "Signature="+test.want) {
+			t.Errorf("%s: want signature %s, got %s", test.name, test.want, auth)
+		}
+	}
+}
+
+func TestSignSigV4Chunked(t *testing.T) {
+	creds := &awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "secret"}
+	body := ioutil.NopCloser(strings.NewReader("streamed"))
+	req, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", body)
+	setChunked(req)
+	if err := signSigV4(req, creds, "us-east-1", "s3", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != "UNSIGNED-PAYLOAD" {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want UNSIGNED-PAYLOAD", got)
+	}
+	if req.ContentLength != -1 || req.Body != body {
+		t.Errorf("the chunked body was read ahead: length %d", req.ContentLength)
+	}
+}
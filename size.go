@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Sizes counts the bytes exchanged, headers as HTTP/1.1 lines of
+// "Name: value" and the request line, or for HTTP/2 the request's header
+// fields, pseudo-headers included; HTTP/2 header compression and TLS
+// framing are not accounted for.
+type Sizes struct {
+	HeaderBytes int64   // response status line and headers
+	BodyBytes   int64   // response body, before any Content-Encoding is decoded
+	SentBytes   int64   // request line, headers and body
+	Throughput  float64 // MB/s of body received during the content transfer
+}
+
+// Upload is the time taken to send the request body, between writing
+// the request headers and the end of the request.
+type Upload struct {
+	Duration   int // milliseconds
+	Bytes      int64
+	Throughput float64 // MB/s
+}
+
+func newUpload(d time.Duration, n int64) *Upload {
+	u := &Upload{Duration: int(d / time.Millisecond), Bytes: n}
+	if secs := d.Seconds(); secs > 0 {
+		u.Throughput = float64(n) / 1e6 / secs
+	}
+	return u
+}
+
+// countingReader counts the bytes read through it into n.
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+// responseHeaderSize returns the size of the status line and headers
+// of resp.
+func responseHeaderSize(resp *http.Response) int64 {
+	var w byteCounter
+	fmt.Fprintf(&w, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&w)
+	w.Write([]byte("\r\n"))
+	return int64(w)
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// formatBytes formats n in B, KB, MB or GB using powers of 1000.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMG"[exp])
+}
+
+func printSizes(s Sizes) {
+	label := labelString
+	printf("\n%s %s %s %s %s %s %s\n",
+		label("Received:"), valueString(formatBytes(s.BodyBytes)), label("body +"),
+		valueString(formatBytes(s.HeaderBytes)), label("headers, sent"),
+		valueString(formatBytes(s.SentBytes)), label(fmt.Sprintf("(%.2f MB/s)", s.Throughput)))
+}
+
+func printUpload(u *Upload) {
+	printf("\n%s %s %s\n", labelString("Request Upload:"), valueString("%dms", u.Duration),
+		labelString(fmt.Sprintf("(%s, %.2f MB/s)", formatBytes(u.Bytes), u.Throughput)))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{999, "999B"},
+		{1500, "1.5KB"},
+		{1500000, "1.5MB"},
+		{2500000000, "2.5GB"},
+		{2500000000000, "2500.0GB"},
+	}
+	for _, test := range tests {
+		if got := formatBytes(test.n); got != test.want {
+			t.Errorf("%d: want %s, got %s", test.n, test.want, got)
+		}
+	}
+}
+
+func TestSentBytes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h1 := httptest.NewServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	field := func(k, v string) int64 { return int64(len(k) + len(v) + len(": \r\n")) }
+	for _, tt := range []struct {
+		ts   *httptest.Server
+		want int64
+	}{
+		{h1, int64(len("GET / HTTP/1.1\r\n\r\n")) + field("Host", h1.Listener.Addr().String()) +
+			field("User-Agent", "Go-http-client/1.1") + field("Accept-Encoding", "gzip")},
+		{h2, field(":authority", h2.Listener.Addr().String()) + field(":method", "GET") + field(":path", "/") +
+			field(":scheme", "https") + field("accept-encoding", "gzip") + field("user-agent", "Go-http-client/2.0")},
+	} {
+		req, _ := http.NewRequest("GET", tt.ts.URL+"/", nil)
+		var report Report
+		resp := roundTrip(tt.ts.Client(), req, &report)
+		resp.Body.Close()
+		if report.Size.SentBytes != tt.want {
+			t.Errorf("%s: sent %d bytes, want %d", resp.Proto, report.Size.SentBytes, tt.want)
+		}
+	}
+}
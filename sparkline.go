@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// sparkline draws values as a line of bars, scaled from zero to the
+// largest, at most width wide: longer runs are averaged into buckets.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		buckets := make([]float64, width)
+		for i := range buckets {
+			lo, hi := i*len(values)/width, (i+1)*len(values)/width
+			for _, v := range values[lo:hi] {
+				buckets[i] += v / float64(hi-lo)
+			}
+		}
+		values = buckets
+	}
+
+	levels := []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+	if asciiOutput {
+		levels = []string{"_", ".", ",", "-", "=", "+", "*", "#"}
+	}
+	top := 0.0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = int(math.Round(v / top * float64(len(levels)-1)))
+		}
+		b.WriteString(levels[i])
+	}
+	return b.String()
+}
+
+// printSparklines shows how long each phase took in turn over a run
+// of requests.
+func printSparklines(reports []Report, scheme string) {
+	width := 60
+	if terminalWidth > 0 {
+		width = max(terminalWidth-34, 10)
+	}
+	printf("\n%s\n", labelString("Each request in turn:"))
+	for _, p := range summaryPhases {
+		if p.name == "TLS Handshake" && scheme != "https" {
+			continue
+		}
+		times := phaseTimes(reports, p.timed)
+		printf("   %s %s %s\n", labelString("%-18s", p.name+":"), valueString(sparkline(times, width)),
+			labelString("max %.1fms", newStats(times).Max))
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestSparkline(t *testing.T) {
+	defer func(v bool) { asciiOutput = v }(asciiOutput)
+	asciiOutput = false
+	for _, tt := range []struct {
+		values []float64
+		width  int
+		want   string
+	}{
+		{[]float64{0, 3, 7, 3, 0}, 10, "▁▄█▄▁"},
+		{[]float64{0, 0, 0}, 10, "▁▁▁"},
+		{[]float64{5, 5}, 10, "██"},
+		{[]float64{0, 2, 7, 7, 2, 4}, 3, "▂█▄"},
+	} {
+		if got := sparkline(tt.values, tt.width); got != tt.want {
+			t.Errorf("sparkline(%v, %d) = %q, want %q", tt.values, tt.width, got, tt.want)
+		}
+	}
+
+	asciiOutput = true
+	if got := sparkline([]float64{0, 3, 7}, 10); got != "_-#" {
+		t.Errorf("with -ascii got %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// SSEStats reports the timing of the events of a text/event-stream
+// response read with -sse.
+type SSEStats struct {
+	Events     int
+	FirstEvent float64 // milliseconds from the start of the request
+	Gaps       Stats   // time between consecutive events
+}
+
+// readSSE parses the event stream in body, copying it to w, until
+// maxEvents have arrived, the stream ends or the user interrupts.
+func readSSE(body io.ReadCloser, w io.Writer, start time.Time, maxEvents int) *SSEStats {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupt:
+			body.Close()
+		case <-done:
+		}
+	}()
+
+	stats := &SSEStats{}
+	var gaps []float64
+	var last time.Time
+	var data bool
+	sc := bufio.NewScanner(io.TeeReader(body, w))
+	for sc.Scan() {
+		line := sc.Text()
+		if line != "" {
+			// comments, starting with a colon, are often sent as
+			// keep alives and aren't events.
+			if !strings.HasPrefix(line, ":") && (line == "data" || strings.HasPrefix(line, "data:")) {
+				data = true
+			}
+			continue
+		}
+		if !data {
+			continue
+		}
+		data = false
+
+		now := time.Now()
+		stats.Events++
+		if last.IsZero() {
+			stats.FirstEvent = float64(now.Sub(start)) / float64(time.Millisecond)
+		} else {
+			gaps = append(gaps, float64(now.Sub(last))/float64(time.Millisecond))
+		}
+		last = now
+		if textReport() {
+			printf("%s %s\n", labelString("event"), valueString("%d at %.1fms", stats.Events, float64(now.Sub(start))/float64(time.Millisecond)))
+		}
+		if maxEvents > 0 && stats.Events >= maxEvents {
+			break
+		}
+	}
+	stats.Gaps = newStats(gaps)
+	return stats
+}
+
+func printSSEStats(s *SSEStats) {
+	printf("\n%s %s\n", labelString("Server-Sent Events:"), valueString("%d", s.Events))
+	if s.Events == 0 {
+		return
+	}
+	printf("   %s %s\n", labelString("first event:"), valueString("%.1fms", s.FirstEvent))
+	if s.Gaps.Count > 0 {
+		printStats("between events:", s.Gaps)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Stats summarizes a set of latencies in milliseconds.
+type Stats struct {
+	Count int
+	Min   float64
+	Avg   float64
+	P50   float64
+	P95   float64
+	Max   float64
+}
+
+func newStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return Stats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Avg:   sum / float64(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the p-th percentile of sorted using the nearest
+// rank method.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+func printStats(label string, s Stats) {
+	ms := "%.1fms"
+	if timingPrecision == "us" {
+		ms = "%.3fms"
+	}
+	printf("   %s %s %s %s %s %s\n", labelString(label),
+		valueString("min "+ms, s.Min), valueString("avg "+ms, s.Avg),
+		valueString("p50 "+ms, s.P50), valueString("p95 "+ms, s.P95),
+		valueString("max "+ms, s.Max))
+}
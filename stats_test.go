@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestNewStats(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	got := newStats(values)
+	want := Stats{Count: 10, Min: 1, Avg: 5.5, P50: 5, P95: 10, Max: 10}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if got := newStats(nil); got != (Stats{}) {
+		t.Errorf("want zero stats for no values, got %+v", got)
+	}
+}
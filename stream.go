@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// StreamChunk is a piece of the response body as it arrived, At
+// milliseconds after the first response byte.
+type StreamChunk struct {
+	At    float64
+	Bytes int64
+}
+
+// streamTracer records when each read of the body returned data,
+// merging reads that arrive within a millisecond of each other.
+type streamTracer struct {
+	io.ReadCloser
+	start  time.Time
+	chunks []StreamChunk
+}
+
+func (t *streamTracer) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		at := float64(time.Since(t.start)) / float64(time.Millisecond)
+		if l := len(t.chunks); l > 0 && at-t.chunks[l-1].At < 1 {
+			t.chunks[l-1].Bytes += int64(n)
+		} else {
+			t.chunks = append(t.chunks, StreamChunk{At: at, Bytes: int64(n)})
+		}
+	}
+	return n, err
+}
+
+// maxTimelineRows limits the printed timeline, longer ones are merged
+// into equal time slices.
+const maxTimelineRows = 40
+
+func printStreamTrace(chunks []StreamChunk) {
+	if len(chunks) > maxTimelineRows {
+		slice := chunks[len(chunks)-1].At / maxTimelineRows
+		var merged []StreamChunk
+		for _, c := range chunks {
+			at := float64(int(c.At/slice)) * slice
+			if l := len(merged); l > 0 && merged[l-1].At == at {
+				merged[l-1].Bytes += c.Bytes
+				continue
+			}
+			merged = append(merged, StreamChunk{At: at, Bytes: c.Bytes})
+		}
+		chunks = merged
+	}
+
+	var largest int64
+	for _, c := range chunks {
+		largest = max(largest, c.Bytes)
+	}
+	printf("\n%s\n", labelString("Body arrival:"))
+	for _, c := range chunks {
+		bar := strings.Repeat(glyph("▇", "#"), max(1, int(20*c.Bytes/max(largest, 1))))
+		printf("  %s %s %s\n", valueString("%+10.1fms", c.At), valueString("%8s", formatBytes(c.Bytes)), labelString(bar))
+	}
+}
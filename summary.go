@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Summary summarizes the requests made with -n.
+type Summary struct {
+	Requests int
+	Status   map[string]int // the number of responses with each status
+	DNS      Stats
+	TCP      Stats
+	TLS      Stats
+	Server   Stats
+	Transfer Stats
+	Total    Stats
+}
+
+// summaryPhases are the phases a Summary covers, as named in the
+// waterfall.
+var summaryPhases = []struct {
+	name  string
+	timed func(Timing) int
+	stats func(*Summary) *Stats
+}{
+	{"DNS Lookup", func(t Timing) int { return t.DNS }, func(s *Summary) *Stats { return &s.DNS }},
+	{"TCP Connection", func(t Timing) int { return t.TCP }, func(s *Summary) *Stats { return &s.TCP }},
+	{"TLS Handshake", func(t Timing) int { return t.TLS }, func(s *Summary) *Stats { return &s.TLS }},
+	{"Server Processing", func(t Timing) int { return t.Server }, func(s *Summary) *Stats { return &s.Server }},
+	{"Content Transfer", func(t Timing) int { return t.Transfer }, func(s *Summary) *Stats { return &s.Transfer }},
+	{"Total", func(t Timing) int { return t.Total }, func(s *Summary) *Stats { return &s.Total }},
+}
+
+// summarize summarizes the reports of repeated requests.
+func summarize(reports []Report) *Summary {
+	s := &Summary{Requests: len(reports), Status: map[string]int{}}
+	for _, r := range reports {
+		s.Status[r.Status]++
+	}
+	for _, p := range summaryPhases {
+		*p.stats(s) = newStats(phaseTimes(reports, p.timed))
+	}
+	return s
+}
+
+// phaseTimes returns the milliseconds each report took in a phase.
+func phaseTimes(reports []Report, timed func(Timing) int) []float64 {
+	v := make([]float64, len(reports))
+	for i, r := range reports {
+		v[i] = float64(timed(r.Timing))
+		if r.TimingMicros != nil {
+			v[i] = float64(timed(*r.TimingMicros)) / 1000
+		}
+	}
+	return v
+}
+
+func printSummary(s *Summary, scheme string) {
+	label := labelString
+	statuses := make([]string, 0, len(s.Status))
+	for status := range s.Status {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for i, status := range statuses {
+		statuses[i] = valueString("%d %s %s", s.Status[status], glyph("×", "x"), status)
+	}
+	printf("\n%s %s\n", label("Summary of %d requests:", s.Requests), strings.Join(statuses, label(", ")))
+
+	for _, p := range summaryPhases {
+		if p.name == "TLS Handshake" && scheme != "https" {
+			continue
+		}
+		printStats(fmt.Sprintf("%-18s", p.name+":"), *p.stats(s))
+	}
+}
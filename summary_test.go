@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	var reports []Report
+	for i, total := range []int{30, 10, 20, 40} {
+		r := Report{Status: "200 OK"}
+		if i == 3 {
+			r.Status = "503 Service Unavailable"
+		}
+		r.Timing.Total = total
+		r.Timing.DNS = 1
+		reports = append(reports, r)
+	}
+
+	s := summarize(reports)
+	if s.Requests != 4 || s.Status["200 OK"] != 3 || s.Status["503 Service Unavailable"] != 1 {
+		t.Errorf("got %d requests, statuses %v", s.Requests, s.Status)
+	}
+	if want := (Stats{Count: 4, Min: 10, Avg: 25, P50: 20, P95: 40, Max: 40}); s.Total != want {
+		t.Errorf("Total: got %+v, want %+v", s.Total, want)
+	}
+	if s.DNS.Min != 1 || s.DNS.Max != 1 {
+		t.Errorf("DNS: got %+v", s.DNS)
+	}
+}
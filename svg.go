@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// The layout of an -svg waterfall, in pixels.
+const (
+	svgWidth  = 900
+	svgLabels = 280 // the width of the column naming each hop
+	svgRow    = 28
+	svgBar    = 16
+	svgTop    = 40 // room for the legend
+	svgBottom = 30 // room for the axis
+	svgRight  = 60 // room for the total of the last hop
+)
+
+// svgPhases are the phases drawn, with their colors.
+var svgPhases = []struct {
+	name, color string
+	timed       func(Timing) int
+}{
+	{"DNS Lookup", "#77bb55", func(t Timing) int { return t.DNS }},
+	{"TCP Connection", "#ee9933", func(t Timing) int { return t.TCP }},
+	{"TLS Handshake", "#aa66cc", func(t Timing) int { return t.TLS }},
+	{"Server Processing", "#4488cc", func(t Timing) int { return t.Server }},
+	{"Content Transfer", "#44bbbb", func(t Timing) int { return t.Transfer }},
+}
+
+// axisStep returns the interval between ticks of an axis of total
+// length, a 1, 2 or 5 times a power of ten giving at most ticks ticks.
+func axisStep(total float64, ticks int) float64 {
+	if total <= 0 {
+		return 1
+	}
+	step := math.Pow(10, math.Floor(math.Log10(total/float64(ticks))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if total/(step*m) <= float64(ticks) {
+			return step * m
+		}
+	}
+	return step * 10
+}
+
+// waterfallSVG draws the phases of each hop of a redirect chain, one
+// after the other as they happened, on a common time axis.
+func waterfallSVG(chain []hop) string {
+	totalUs := 0
+	for _, h := range chain {
+		totalUs += h.report.micros.Total
+	}
+	total := math.Max(float64(totalUs)/1000, 1) // milliseconds
+	plot := float64(svgWidth - svgLabels - svgRight)
+	x := func(ms float64) float64 { return svgLabels + plot*ms/total }
+	height := svgTop + svgRow*len(chain) + svgBottom
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		svgWidth, height, svgWidth, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", svgWidth, height)
+
+	// the legend
+	tls := false
+	for _, h := range chain {
+		tls = tls || strings.HasPrefix(h.url, "https:")
+	}
+	lx := 10
+	for _, p := range svgPhases {
+		if p.name == "TLS Handshake" && !tls {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="12" width="10" height="10" fill="%s"/><text x="%d" y="21">%s</text>`+"\n",
+			lx, p.color, lx+14, p.name)
+		lx += 14 + 7*len(p.name) + 16
+	}
+
+	// the axis, with a grid line at each tick
+	bottom := svgTop + svgRow*len(chain)
+	step := axisStep(total, 8)
+	for i := 0; float64(i)*step <= total; i++ {
+		t := float64(i) * step
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#dddddd"/>`+"\n", x(t), svgTop-4, x(t), bottom)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" fill="#666666">%sms</text>`+"\n",
+			x(t), bottom+16, trimFloat(t))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999999"/>`+"\n", svgLabels, bottom, svgWidth-svgRight, bottom)
+
+	offset := 0.0 // when the hop started
+	for i, h := range chain {
+		y := svgTop + svgRow*i
+		label := h.url
+		if len(label) > 40 {
+			label = label[:39] + "…"
+		}
+		fmt.Fprintf(&b, `<text x="10" y="%d"><title>%s</title>%s <tspan fill="#666666">%s</tspan></text>`+"\n",
+			y+svgBar-3, html.EscapeString(h.url), html.EscapeString(label), html.EscapeString(statusCode(h.report.Status)))
+		start := offset
+		for _, p := range svgPhases {
+			us := p.timed(h.report.micros)
+			if us == 0 {
+				continue
+			}
+			ms := float64(us) / 1000
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>%s %s</title></rect>`+"\n",
+				x(start), y+2, math.Max(x(start+ms)-x(start), 0.5), svgBar, p.color, p.name, formatTiming(us))
+			start += ms
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="#666666">%s</text>`+"\n",
+			x(start)+4, y+svgBar-3, formatTiming(h.report.micros.Total))
+		offset += float64(h.report.micros.Total) / 1000
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// trimFloat formats f without trailing zeros.
+func trimFloat(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", f), "0"), ".")
+}
+
+// writeWaterfallSVG writes the waterfall of the redirect chain to
+// filename.
+func writeWaterfallSVG(filename string, chain []hop) error {
+	return ioutil.WriteFile(filename, []byte(waterfallSVG(chain)), 0644)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAxisStep(t *testing.T) {
+	for _, tt := range []struct {
+		total float64
+		want  float64
+	}{
+		{1.4, 0.2},
+		{100, 20},
+		{120, 20},
+		{8, 1},
+		{1000, 200},
+		{0, 1},
+	} {
+		if got := axisStep(tt.total, 8); got != tt.want {
+			t.Errorf("axisStep(%v, 8) = %v, want %v", tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestWaterfallSVG(t *testing.T) {
+	chain := []hop{
+		{"http://example.com/", Report{Status: "301 Moved Permanently",
+			micros: Timing{DNS: 10000, TCP: 10000, Server: 20000, Total: 40000}}},
+		{"https://example.com/<new>", Report{Status: "200 OK",
+			micros: Timing{TCP: 10000, TLS: 20000, Server: 20000, Transfer: 10000, Total: 60000}}},
+	}
+	svg := waterfallSVG(chain)
+
+	// it must be well formed XML.
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, svg)
+		}
+	}
+
+	// the second hop starts after the first, 40ms into 100ms.
+	if !strings.Contains(svg, `<rect x="504.0" y="70" width="56.0" height="16" fill="#ee9933"><title>TCP Connection 10ms</title></rect>`) {
+		t.Errorf("second hop not drawn after the first:\n%s", svg)
+	}
+	if !strings.Contains(svg, "TLS Handshake</text>") {
+		t.Error("TLS handshake missing from the legend")
+	}
+	if strings.Count(svg, "<rect") != 1+5+3+4 {
+		t.Errorf("got %d rects, want the background, 5 in the legend and 7 phases", strings.Count(svg, "<rect"))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// syslogFacilities and syslogSeverities number the syslog facilities
+// and severities by name, as in RFC 5424.
+var (
+	syslogFacilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+		"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+	syslogSeverities = map[string]int{
+		"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+		"warning": 4, "notice": 5, "info": 6, "debug": 7,
+	}
+)
+
+// syslogFlag is -syslog, which logs to the local syslog daemon, or with
+// -syslog=ADDRESS to a remote one, as host[:port] over UDP or prefixed
+// with tcp:// or udp://.
+type syslogFlag struct {
+	enabled       bool
+	network, addr string
+}
+
+func (f *syslogFlag) IsBoolFlag() bool { return true }
+
+func (f *syslogFlag) String() string {
+	switch {
+	case f == nil || !f.enabled:
+		return ""
+	case f.addr == "":
+		return "true"
+	}
+	return f.network + "://" + f.addr
+}
+
+func (f *syslogFlag) Set(v string) error {
+	switch v {
+	case "true":
+		*f = syslogFlag{enabled: true}
+		return nil
+	case "false":
+		*f = syslogFlag{}
+		return nil
+	}
+	network, addr := "udp", v
+	if u, err := url.Parse(v); err == nil && u.Host != "" {
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return fmt.Errorf("unsupported syslog network %q, must be udp or tcp", u.Scheme)
+		}
+		network, addr = u.Scheme, u.Host
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "514")
+	}
+	*f = syslogFlag{enabled: true, network: network, addr: addr}
+	return nil
+}
+
+// syslogPriority combines a facility and severity given by name.
+func syslogPriority(facility, severity string) (int, error) {
+	f, ok := syslogFacilities[facility]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	s, ok := syslogSeverities[severity]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog severity %q", severity)
+	}
+	return f<<3 | s, nil
+}
+
+// syslogLine formats the report of a request to u as key=value pairs,
+// with the timings in milliseconds.
+func syslogLine(u *url.URL, r *Report) string {
+	t := r.Timing
+	fields := []string{
+		"url=" + u.String(),
+		"status=" + statusCode(r.Status),
+		"address=" + r.Address,
+		fmt.Sprintf("dns=%d tcp=%d", t.DNS, t.TCP),
+	}
+	if u.Scheme == "https" {
+		fields = append(fields, fmt.Sprintf("tls=%d", t.TLS))
+	}
+	fields = append(fields,
+		fmt.Sprintf("server=%d transfer=%d total=%d", t.Server, t.Transfer, t.Total),
+		fmt.Sprintf("bytes=%d", r.Size.BodyBytes))
+	for _, w := range r.Warnings {
+		fields = append(fields, fmt.Sprintf("warning=%q", w))
+	}
+	return strings.Join(fields, " ")
+}
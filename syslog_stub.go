@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog is unavailable on systems without log/syslog.
+func dialSyslog(network, addr string, priority int) (io.Writer, error) {
+	return nil, errors.New("syslog is not supported on this system")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSyslogFlag(t *testing.T) {
+	for _, tt := range []struct {
+		v, network, addr string
+	}{
+		{"true", "", ""},
+		{"logs.example.com", "udp", "logs.example.com:514"},
+		{"logs.example.com:1514", "udp", "logs.example.com:1514"},
+		{"tcp://logs.example.com", "tcp", "logs.example.com:514"},
+		{"udp://[::1]:1514", "udp", "[::1]:1514"},
+	} {
+		var f syslogFlag
+		if err := f.Set(tt.v); err != nil {
+			t.Errorf("%q: %v", tt.v, err)
+			continue
+		}
+		if !f.enabled || f.network != tt.network || f.addr != tt.addr {
+			t.Errorf("%q: got %+v", tt.v, f)
+		}
+	}
+	var f syslogFlag
+	if err := f.Set("https://logs.example.com"); err == nil {
+		t.Error("https accepted")
+	}
+}
+
+func TestSyslogPriority(t *testing.T) {
+	if p, err := syslogPriority("local3", "notice"); err != nil || p != 157 {
+		t.Errorf("got %d, %v", p, err)
+	}
+	if _, err := syslogPriority("user", "loud"); err == nil {
+		t.Error("unknown severity accepted")
+	}
+}
+
+func TestSyslogLine(t *testing.T) {
+	u, _ := url.Parse("https://example.com/health")
+	r := &Report{
+		Status:  "503 Service Unavailable",
+		Address: "93.184.216.34:443",
+		Timing:  Timing{DNS: 1, TCP: 2, TLS: 3, Server: 4, Transfer: 5, Total: 15},
+	}
+	r.Size.BodyBytes = 42
+	want := "url=https://example.com/health status=503 address=93.184.216.34:443 dns=1 tcp=2 tls=3 server=4 transfer=5 total=15 bytes=42"
+	if got := syslogLine(u, r); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
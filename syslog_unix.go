@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// dialSyslog connects to the syslog daemon at addr over network, or
+// the local one if addr is empty, to log with priority.
+func dialSyslog(network, addr string, priority int) (io.Writer, error) {
+	return syslog.Dial(network, addr, syslog.Priority(priority), "httpstat")
+}
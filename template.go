@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// requestSeq numbers the requests made with -n, from 1, for the
+	// {{sequence}} placeholder.
+	requestSeq = 1
+
+	// dataRows are the rows of the -data-source file, and templateVars
+	// the columns of the one for the current request.
+	dataRows     []map[string]string
+	templateVars map[string]string
+
+	// extractedVars are the values named by -extract name=PATH in the
+	// responses so far.
+	extractedVars = map[string]string{}
+)
+
+// placeholder matches the template placeholders expanded in the URL,
+// headers and body of each request. Unknown names are left alone.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.-]*)(\s+\d+)?\s*\}\}`)
+
+// setSequence numbers the next request n, taking the next row of the
+// -data-source file, from the start again once they have all been used.
+func setSequence(n int) {
+	requestSeq = n
+	if len(dataRows) > 0 {
+		templateVars = dataRows[(n-1)%len(dataRows)]
+	}
+}
+
+// expandTemplate replaces the placeholders in s:
+//
+//	{{sequence}}   the number of the request, from 1
+//	{{uuid}}       a random version 4 UUID
+//	{{timestamp}}  the Unix time in milliseconds
+//	{{rand N}}     a random integer from 0 to N-1
+//	{{column}}     the column of the current -data-source row
+//	{{name}}       the value extracted from an earlier response by -extract
+func expandTemplate(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		sm := placeholder.FindStringSubmatch(m)
+		name, arg := sm[1], strings.TrimSpace(sm[2])
+		if v, ok := templateVars[name]; ok && arg == "" {
+			return v
+		}
+		if v, ok := extractedVars[name]; ok && arg == "" {
+			return v
+		}
+		switch {
+		case name == "sequence" && arg == "":
+			return strconv.Itoa(requestSeq)
+		case name == "uuid" && arg == "":
+			return newUUID()
+		case name == "timestamp" && arg == "":
+			return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		case name == "rand" && arg != "":
+			n, ok := new(big.Int).SetString(arg, 10)
+			if !ok || n.Sign() <= 0 {
+				return "0"
+			}
+			r, _ := rand.Int(rand.Reader, n)
+			return r.String()
+		default:
+			return m
+		}
+	})
+}
+
+// expandURL expands the placeholders in the path and query of u.
+func expandURL(u *url.URL) *url.URL {
+	if !strings.Contains(u.Path, "{{") && !strings.Contains(u.RawQuery, "{{") {
+		// leave the escaping of the path as it was given.
+		return u
+	}
+	e := *u
+	e.Path, e.RawPath = expandTemplate(u.Path), ""
+	e.RawQuery = expandTemplate(u.RawQuery)
+	return &e
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
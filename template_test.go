@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	defer func(n int) { requestSeq = n }(requestSeq)
+	requestSeq = 7
+
+	if got := expandTemplate("id={{sequence}}&n={{ sequence }}"); got != "id=7&n=7" {
+		t.Errorf("sequence: got %q", got)
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if a, b := expandTemplate("{{uuid}}"), expandTemplate("{{uuid}}"); !uuid.MatchString(a) || a == b {
+		t.Errorf("uuid: got %q and %q", a, b)
+	}
+	if ts, err := strconv.ParseInt(expandTemplate("{{timestamp}}"), 10, 64); err != nil || ts < 1e12 {
+		t.Errorf("timestamp: got %d, %v", ts, err)
+	}
+	for i := 0; i < 20; i++ {
+		if n, err := strconv.Atoi(expandTemplate("{{rand 3}}")); err != nil || n < 0 || n > 2 {
+			t.Fatalf("rand 3: got %d, %v", n, err)
+		}
+	}
+	// other braces, as in JSON or mustache templates, are left alone.
+	for _, s := range []string{`{"a":{"b":1}}`, "{{name}}", "{{rand}}"} {
+		if got := expandTemplate(s); got != s {
+			t.Errorf("expandTemplate(%q) = %q", s, got)
+		}
+	}
+}
+
+func TestExpandURL(t *testing.T) {
+	defer func(n int) { requestSeq = n }(requestSeq)
+	requestSeq = 3
+
+	u, _ := url.Parse("http://example.com/items/{{sequence}}?page={{sequence}}")
+	if got := expandURL(u).String(); got != "http://example.com/items/3?page=3" {
+		t.Errorf("got %s", got)
+	}
+
+	// the escaping of URLs without placeholders is kept.
+	u, _ = url.Parse("http://example.com/a%2Fb")
+	if got := expandURL(u).String(); got != "http://example.com/a%2Fb" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// The functions output is colored with, by what is being printed. The
+// theme chosen with -theme and the [colors] config table set them.
+var (
+	valueString  = color.CyanString   // measurements and header values
+	labelString  = grayscale(14)      // the names of measurements
+	okString     = color.GreenString  // good news
+	warnString   = color.YellowString // warnings
+	errorString  = color.RedString    // errors and failed checks
+	statusString = color.CyanString   // the status line
+	headerString = grayscale(14)      // response header names
+)
+
+// themeRoles maps the names of the roles in a theme to the functions
+// printing in them.
+var themeRoles = map[string]*func(string, ...interface{}) string{
+	"value":   &valueString,
+	"label":   &labelString,
+	"ok":      &okString,
+	"warning": &warnString,
+	"error":   &errorString,
+	"status":  &statusString,
+	"header":  &headerString,
+}
+
+// themes are the preset themes, overriding the colors of the default.
+var themes = map[string]map[string]string{
+	"default": {},
+	"high-contrast": {
+		"value":   "bold bright-cyan",
+		"label":   "bright-white",
+		"ok":      "bold bright-green",
+		"warning": "bold bright-yellow",
+		"error":   "bold bright-red",
+		"status":  "bold bright-white",
+		"header":  "bold bright-white",
+	},
+	"light": {
+		"value":   "blue",
+		"label":   "240",
+		"ok":      "green",
+		"warning": "130",
+		"error":   "bold red",
+		"status":  "bold blue",
+		"header":  "240",
+	},
+}
+
+// themeColors are the colors of the [colors] config table, by role.
+var themeColors map[string]string
+
+// applyTheme sets the colors of the named preset theme, then those of
+// colors.
+func applyTheme(name string, colors map[string]string) error {
+	preset, ok := themes[name]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for n := range themes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown theme %q, choose from %s", name, strings.Join(names, ", "))
+	}
+	for _, c := range []map[string]string{preset, colors} {
+		for role, spec := range c {
+			f, ok := themeRoles[role]
+			if !ok {
+				return fmt.Errorf("unknown color role %q", role)
+			}
+			attrs, err := parseColorSpec(spec)
+			if err != nil {
+				return fmt.Errorf("%s color: %v", role, err)
+			}
+			*f = color.New(attrs...).SprintfFunc()
+		}
+	}
+	return nil
+}
+
+// colorNames are the basic terminal colors, in attribute order.
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// parseColorSpec parses a color such as "bold red", "bright-cyan",
+// "white on-blue" or a 256 color palette number like "240".
+func parseColorSpec(spec string) ([]color.Attribute, error) {
+	var attrs []color.Attribute
+	for _, word := range strings.Fields(strings.ReplaceAll(spec, ",", " ")) {
+		switch word {
+		case "bold":
+			attrs = append(attrs, color.Bold)
+			continue
+		case "faint":
+			attrs = append(attrs, color.Faint)
+			continue
+		case "italic":
+			attrs = append(attrs, color.Italic)
+			continue
+		case "underline":
+			attrs = append(attrs, color.Underline)
+			continue
+		case "default", "none":
+			continue
+		}
+		if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+			attrs = append(attrs, 38, 5, color.Attribute(n))
+			continue
+		}
+
+		name, base := word, color.FgBlack
+		switch {
+		case strings.HasPrefix(name, "on-bright-"):
+			name, base = strings.TrimPrefix(name, "on-bright-"), color.BgHiBlack
+		case strings.HasPrefix(name, "on-"):
+			name, base = strings.TrimPrefix(name, "on-"), color.BgBlack
+		case strings.HasPrefix(name, "bright-"):
+			name, base = strings.TrimPrefix(name, "bright-"), color.FgHiBlack
+		}
+		i := indexOf(colorNames, name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown color %q", word)
+		}
+		attrs = append(attrs, base+color.Attribute(i))
+	}
+	return attrs, nil
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
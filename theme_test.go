@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestParseColorSpec(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		want []color.Attribute
+	}{
+		{"red", []color.Attribute{color.FgRed}},
+		{"bold bright-cyan", []color.Attribute{color.Bold, color.FgHiCyan}},
+		{"white, on-blue", []color.Attribute{color.FgWhite, color.BgBlue}},
+		{"on-bright-black", []color.Attribute{color.BgHiBlack}},
+		{"240 underline", []color.Attribute{38, 5, 240, color.Underline}},
+		{"default", nil},
+	} {
+		got, err := parseColorSpec(tt.spec)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseColorSpec(%q) = %v, %v, want %v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"purple", "256", "bright-"} {
+		if _, err := parseColorSpec(spec); err == nil {
+			t.Errorf("parseColorSpec(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	defer func(v, e func(string, ...interface{}) string, nc bool) {
+		valueString, errorString, color.NoColor = v, e, nc
+	}(valueString, errorString, color.NoColor)
+	color.NoColor = false
+
+	if err := applyTheme("light", map[string]string{"error": "magenta"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := valueString("x"), color.New(color.FgBlue).Sprint("x"); got != want {
+		t.Errorf("value: got %q, want %q", got, want)
+	}
+	if got, want := errorString("x"), color.New(color.FgMagenta).Sprint("x"); got != want {
+		t.Errorf("error: got %q, want %q", got, want)
+	}
+
+	if err := applyTheme("dark", nil); err == nil {
+		t.Errorf("unknown theme accepted")
+	}
+	if err := applyTheme("default", map[string]string{"background": "red"}); err == nil {
+		t.Errorf("unknown role accepted")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"time"
+)
+
+// describeTimeout says which phase of a request timed out with err, and
+// the flag setting how long it may take, or returns "" if err isn't one
+// of those timeouts.
+func describeTimeout(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	msg := err.Error()
+	switch {
+	case !os.IsTimeout(err):
+		return ""
+	case strings.Contains(msg, "TLS handshake timeout"):
+		return fmt.Sprintf("TLS handshake timeout: no handshake within %s (-tls-timeout)", tlsTimeout)
+	case strings.Contains(msg, "timeout awaiting response headers"):
+		return fmt.Sprintf("response header timeout: no response within %s of sending the request (-response-header-timeout)", responseHeaderTimeout)
+	case errors.As(err, &dnsErr) && dnsTimeout > 0:
+		return fmt.Sprintf("DNS timeout: %s not resolved within %s (-dns-timeout)", dnsErr.Name, dnsTimeout)
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("DNS timeout: %s not resolved within %s (-connect-timeout, or set -dns-timeout)", dnsErr.Name, connectTimeout)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Sprintf("connect timeout: not connected to %v within %s (-connect-timeout)", opErr.Addr, connectTimeout)
+	case strings.Contains(msg, "Client.Timeout exceeded"):
+		return fmt.Sprintf("timeout: the request took longer than %s (-m)", maxTime)
+	}
+	return ""
+}
+
+// lookupAddrs resolves the host of addr, a host:port, to the addresses
+// to dial on network, failing with a DNS timeout if that takes longer
+// than timeout.
+func lookupAddrs(ctx context.Context, network, addr string, timeout time.Duration) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+	// as the dialer does, trace the lookup but not the connections to
+	// the name servers it makes.
+	lookupCtx := context.Background()
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil {
+		lookupCtx = httptrace.WithClientTrace(lookupCtx, &httptrace.ClientTrace{DNSStart: trace.DNSStart, DNSDone: trace.DNSDone})
+	}
+	lookupCtx, cancel := context.WithTimeout(lookupCtx, timeout)
+	defer cancel()
+	defer context.AfterFunc(ctx, cancel)()
+	ipNetwork := map[string]string{"tcp4": "ip4", "tcp6": "ip6"}[network]
+	if ipNetwork == "" {
+		ipNetwork = "ip"
+	}
+	ips, err := net.DefaultResolver.LookupIP(lookupCtx, ipNetwork, host)
+	if err != nil {
+		if lookupCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, &net.DNSError{Err: "timeout", Name: host, IsTimeout: true}
+		}
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip.String(), port)
+	}
+	return addrs, nil
+}
+
+// minDialTimeout is the least time dialAddrs gives an address, unless
+// there's less left, as net.Dialer does.
+const minDialTimeout = 2 * time.Second
+
+// dialAddrs dials addrs in turn until one connects, splitting what is left
+// of the timeout of d between the addresses not yet tried, as net.Dialer
+// does, so that an address that doesn't answer can't use it all.
+func dialAddrs(ctx context.Context, d *net.Dialer, network string, addrs []string) (net.Conn, error) {
+	var deadline time.Time
+	if d.Timeout > 0 {
+		deadline = time.Now().Add(d.Timeout)
+	}
+	if dl, ok := ctx.Deadline(); ok && (deadline.IsZero() || dl.Before(deadline)) {
+		deadline = dl
+	}
+	var firstErr error
+	for i, a := range addrs {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !deadline.IsZero() {
+			dialCtx, cancel = context.WithDeadline(ctx, partialDeadline(time.Now(), deadline, len(addrs)-i))
+		}
+		conn, err := d.DialContext(dialCtx, network, a)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// partialDeadline returns the deadline to dial one of the remaining
+// addresses by, for them all to be dialed by deadline.
+func partialDeadline(now, deadline time.Time, remaining int) time.Time {
+	left := deadline.Sub(now)
+	timeout := left / time.Duration(remaining)
+	if timeout < minDialTimeout {
+		timeout = min(left, minDialTimeout)
+	}
+	return now.Add(timeout)
+}
+
+var (
+	// runContext is done once the -total-time of the run is up.
+	runContext = context.Background()
+	stopRun    = func() {}
+)
+
+// startRun starts the -total-time of the run.
+func startRun() {
+	if totalTime > 0 {
+		runContext, stopRun = context.WithTimeout(context.Background(), totalTime)
+	}
+}
+
+// pause waits d between the requests of a run, returning false if the
+// -total-time of the run is up first.
+func pause(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-runContext.Done():
+		return false
+	}
+}
+
+// beforeDeadline makes a request with f, returning false if the
+// -total-time of the run was up before it finished rather than failing
+// the request.
+func beforeDeadline(f func()) (finished bool) {
+	if totalTime <= 0 {
+		f()
+		return true
+	}
+	defer func(prev bool) {
+		recoverFailures = prev
+		if r := recover(); r != nil {
+			e, ok := r.(*requestError)
+			if !ok {
+				panic(r)
+			}
+			if runContext.Err() == nil {
+				failRequest(e.err, "%s", e.msg)
+			}
+			finished = false
+		}
+	}(recoverFailures)
+	recoverFailures = true
+	f()
+	// a body read to be discarded stops without failing.
+	return runContext.Err() == nil
+}
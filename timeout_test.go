@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timeoutError is an error whose Timeout is true.
+type timeoutError string
+
+func (e timeoutError) Error() string { return string(e) }
+func (e timeoutError) Timeout() bool { return true }
+
+func TestDescribeTimeout(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Net: "tcp", Addr: addr, Err: timeoutError("i/o timeout")}},
+			"connect timeout: not connected to 192.0.2.1:443 within"},
+		{&url.Error{Op: "Get", Err: timeoutError("net/http: TLS handshake timeout")}, "TLS handshake timeout"},
+		{&url.Error{Op: "Get", Err: timeoutError("net/http: timeout awaiting response headers")}, "response header timeout"},
+		{&url.Error{Op: "Get", Err: timeoutError("context deadline exceeded (Client.Timeout exceeded while awaiting headers)")}, "(-m)"},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "timeout", Name: "example.com", IsTimeout: true}}},
+			"DNS timeout: example.com not resolved within"},
+		{&url.Error{Op: "Get", Err: errors.New("connection refused")}, ""},
+	} {
+		got := describeTimeout(tt.err)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("describeTimeout(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	defer func(d time.Duration) { responseHeaderTimeout = d }(responseHeaderTimeout)
+	responseHeaderTimeout = 20 * time.Millisecond
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: &http.Transport{ResponseHeaderTimeout: responseHeaderTimeout}}
+	_, err := client.Get(ts.URL)
+	if err == nil || !strings.HasPrefix(describeTimeout(err), "response header timeout") {
+		t.Errorf("got %v, described as %q", err, describeTimeout(err))
+	}
+}
+
+func TestLookupAddrsTimeout(t *testing.T) {
+	// a name server that never answers.
+	stalled, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	defer func(r *net.Resolver) { net.DefaultResolver = r }(net.DefaultResolver)
+	net.DefaultResolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "udp", stalled.LocalAddr().String())
+	}}
+
+	if addrs, err := lookupAddrs(context.Background(), "tcp", "192.0.2.1:80", time.Millisecond); err != nil || addrs[0] != "192.0.2.1:80" {
+		t.Errorf("lookupAddrs of an address = %q, %v", addrs, err)
+	}
+	start := time.Now()
+	_, err = lookupAddrs(context.Background(), "tcp", "stalled.example:80", 50*time.Millisecond)
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsTimeout || dnsErr.Name != "stalled.example" {
+		t.Errorf("got %v, want a DNS timeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("timed out after %s", d)
+	}
+}
+
+func TestTotalTime(t *testing.T) {
+	defer func(d time.Duration, r bool) {
+		totalTime, recoverFailures = d, r
+		runContext, stopRun = context.Background(), func() {}
+	}(totalTime, recoverFailures)
+	totalTime, recoverFailures = 50*time.Millisecond, true
+	startRun()
+	defer stopRun()
+
+	if !beforeDeadline(func() {}) || !pause(time.Millisecond) {
+		t.Error("the run stopped before its total time was up")
+	}
+	// failures before the deadline still fail the request.
+	failed := func() (failed bool) {
+		defer func() { _, failed = recover().(*requestError) }()
+		beforeDeadline(func() { failRequest(errors.New("connection refused"), "unable to connect") })
+		return false
+	}()
+	if !failed {
+		t.Error("a failure before the deadline didn't fail the request")
+	}
+
+	if pause(time.Second) {
+		t.Error("the pause outlasted the total time")
+	}
+	if beforeDeadline(func() { failRequest(context.DeadlineExceeded, "failed to read response") }) {
+		t.Error("a request cut short by the deadline finished")
+	}
+}
+
+func TestPartialDeadline(t *testing.T) {
+	now := time.Unix(0, 0)
+	for _, tt := range []struct {
+		left      time.Duration
+		remaining int
+		want      time.Duration
+	}{
+		{30 * time.Second, 3, 10 * time.Second},
+		{30 * time.Second, 1, 30 * time.Second},
+		{3 * time.Second, 3, 2 * time.Second},
+		{time.Second, 3, time.Second},
+	} {
+		if got := partialDeadline(now, now.Add(tt.left), tt.remaining).Sub(now); got != tt.want {
+			t.Errorf("%s left for %d addresses: %s each, want %s", tt.left, tt.remaining, got, tt.want)
+		}
+	}
+}
+
+func TestDialAddrs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// a port nothing listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := closed.Addr().String()
+	closed.Close()
+
+	d := &net.Dialer{Timeout: time.Second}
+	conn, err := dialAddrs(context.Background(), d, "tcp", []string{refused, l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if _, err := dialAddrs(context.Background(), d, "tcp", []string{refused}); err == nil {
+		t.Error("dialed an address nothing listens on")
+	}
+}
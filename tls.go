@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// TLSInfo describes the negotiated TLS session and the certificate
+// chain presented by the peer.
+type TLSInfo struct {
+	Version      string
+	CipherSuite  string
+	Certificates []CertInfo
+}
+
+// OCSPInfo describes the OCSP response stapled to the handshake, if any.
+type OCSPInfo struct {
+	Stapled    bool
+	Status     string     `json:",omitempty"`
+	ThisUpdate *time.Time `json:",omitempty"`
+	NextUpdate *time.Time `json:",omitempty"`
+	RevokedAt  *time.Time `json:",omitempty"`
+	Error      string     `json:",omitempty"`
+}
+
+// CertInfo is a summary of a single certificate in the peer's chain.
+type CertInfo struct {
+	Subject            string
+	Issuer             string
+	DNSNames           []string `json:",omitempty"`
+	IPAddresses        []string `json:",omitempty"`
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyType            string
+	SignatureAlgorithm string
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// tlsVersion is a flag.Value which parses a TLS protocol version, eg. 1.2.
+type tlsVersion uint16
+
+func (v tlsVersion) String() string {
+	if v == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(tlsVersionName(uint16(v)), "TLS ")
+}
+
+func (v *tlsVersion) Set(s string) error {
+	for ver, name := range tlsVersions {
+		if strings.TrimPrefix(name, "TLS ") == s {
+			*v = tlsVersion(ver)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown TLS version %q", s)
+}
+
+func tlsVersionName(v uint16) string {
+	if name, ok := tlsVersions[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// newTLSInfo summarises the connection state of a completed handshake.
+func newTLSInfo(cs tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tlsVersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+	}
+	for _, cert := range cs.PeerCertificates {
+		info.Certificates = append(info.Certificates, newCertInfo(cert))
+	}
+	return info
+}
+
+func newCertInfo(cert *x509.Certificate) CertInfo {
+	ci := CertInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		DNSNames:           cert.DNSNames,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyType:            keyType(cert),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+	for _, ip := range cert.IPAddresses {
+		ci.IPAddresses = append(ci.IPAddresses, ip.String())
+	}
+	return ci
+}
+
+// keyType describes the certificate's public key algorithm and size,
+// eg. "RSA 2048" or "ECDSA P-256".
+func keyType(cert *x509.Certificate) string {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
+func printTLSInfo(info *TLSInfo) {
+	label := labelString
+	printf("\n%s %s\n", okString(info.Version), valueString(info.CipherSuite))
+	for i, c := range info.Certificates {
+		printf("%s %s\n", label(fmt.Sprintf("%2d subject:", i)), valueString(c.Subject))
+		printf("   %s %s\n", label("issuer: "), valueString(c.Issuer))
+		if names := append(append([]string{}, c.DNSNames...), c.IPAddresses...); len(names) > 0 {
+			printf("   %s %s\n", label("names:  "), valueString(strings.Join(names, ", ")))
+		}
+		printf("   %s %s\n", label("valid:  "), valueString("%s - %s (%s)",
+			c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"), validity(c.NotAfter)))
+		printf("   %s %s\n", label("key:    "), valueString("%s, signed with %s", c.KeyType, c.SignatureAlgorithm))
+	}
+}
+
+// describeHandshakeError explains why a TLS handshake failed, including
+// the range of protocol versions offered when that is likely relevant.
+func describeHandshakeError(err error) string {
+	msg := err.Error()
+	var rejected *tls.ECHRejectionError
+	if errors.As(err, &rejected) {
+		return "server rejected Encrypted Client Hello and fell back to the outer ClientHello"
+	}
+	if tlsMin == 0 && tlsMax == 0 {
+		return msg
+	}
+	if strings.Contains(msg, "protocol version") || strings.Contains(msg, "handshake failure") {
+		min, max := tlsVersionName(uint16(tlsMin)), tlsVersionName(uint16(tlsMax))
+		switch {
+		case tlsMin == 0:
+			min = "default"
+		case tlsMax == 0:
+			max = "default"
+		}
+		msg += fmt.Sprintf(" (offered %s to %s)", min, max)
+	}
+	return msg
+}
+
+// pins is a list of public key pins in curl's sha256//BASE64 format.
+type pins []string
+
+func (p pins) String() string { return strings.Join(p, ";") }
+
+func (p *pins) Set(v string) error {
+	for _, pin := range strings.Split(v, ";") {
+		if !strings.HasPrefix(pin, "sha256//") {
+			return fmt.Errorf("pin %q must be of the form sha256//BASE64", pin)
+		}
+		*p = append(*p, pin)
+	}
+	return nil
+}
+
+// verify is a tls.Config VerifyConnection callback which succeeds if
+// the public key of any certificate in the peer's chain matches a pin.
+func (p pins) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no peer certificates to verify public key pins against")
+	}
+	for _, cert := range cs.PeerCertificates {
+		pin := publicKeyPin(cert)
+		for _, want := range p {
+			if pin == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("public key pin mismatch: peer presented %s", publicKeyPin(cs.PeerCertificates[0]))
+}
+
+// publicKeyPin returns the SHA-256 pin of the certificate's SubjectPublicKeyInfo.
+func publicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256//" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Resumption reports the outcome of a TLS session resumption test.
+type Resumption struct {
+	FullHandshake    int
+	ResumedHandshake int
+	Resumed          bool
+	Error            string `json:",omitempty"`
+
+	full, resumed int // the handshakes in microseconds
+}
+
+// measureResumption performs two handshakes with the target of req over
+// fresh connections sharing a session cache; the first is a full
+// handshake, the second should resume the session using the ticket
+// issued during the first. The -total-time of the run bounds both.
+func measureResumption(ctx context.Context, tr *http.Transport, req *http.Request) *Resumption {
+	cfg := tr.TLSClientConfig.Clone()
+	cfg.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	cfg.NextProtos = nil
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 tr.Proxy,
+			DialContext:           tr.DialContext,
+			TLSClientConfig:       cfg,
+			TLSHandshakeTimeout:   tr.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: tr.ResponseHeaderTimeout,
+			DisableKeepAlives:     true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: maxTime,
+	}
+
+	var r Resumption
+	for i, us := range []*int{&r.full, &r.resumed} {
+		var tStart time.Time
+		var cs tls.ConnectionState
+		trace := &httptrace.ClientTrace{
+			TLSHandshakeStart: func() { tStart = time.Now() },
+			TLSHandshakeDone: func(state tls.ConnectionState, _ error) {
+				*us = usSince(tStart)
+				cs = state
+			},
+		}
+		hreq := req.Clone(httptrace.WithClientTrace(ctx, trace))
+		hreq.Method = http.MethodHead
+		hreq.Body = nil
+		hreq.ContentLength = 0
+		resp, err := client.Do(hreq)
+		if err != nil {
+			r.Error = err.Error()
+			return &r
+		}
+		// the session ticket arrives after the handshake, read the
+		// response fully so it is processed before the next attempt.
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if i == 1 {
+			r.Resumed = cs.DidResume
+		}
+	}
+	r.FullHandshake, r.ResumedHandshake = r.full/1000, r.resumed/1000
+	return &r
+}
+
+func printResumption(r *Resumption) {
+	label := labelString
+	if r.Error != "" {
+		printf("\n%s %s\n", label("TLS resumption:"), errorString(r.Error))
+		return
+	}
+	result := okString("resumed")
+	if !r.Resumed {
+		result = errorString("not resumed")
+	}
+	printf("\n%s %s\n", label("TLS resumption:"), result)
+	printf("   %s %s\n", label("full handshake:   "), valueString(formatTiming(r.full)))
+	printf("   %s %s\n", label("resumed handshake:"), valueString(formatTiming(r.resumed)))
+}
+
+// checkCertExpiry returns a warning if the leaf certificate presented
+// during the handshake expires within the given window.
+func checkCertExpiry(cs tls.ConnectionState, window time.Duration) string {
+	if len(cs.PeerCertificates) == 0 {
+		return ""
+	}
+	leaf := cs.PeerCertificates[0]
+	if time.Until(leaf.NotAfter) > window {
+		return ""
+	}
+	name := leaf.Subject.CommonName
+	if name == "" && len(leaf.DNSNames) > 0 {
+		name = leaf.DNSNames[0]
+	}
+	return fmt.Sprintf("certificate for %s expires %s (%s)",
+		name, leaf.NotAfter.Format(time.RFC3339), validity(leaf.NotAfter))
+}
+
+// newOCSPInfo parses and validates the OCSP response stapled during
+// the handshake against the leaf certificate and its issuer.
+func newOCSPInfo(cs tls.ConnectionState) *OCSPInfo {
+	if len(cs.OCSPResponse) == 0 {
+		return &OCSPInfo{}
+	}
+	info := &OCSPInfo{Stapled: true}
+
+	var leaf, issuer *x509.Certificate
+	switch {
+	case len(cs.VerifiedChains) > 0 && len(cs.VerifiedChains[0]) > 1:
+		leaf, issuer = cs.VerifiedChains[0][0], cs.VerifiedChains[0][1]
+	case len(cs.PeerCertificates) > 1:
+		leaf, issuer = cs.PeerCertificates[0], cs.PeerCertificates[1]
+	case len(cs.PeerCertificates) > 0:
+		leaf = cs.PeerCertificates[0]
+	}
+
+	var resp *ocsp.Response
+	var err error
+	if leaf != nil && issuer != nil {
+		resp, err = ocsp.ParseResponseForCert(cs.OCSPResponse, leaf, issuer)
+	} else {
+		resp, err = ocsp.ParseResponse(cs.OCSPResponse, nil)
+		if err == nil {
+			info.Error = "issuer certificate not available, signature not verified"
+		}
+	}
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		info.Status = "good"
+	case ocsp.Revoked:
+		info.Status = "revoked"
+		info.RevokedAt = &resp.RevokedAt
+	default:
+		info.Status = "unknown"
+	}
+	info.ThisUpdate = &resp.ThisUpdate
+	if !resp.NextUpdate.IsZero() {
+		info.NextUpdate = &resp.NextUpdate
+		if time.Now().After(resp.NextUpdate) {
+			info.Error = "stapled response is stale"
+		}
+	}
+	return info
+}
+
+// warning describes any problem with the stapled OCSP response.
+func (o *OCSPInfo) warning() string {
+	switch {
+	case !o.Stapled:
+		return "no OCSP response stapled"
+	case o.Status == "revoked":
+		return fmt.Sprintf("certificate revoked at %s", o.RevokedAt.Format(time.RFC3339))
+	case o.Error != "":
+		return "OCSP: " + o.Error
+	default:
+		return ""
+	}
+}
+
+func printOCSPInfo(o *OCSPInfo) {
+	if !o.Stapled {
+		return
+	}
+	label := labelString
+	status := o.Status
+	if status == "" {
+		status = "invalid"
+	}
+	printf("\n%s %s\n", label("OCSP:"), valueString(status))
+	if o.ThisUpdate != nil {
+		printf("   %s %s\n", label("produced:"), valueString("%s (%s ago)",
+			o.ThisUpdate.Format(time.RFC3339), time.Since(*o.ThisUpdate).Round(time.Minute)))
+	}
+	if o.NextUpdate != nil {
+		printf("   %s %s\n", label("next:    "), valueString(o.NextUpdate.Format(time.RFC3339)))
+	}
+}
+
+// validity describes how long remains until the given expiry time.
+func validity(notAfter time.Time) string {
+	d := time.Until(notAfter)
+	if d < 0 {
+		return fmt.Sprintf("expired %d days ago", int(-d.Hours()/24))
+	}
+	return fmt.Sprintf("%d days left", int(d.Hours()/24))
+}
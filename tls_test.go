@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestNewCertInfo(t *testing.T) {
+	b, err := ioutil.ReadFile("./test/singlecert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ci := newCertInfo(cert)
+	if want := "CN=client.example,O=Example Org,C=US"; ci.Subject != want {
+		t.Errorf("subject: want %q, got %q", want, ci.Subject)
+	}
+	if want := "RSA 2048"; ci.KeyType != want {
+		t.Errorf("key type: want %q, got %q", want, ci.KeyType)
+	}
+	if want := "SHA256-RSA"; ci.SignatureAlgorithm != want {
+		t.Errorf("signature algorithm: want %q, got %q", want, ci.SignatureAlgorithm)
+	}
+}
+
+func TestPinsVerify(t *testing.T) {
+	b, err := ioutil.ReadFile("./test/singlecert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	var p pins
+	if err := p.Set("sha256//AAAA;sha256//r1C3BpJoew+nN4BXz3mqcoUYGScsz1TcboImeaUaXSk="); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.verify(cs); err != nil {
+		t.Errorf("expected pin to match: %v", err)
+	}
+	if err := pins([]string{"sha256//AAAA"}).verify(cs); err == nil {
+		t.Errorf("expected pin mismatch")
+	}
+	if err := p.Set("md5//AAAA"); err == nil {
+		t.Errorf("expected error for unsupported hash")
+	}
+}
+
+func TestNewOCSPInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+
+	tmpl = &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+
+	staple, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := newOCSPInfo(tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca},
+		OCSPResponse:     staple,
+	})
+	if !info.Stapled || info.Status != "revoked" || info.Error != "" {
+		t.Errorf("unexpected OCSP info: %+v", info)
+	}
+
+	info = newOCSPInfo(tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca}})
+	if info.Stapled || info.warning() == "" {
+		t.Errorf("expected warning for missing staple: %+v", info)
+	}
+}
+
+func TestTransportTLSVersions(t *testing.T) {
+	defer func(min, max tlsVersion) { tlsMin, tlsMax = min, max }(tlsMin, tlsMax)
+	tlsMin, tlsMax = 0, tls.VersionTLS11
+
+	u, _ := url.Parse("https://example.com/")
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	for i := 0; i < 2; i++ {
+		c := newTransport(u, req).TLSClientConfig
+		if c.MinVersion != tls.VersionTLS10 || c.MaxVersion != tls.VersionTLS11 {
+			t.Errorf("transport %d offers TLS %x to %x, want 1.0 to 1.1", i+1, c.MinVersion, c.MaxVersion)
+		}
+	}
+	if tlsMin != 0 {
+		t.Errorf("-tls-min changed to %v", tlsMin)
+	}
+}
+
+func TestMeasureResumption(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+	tr := ts.Client().Transport.(*http.Transport)
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+
+	r := measureResumption(context.Background(), tr, req)
+	if r.Error != "" || !r.Resumed || r.full <= 0 || r.resumed <= 0 {
+		t.Errorf("resumption %+v", r)
+	}
+
+	output, precision := color.Output, timingPrecision
+	defer func() { color.Output, timingPrecision = output, precision }()
+	var buf bytes.Buffer
+	color.Output, timingPrecision = &buf, "us"
+	printResumption(r)
+	if !regexp.MustCompile(`full handshake: +\d+\.\d{3}ms`).Match(buf.Bytes()) {
+		t.Errorf("printed without the -precision:\n%s", buf.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := measureResumption(ctx, tr, req); r.Error == "" {
+		t.Errorf("with the run over: %+v", r)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// traceDumper records the bytes sent and received on connections for
+// -trace-dump, as a hex dump of each read and write.
+type traceDumper struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (d *traceDumper) record(addr, event string, b []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fmt.Fprintf(d.w, "%s %s %s\n", time.Now().Format("15:04:05.000000"), addr, event)
+	if len(b) > 0 {
+		io.WriteString(d.w, hex.Dump(b))
+	}
+}
+
+// dumpConn is a connection whose traffic is recorded by a traceDumper.
+type dumpConn struct {
+	net.Conn
+	addr string
+	d    *traceDumper
+}
+
+func (c *dumpConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.d.record(c.addr, fmt.Sprintf("< received %d bytes", n), b[:n])
+	}
+	return n, err
+}
+
+func (c *dumpConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.d.record(c.addr, fmt.Sprintf("> sent %d bytes", n), b[:n])
+	}
+	return n, err
+}
+
+func (c *dumpConn) Close() error {
+	c.d.record(c.addr, "closed", nil)
+	return c.Conn.Close()
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dial returns a dial function recording the connections made with
+// dial, or a plain dialer if that's nil.
+func (d *traceDumper) dial(dial dialFunc) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		d.record(addr, "connected", nil)
+		return &dumpConn{Conn: conn, addr: addr, d: d}, nil
+	}
+}
+
+// dialTLS returns a TLS dial function which records the plaintext of
+// the connections it makes, so the handshake is done here rather than
+// by the transport. It reports the handshake to the request's trace as
+// the transport would have.
+func (d *traceDumper) dialTLS(dial dialFunc, config *tls.Config, timeout time.Duration) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := config.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, cfg)
+
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		hctx, cancel := context.WithTimeout(ctx, timeout)
+		err = tc.HandshakeContext(hctx)
+		cancel()
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tc.ConnectionState(), err)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		d.record(addr, "connected, "+tlsVersionName(tc.ConnectionState().Version), nil)
+		return &dumpConn{Conn: tc, addr: addr, d: d}, nil
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestTraceDump(t *testing.T) {
+	var buf bytes.Buffer
+	d := &traceDumper{w: &buf}
+	client, server := net.Pipe()
+	dial := d.dial(func(context.Context, string, string) (net.Conn, error) { return client, nil })
+	conn, err := dial(context.Background(), "tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		b := make([]byte, 4)
+		io.ReadFull(server, b)
+		server.Write([]byte("pong\r\n"))
+	}()
+	conn.Write([]byte("ping"))
+	io.ReadFull(conn, make([]byte, 6))
+	conn.Close()
+
+	out := buf.String()
+	for _, want := range []string{
+		"example.com:80 connected\n",
+		"example.com:80 > sent 4 bytes\n00000000  70 69 6e 67",
+		"|ping|\n",
+		"example.com:80 < received 6 bytes\n",
+		"|pong..|\n",
+		"example.com:80 closed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump missing %q:\n%s", want, out)
+		}
+	}
+}
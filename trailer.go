@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// setTrailers declares the -trailer fields on req, sending its body
+// chunked as HTTP/1.1 can only send trailers after a chunked body.
+func setTrailers(req *http.Request, trailers headers) {
+	req.Trailer = make(http.Header)
+	for _, t := range trailers {
+		k, v := headerKeyValue(t)
+		req.Trailer.Add(k, v)
+	}
+	setChunked(req)
+}
+
+// receivedTrailers returns the trailers of resp which were sent, once
+// its body has been read, or nil if there were none.
+func receivedTrailers(resp *http.Response) http.Header {
+	var h http.Header
+	for k, v := range resp.Trailer {
+		if len(v) == 0 {
+			// declared in the Trailer header but never sent.
+			continue
+		}
+		if h == nil {
+			h = make(http.Header)
+		}
+		h[k] = v
+	}
+	return h
+}
+
+func printTrailers(h http.Header) {
+	printf("\n%s\n", labelString("Trailers:"))
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		printf("%s %s\n", labelString(k+":"), valueString(strings.Join(h[k], ",")))
+	}
+}
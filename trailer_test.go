@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrailers(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		got = r.Trailer
+		w.Header().Set("Trailer", "X-Checksum, X-Unsent")
+		w.Write([]byte("body"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+	setTrailers(req, headers{"X-Sum: 42"})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Sum") != "42" {
+		t.Errorf("server got trailers %v, want X-Sum: 42", got)
+	}
+
+	// trailers are only known once the body has been read.
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	h := receivedTrailers(resp)
+	if len(h) != 1 || h.Get("X-Checksum") != "abc" {
+		t.Errorf("receivedTrailers = %v, want just X-Checksum", h)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+)
+
+// requestEcho collects the request line and header fields of a request
+// as the transport writes them, for -V.
+type requestEcho struct {
+	method, target string
+	http2          bool
+	fields         []string
+}
+
+// add records a header field written by the transport. HTTP/2 requests
+// start with pseudo-header fields in place of a request line; the
+// authority is shown as the Host header it replaces.
+func (e *requestEcho) add(key string, values []string) {
+	if strings.HasPrefix(key, ":") {
+		e.http2 = true
+		if key == ":authority" {
+			e.fields = append(e.fields, "host: "+strings.Join(values, ","))
+		}
+		return
+	}
+	for _, v := range values {
+		e.fields = append(e.fields, key+": "+v)
+	}
+}
+
+// lines returns the request as sent, less the blank line ending it.
+func (e *requestEcho) lines() []string {
+	proto := "HTTP/1.1"
+	if e.http2 {
+		proto = "HTTP/2"
+	}
+	return append([]string{e.method + " " + e.target + " " + proto}, e.fields...)
+}
+
+// printSentLines prints the lines of a request curl style, marked with >.
+func printSentLines(lines []string) {
+	label := labelString
+	printf("\n")
+	for _, line := range lines {
+		printf("%s %s\n", label(">"), valueString(line))
+	}
+}
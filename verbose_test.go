@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequestEcho(t *testing.T) {
+	e := &requestEcho{method: "GET", target: "/a?b=1"}
+	e.add("Host", []string{"example.com"})
+	e.add("Accept", []string{"text/html", "*/*"})
+	want := []string{"GET /a?b=1 HTTP/1.1", "Host: example.com", "Accept: text/html", "Accept: */*"}
+	if got := e.lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTTP/1.1: got %q, want %q", got, want)
+	}
+
+	e = &requestEcho{method: "GET", target: "/"}
+	for _, f := range [][2]string{{":authority", "example.com"}, {":method", "GET"}, {":path", "/"}, {":scheme", "https"}, {"user-agent", "test"}} {
+		e.add(f[0], []string{f[1]})
+	}
+	want = []string{"GET / HTTP/2", "host: example.com", "user-agent: test"}
+	if got := e.lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTTP/2: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+const (
+	watchWindow = 200 // requests kept for the chart and percentiles
+	watchErrors = 5   // errors listed
+)
+
+// watchProbe is a request made by the watch subcommand.
+type watchProbe struct {
+	at     time.Time
+	report *Report // nil if the request failed
+	err    string
+}
+
+// watcher holds what the watch subcommand shows.
+type watcher struct {
+	url      *url.URL
+	interval time.Duration
+	started  time.Time
+	requests int
+	statuses map[string]int
+	failures int
+	probes   []watchProbe // the last watchWindow requests, oldest first
+	errors   []watchProbe // the last watchErrors failures
+}
+
+func (w *watcher) add(p watchProbe) {
+	w.requests++
+	if p.report == nil {
+		w.failures++
+		w.errors = append(w.errors, p)
+		if len(w.errors) > watchErrors {
+			w.errors = w.errors[1:]
+		}
+	} else {
+		w.statuses[p.report.Status]++
+	}
+	w.probes = append(w.probes, p)
+	if len(w.probes) > watchWindow {
+		w.probes = w.probes[1:]
+	}
+}
+
+// lastReport returns the report of the latest successful request.
+func (w *watcher) lastReport() *Report {
+	for i := len(w.probes) - 1; i >= 0; i-- {
+		if w.probes[i].report != nil {
+			return w.probes[i].report
+		}
+	}
+	return nil
+}
+
+// render draws the screen, width columns wide.
+func (w *watcher) render(width int) string {
+	var b strings.Builder
+	out := color.Output
+	color.Output = &b
+	defer func() { color.Output = out }()
+	label := labelString
+
+	printf("%s %s  %s\n\n", headerString("httpstat watch"), valueString(w.url.String()),
+		label("every %s, %s, Ctrl-C to quit", w.interval, time.Now().Format("15:04:05")))
+
+	if len(w.probes) > 0 {
+		last := w.probes[len(w.probes)-1]
+		if last.report != nil {
+			printf("%s %s %s\n", label("Last request:"), statusString(last.report.Status),
+				label("at %s", last.at.Format("15:04:05")))
+		} else {
+			printf("%s %s %s\n", label("Last request:"), errorString("failed"),
+				label("at %s", last.at.Format("15:04:05")))
+		}
+	}
+	if r := w.lastReport(); r != nil {
+		printCompactWaterfall(w.url.Scheme, r.micros, width)
+	}
+
+	var totals []float64
+	var failed []bool
+	for _, p := range w.probes {
+		if p.report == nil {
+			totals, failed = append(totals, 0), append(failed, true)
+			continue
+		}
+		totals = append(totals, phaseTimes([]Report{*p.report}, func(t Timing) int { return t.Total })[0])
+		failed = append(failed, false)
+	}
+	cols := max(width-10, 10)
+	if len(totals) > cols {
+		totals, failed = totals[len(totals)-cols:], failed[len(failed)-cols:]
+	}
+	if len(totals) > 0 {
+		printf("\n%s\n", label("Total time of the last %d requests:", len(totals)))
+		for _, line := range latencyChart(totals, failed, chartHeight) {
+			printf("%s\n", line)
+		}
+	}
+
+	statuses := make([]string, 0, len(w.statuses)+1)
+	for status, n := range w.statuses {
+		s := valueString("%d %s %s", n, glyph("×", "x"), status)
+		if code := statusCode(status); code >= "500" {
+			s = errorString("%d %s %s", n, glyph("×", "x"), status)
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	if w.failures > 0 {
+		statuses = append(statuses, errorString("%d failed", w.failures))
+	}
+	printf("\n%s %s\n", label("%d requests since %s:", w.requests, w.started.Format("15:04:05")), strings.Join(statuses, label(", ")))
+
+	var ok []Report
+	for _, p := range w.probes {
+		if p.report != nil {
+			ok = append(ok, *p.report)
+		}
+	}
+	if len(ok) > 1 {
+		printStats("Total:", newStats(phaseTimes(ok, func(t Timing) int { return t.Total })))
+	}
+
+	if len(w.errors) > 0 {
+		printf("\n%s\n", label("Errors:"))
+		for _, e := range w.errors {
+			printf("   %s %s\n", label(e.at.Format("15:04:05")), errorString(e.err))
+		}
+	}
+	return b.String()
+}
+
+// runWatch is the watch subcommand, which repeats a request and keeps
+// its timing on screen until interrupted.
+func runWatch(args []string) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s watch [OPTIONS] URL\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Repeat a request every -w, 1s unless set, and keep its timing on screen until interrupted.")
+		fmt.Fprintln(os.Stderr, "\nOPTIONS:")
+		flag.PrintDefaults()
+	}
+	requestDelay = time.Second
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	u := configure(flag.Arg(0))
+	numRequests, summaryOnly = 1, true
+
+	out := color.Output
+	w := &watcher{url: u, interval: requestDelay, started: time.Now(), statuses: map[string]int{}}
+	// on a terminal, redraw the alternate screen and restore the
+	// terminal on exit.
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	clear := "\n"
+	if tty {
+		clear = "\x1b[H\x1b[2J"
+		fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+			closeKeyLog()
+			os.Exit(exitStatus)
+		}()
+	}
+
+	for {
+		start := time.Now()
+		color.Output = ioutil.Discard
+		redirectsFollowed = 0
+		reports, err := tryVisit(u)
+		color.Output = out
+
+		p := watchProbe{at: start}
+		switch {
+		case err != nil:
+			p.err = err.Error()
+		case len(reports) > 0:
+			p.report = &reports[len(reports)-1]
+		}
+		w.add(p)
+
+		width := terminalWidth
+		if tty {
+			width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+		}
+		fmt.Fprint(out, clear+w.render(width))
+		time.Sleep(requestDelay - time.Since(start))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatcherAdd(t *testing.T) {
+	w := &watcher{statuses: map[string]int{}}
+	for i := 0; i < watchWindow+10; i++ {
+		p := watchProbe{at: time.Now()}
+		if i%3 == 0 {
+			p.err = "connection refused"
+		} else {
+			p.report = &Report{Status: "200 OK"}
+		}
+		w.add(p)
+	}
+	if w.requests != watchWindow+10 || len(w.probes) != watchWindow {
+		t.Errorf("%d requests, %d kept", w.requests, len(w.probes))
+	}
+	if w.failures != 70 || w.statuses["200 OK"] != 140 || len(w.errors) != watchErrors {
+		t.Errorf("%d failures, %v, %d errors kept", w.failures, w.statuses, len(w.errors))
+	}
+	if w.lastReport() == nil {
+		t.Error("no last report")
+	}
+}
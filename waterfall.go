@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+)
+
+// terminalWidth is the width of the terminal output goes to, or 0 if
+// it isn't a terminal.
+var terminalWidth int
+
+// templateWidth returns the width of the widest line of a template.
+func templateWidth(tmpl string) int {
+	w := 0
+	for _, line := range strings.Split(tmpl, "\n") {
+		w = max(w, len(line))
+	}
+	return w
+}
+
+// printCompactWaterfall prints the timing, in microseconds, of each
+// phase on a line of its own, with a bar showing when it started and how long it took, in
+// width columns.
+func printCompactWaterfall(scheme string, t Timing, width int) {
+	phases := []struct {
+		name            string
+		start, duration int
+	}{
+		{"DNS Lookup", 0, t.DNS},
+		{"TCP Connection", t.Lookup, t.TCP},
+		{"TLS Handshake", t.Connect, t.TLS},
+		{"Server Processing", t.PreTransfer, t.Server},
+		{"Content Transfer", t.StartTransfer, t.Transfer},
+	}
+	if width == 0 {
+		width = 80
+	}
+	// the name and duration take up to 30 columns.
+	barWidth := max(width-32, 10)
+
+	for _, p := range phases {
+		if p.name == "TLS Handshake" && scheme != "https" {
+			continue
+		}
+		line := labelString("%-18s", p.name) + " " + valueString("%8s", formatTiming(p.duration))
+		if t.Total > 0 && p.duration > 0 {
+			offset := min(p.start*barWidth/t.Total, barWidth-1)
+			n := min(max(p.duration*barWidth/t.Total, 1), barWidth-offset)
+			line += "  " + strings.Repeat(" ", offset) + valueString(strings.Repeat(glyph("█", "#"), n))
+		}
+		printf("%s\n", line)
+	}
+	printf("%s %s\n", labelString("%-18s", "Total"), valueString("%8s", formatTiming(t.Total)))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestTemplateWidth(t *testing.T) {
+	if got := templateWidth("ab\nabcd\nabc\n"); got != 4 {
+		t.Errorf("got %d, want 4", got)
+	}
+	if w := templateWidth(httpsTemplate); w <= 80 {
+		t.Errorf("https template is %d columns, expected it not to fit 80", w)
+	}
+}
+
+func TestPrintCompactWaterfall(t *testing.T) {
+	output, noColor, ascii := color.Output, color.NoColor, asciiOutput
+	defer func() { color.Output, color.NoColor, asciiOutput = output, noColor, ascii }()
+	var buf bytes.Buffer
+	color.Output, color.NoColor, asciiOutput = &buf, true, true
+
+	timing := Timing{
+		DNS: 10000, TCP: 10000, TLS: 20000, Server: 40000, Transfer: 20000,
+		Lookup: 10000, Connect: 20000, PreTransfer: 40000, StartTransfer: 80000, Total: 100000,
+	}
+	printCompactWaterfall("https", timing, 52)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 6:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []string{
+		"DNS Lookup             10ms  ##",
+		"TCP Connection         10ms    ##",
+		"TLS Handshake          20ms      ####",
+		"Server Processing      40ms          ########",
+		"Content Transfer       20ms                  ####",
+		"Total                 100ms",
+	} {
+		if lines[i] != want {
+			t.Errorf("line %d is %q, want %q", i, lines[i], want)
+		}
+	}
+
+	buf.Reset()
+	printCompactWaterfall("http", timing, 48)
+	if strings.Contains(buf.String(), "TLS") {
+		t.Errorf("TLS handshake shown for http:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebSocket opcodes, RFC 6455 section 5.2.
+const (
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+
+	wsGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsCloseWait   = 2 * time.Second
+	wsMaxFrameLen = 1 << 24
+)
+
+// WebSocketInfo reports the outcome of a WebSocket upgrade and the
+// round trip times of any pings sent over it.
+type WebSocketInfo struct {
+	Upgraded bool
+	Protocol string `json:",omitempty"`
+	Pings    Stats
+	Error    string `json:",omitempty"`
+}
+
+// setWebSocketHeaders turns req into a WebSocket opening handshake.
+func setWebSocketHeaders(req *http.Request) {
+	key := make([]byte, 16)
+	rand.Read(key)
+	req.Method = http.MethodGet
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+}
+
+// websocketAccept is the Sec-WebSocket-Accept value expected in answer
+// to key.
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// websocketSession checks the server accepted the upgrade requested by
+// req, sends pings pings over the connection timing the pongs, then
+// closes it.
+func websocketSession(req *http.Request, resp *http.Response, pings int) *WebSocketInfo {
+	ws := &WebSocketInfo{Protocol: resp.Header.Get("Sec-WebSocket-Protocol")}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		ws.Error = "server did not switch protocols: " + resp.Status
+		return ws
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(req.Header.Get("Sec-WebSocket-Key")) {
+		ws.Error = "invalid Sec-WebSocket-Accept in the server's response"
+		return ws
+	}
+	ws.Upgraded = true
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		ws.Error = "connection can't be written to after the upgrade"
+		return ws
+	}
+	r := bufio.NewReader(conn)
+
+	var rtts []float64
+	for i := 0; i < pings; i++ {
+		if i > 0 {
+			time.Sleep(requestDelay)
+		}
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, uint64(i))
+		start := time.Now()
+		if err := writeFrame(conn, wsPing, payload); err != nil {
+			ws.Error = fmt.Sprintf("ping failed: %v", err)
+			return ws
+		}
+		if err := awaitFrame(r, conn, wsPong, payload); err != nil {
+			ws.Error = fmt.Sprintf("no pong received: %v", err)
+			return ws
+		}
+		rtts = append(rtts, float64(time.Since(start))/float64(time.Millisecond))
+	}
+	ws.Pings = newStats(rtts)
+
+	// close normally, giving the server a moment to echo the close.
+	writeFrame(conn, wsClose, []byte{0x03, 0xe8})
+	done := make(chan struct{})
+	go func() {
+		awaitFrame(r, conn, wsClose, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(wsCloseWait):
+	}
+	return ws
+}
+
+// writeFrame writes a single masked frame, as clients must send.
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, 0x80|byte(n))
+	case n <= 0xffff:
+		hdr = append(hdr, 0x80|126, byte(n>>8), byte(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64(append(hdr, 0x80|127), uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	hdr = append(hdr, mask...)
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	_, err := w.Write(append(hdr, masked...))
+	return err
+}
+
+// readFrame reads a single frame, returning its opcode and payload.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := hdr[0] & 0x0f
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var l uint16
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(l)
+	case 127:
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return 0, nil, err
+		}
+	}
+	if n > wsMaxFrameLen {
+		return 0, nil, errors.New("frame too large")
+	}
+	var mask []byte
+	if hdr[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range mask {
+		for j := i; j < len(payload); j += 4 {
+			payload[j] ^= mask[i]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// awaitFrame reads frames until one with the given opcode, and payload
+// if not nil, arrives, answering any pings from the server meanwhile.
+func awaitFrame(r *bufio.Reader, w io.Writer, opcode byte, payload []byte) error {
+	for {
+		op, p, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case op == opcode && (payload == nil || string(p) == string(payload)):
+			return nil
+		case op == wsPing:
+			writeFrame(w, wsPong, p)
+		case op == wsClose:
+			return errors.New("connection closed by server")
+		}
+	}
+}
+
+func printWebSocketInfo(ws *WebSocketInfo) {
+	label := labelString
+	if !ws.Upgraded {
+		printf("\n%s %s\n", label("WebSocket:"), errorString(ws.Error))
+		return
+	}
+	proto := ""
+	if ws.Protocol != "" {
+		proto = " " + label("(protocol "+ws.Protocol+")")
+	}
+	printf("\n%s %s%s\n", label("WebSocket:"), okString("upgraded"), proto)
+	if ws.Pings.Count > 0 {
+		printStats(fmt.Sprintf("%d pings:", ws.Pings.Count), ws.Pings)
+	}
+	if ws.Error != "" {
+		printf("   %s\n", errorString(ws.Error))
+	}
+}
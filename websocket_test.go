@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestWebsocketAccept(t *testing.T) {
+	// from RFC 6455 section 1.3
+	got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ==")
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("websocketAccept = %q, want %q", got, want)
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 8, 125, 126, 300, 70000} {
+		payload := bytes.Repeat([]byte{'x'}, n)
+		var b bytes.Buffer
+		if err := writeFrame(&b, wsPing, payload); err != nil {
+			t.Fatal(err)
+		}
+		if b.Bytes()[1]&0x80 == 0 {
+			t.Errorf("%d byte frame isn't masked", n)
+		}
+		op, got, err := readFrame(bufio.NewReader(&b))
+		if err != nil {
+			t.Fatalf("%d byte frame: %v", n, err)
+		}
+		if op != wsPing || !bytes.Equal(got, payload) {
+			t.Errorf("%d byte frame read back as opcode %#x with %d bytes", n, op, len(got))
+		}
+	}
+}
+
+func TestAwaitFrameAnswersPings(t *testing.T) {
+	var in, out bytes.Buffer
+	writeFrame(&in, wsPing, []byte("hi"))
+	writeFrame(&in, wsPong, []byte("other"))
+	writeFrame(&in, wsPong, []byte("mine"))
+	if err := awaitFrame(bufio.NewReader(&in), &out, wsPong, []byte("mine")); err != nil {
+		t.Fatal(err)
+	}
+	op, p, err := readFrame(bufio.NewReader(&out))
+	if err != nil || op != wsPong || string(p) != "hi" {
+		t.Errorf("got opcode %#x %q %v, want a pong echoing the ping", op, p, err)
+	}
+}
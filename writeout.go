@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// writeOutVar matches the curl -w variables: %{name}, %header{name}
+// and the %% escape.
+var writeOutVar = regexp.MustCompile(`%\{([a-z_]+)\}|%header\{([^}]+)\}|%%`)
+
+// writeOutValues gives the values of the curl -w variables supported
+// by -write-out, for the report of a request to u.
+var writeOutValues = map[string]func(r *Report, u *url.URL) string{
+	"content_type":       func(r *Report, _ *url.URL) string { return r.Header.Get("Content-Type") },
+	"http_code":          func(r *Report, _ *url.URL) string { return statusCode(r.Status) },
+	"response_code":      func(r *Report, _ *url.URL) string { return statusCode(r.Status) },
+	"http_version":       func(r *Report, _ *url.URL) string { return httpVersion(r.Proto) },
+	"json":               func(r *Report, _ *url.URL) string { b, _ := json.Marshal(r); return string(b) },
+	"num_redirects":      func(*Report, *url.URL) string { return strconv.Itoa(redirectsFollowed) },
+	"remote_ip":          func(r *Report, _ *url.URL) string { h, _, _ := net.SplitHostPort(r.Address); return h },
+	"remote_port":        func(r *Report, _ *url.URL) string { _, p, _ := net.SplitHostPort(r.Address); return p },
+	"scheme":             func(_ *Report, u *url.URL) string { return strings.ToUpper(u.Scheme) },
+	"size_download":      func(r *Report, _ *url.URL) string { return strconv.FormatInt(r.Size.BodyBytes, 10) },
+	"size_header":        func(r *Report, _ *url.URL) string { return strconv.FormatInt(r.Size.HeaderBytes, 10) },
+	"size_request":       func(r *Report, _ *url.URL) string { return strconv.FormatInt(r.Size.SentBytes, 10) },
+	"size_upload":        func(r *Report, _ *url.URL) string { return strconv.FormatInt(uploadBytes(r), 10) },
+	"speed_download":     func(r *Report, _ *url.URL) string { return strconv.FormatInt(int64(r.Size.Throughput*1e6), 10) },
+	"time_appconnect":    func(r *Report, u *url.URL) string { return appConnect(r, u) },
+	"time_connect":       func(r *Report, _ *url.URL) string { return seconds(r, func(t Timing) int { return t.Connect }) },
+	"time_namelookup":    func(r *Report, _ *url.URL) string { return seconds(r, func(t Timing) int { return t.Lookup }) },
+	"time_pretransfer":   func(r *Report, _ *url.URL) string { return seconds(r, func(t Timing) int { return t.PreTransfer }) },
+	"time_redirect":      func(*Report, *url.URL) string { return "0.000000" },
+	"time_starttransfer": func(r *Report, _ *url.URL) string { return seconds(r, func(t Timing) int { return t.StartTransfer }) },
+	"time_total":         func(r *Report, _ *url.URL) string { return seconds(r, func(t Timing) int { return t.Total }) },
+	"url_effective":      func(_ *Report, u *url.URL) string { return u.String() },
+}
+
+// parseWriteOut checks a -write-out format, read from a file if it
+// starts with @ as for curl, and interprets its \n, \t and \\ escapes.
+func parseWriteOut(s string) (string, error) {
+	s, err := readArgument(s)
+	if err != nil {
+		return "", fmt.Errorf("unable to read -write-out format: %v", err)
+	}
+	for _, m := range writeOutVar.FindAllStringSubmatch(s, -1) {
+		if name := m[1]; name != "" && writeOutValues[name] == nil {
+			return "", fmt.Errorf("unknown -write-out variable %%{%s}", name)
+		}
+	}
+	return formatEscapes.Replace(s), nil
+}
+
+// writeOut expands the variables in format for the report of a request
+// to u.
+func writeOut(format string, r *Report, u *url.URL) string {
+	return writeOutVar.ReplaceAllStringFunc(format, func(m string) string {
+		sm := writeOutVar.FindStringSubmatch(m)
+		switch {
+		case sm[1] != "":
+			return writeOutValues[sm[1]](r, u)
+		case sm[2] != "":
+			return strings.Join(r.Header.Values(sm[2]), ", ")
+		default:
+			return "%"
+		}
+	})
+}
+
+// seconds formats the time of a phase of r as curl does times, to the
+// microsecond with -precision us.
+func seconds(r *Report, phase func(Timing) int) string {
+	if r.TimingMicros != nil {
+		return fmt.Sprintf("%.6f", float64(phase(*r.TimingMicros))/1e6)
+	}
+	return fmt.Sprintf("%.6f", float64(phase(r.Timing))/1000)
+}
+
+// statusCode returns the code of a status such as "200 OK".
+func statusCode(status string) string {
+	code, _, _ := strings.Cut(status, " ")
+	return code
+}
+
+// statusNumber returns the code of status as a number, or 0 if status
+// has none.
+func statusNumber(status string) int {
+	n, err := strconv.Atoi(statusCode(status))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// httpVersion formats a protocol such as HTTP/2.0 as curl does, 2.
+func httpVersion(proto string) string {
+	v := strings.TrimPrefix(proto, "HTTP/")
+	if v == "2.0" || v == "3.0" {
+		v = v[:1]
+	}
+	return v
+}
+
+func uploadBytes(r *Report) int64 {
+	if r.Upload == nil {
+		return 0
+	}
+	return r.Upload.Bytes
+}
+
+// appConnect is when the TLS handshake completed, which is never for
+// plain HTTP.
+func appConnect(r *Report, u *url.URL) string {
+	if u.Scheme != "https" {
+		return "0.000000"
+	}
+	return seconds(r, func(t Timing) int { return t.PreTransfer })
+}
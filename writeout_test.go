@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteOut(t *testing.T) {
+	r := &Report{
+		Address: "192.0.2.1:443",
+		Proto:   "HTTP/2.0",
+		Status:  "404 Not Found",
+		Header:  http.Header{"Content-Type": {"text/html"}, "Vary": {"Accept", "Origin"}},
+	}
+	r.Timing.Lookup = 5
+	r.Timing.PreTransfer = 30
+	r.Timing.Total = 1234
+	r.Size.BodyBytes = 99
+	u, _ := url.Parse("https://example.com/x")
+
+	format, err := parseWriteOut(`%{http_code} %{http_version} %{remote_ip} %{remote_port}\t%{time_namelookup} %{time_appconnect} %{time_total} %{size_download} %{content_type} %header{vary} %{scheme} 100%% %{url_effective}\n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "404 2 192.0.2.1 443\t0.005000 0.030000 1.234000 99 text/html Accept, Origin HTTPS 100% https://example.com/x\n"
+	if got := writeOut(format, r, u); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseWriteOut(t *testing.T) {
+	if _, err := parseWriteOut("%{time_total} %{nope}"); err == nil {
+		t.Errorf("unknown variable accepted")
+	}
+
+	filename := filepath.Join(t.TempDir(), "format")
+	ioutil.WriteFile(filename, []byte("%{http_code}\n"), 0644)
+	if got, err := parseWriteOut("@" + filename); err != nil || got != "%{http_code}\n" {
+		t.Errorf("parseWriteOut(@file) = %q, %v", got, err)
+	}
+}